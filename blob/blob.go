@@ -0,0 +1,127 @@
+// Package blob stores the contents of uploaded files behind a small Store
+// interface, so that the local filesystem can be swapped for an object store.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when no blob is stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under slash separated keys such as "todoId/attachmentId".
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the contents of a blob. The reader also implements
+	// io.Seeker when the store supports it.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FileStore is a Store keeping each blob in a file below a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore rooted at dir, creating dir if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("NewFileStore create dir %q: %v", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes r to the file of key. The file only appears once it has been
+// written completely.
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("FileStore put %q: %v", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("FileStore put %q: %v", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileStore put %q: %v", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("FileStore put %q: %v", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("FileStore put %q: %v", key, err)
+	}
+	return nil
+}
+
+// Open returns the file of key, which is an io.ReadSeeker.
+func (s *FileStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("FileStore open %q: %v", key, err)
+	}
+	return f, nil
+}
+
+// Delete removes the file of key. Deleting a missing blob is not an error.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("FileStore delete %q: %v", key, err)
+	}
+	return nil
+}
+
+// path maps a key to a file below the store directory, rejecting keys that
+// could escape it.
+func (s *FileStore) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// ValidateKey checks that key is made of non-empty segments of letters,
+// digits, '-', '_' and '.', other than "." and "..", separated by '/'.
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+		for _, c := range segment {
+			if !isKeyChar(c) {
+				return fmt.Errorf("invalid blob key %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+func isKeyChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
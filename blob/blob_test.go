@@ -0,0 +1,64 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	if err := store.Put(ctx, "todo1/receipt", strings.NewReader("paid 12.50")); err != nil {
+		t.Fatalf("FileStore.Put() error = %v", err)
+	}
+	f, err := store.Open(ctx, "todo1/receipt")
+	if err != nil {
+		t.Fatalf("FileStore.Open() error = %v", err)
+	}
+	got, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(got) != "paid 12.50" {
+		t.Errorf("FileStore.Open() read %q, %v, want %q", got, err, "paid 12.50")
+	}
+
+	if err := store.Delete(ctx, "todo1/receipt"); err != nil {
+		t.Fatalf("FileStore.Delete() error = %v", err)
+	}
+	if _, err := store.Open(ctx, "todo1/receipt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FileStore.Open() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "todo1/receipt"); err != nil {
+		t.Errorf("FileStore.Delete() of missing blob error = %v", err)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"caajol287d5nser73bs0/caajol287d5nseratt1", false},
+		{"receipt.pdf", false},
+		{"", true},
+		{"../etc/passwd", true},
+		{"todo1/../../secret", true},
+		{"/absolute", true},
+		{"todo1//double", true},
+		{"todo1/with space", true},
+		{`todo1\back`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := ValidateKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
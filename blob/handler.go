@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+)
+
+// Handler serves blobs of a Store at the URLs made by a Signer. It must be
+// mounted at the prefix of the Signer.
+type Handler struct {
+	store  Store
+	signer *Signer
+}
+
+func NewHandler(store Store, signer *Signer) *Handler {
+	return &Handler{store: store, signer: signer}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, h.signer.prefix)
+	if err := ValidateKey(key); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.signer.Verify(key, r.URL.Query(), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	blob, err := h.store.Open(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error().Err(err).Str("key", key).Msg("blob handler open")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// uploads are never rendered inline, so that they cannot run scripts on
+	// this origin
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if rs, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, rs)
+		return
+	}
+	if _, err := io.Copy(w, blob); err != nil {
+		logging.FromContext(r.Context()).Warn().Err(err).Str("key", key).Msg("blob handler copy")
+	}
+}
@@ -0,0 +1,53 @@
+package blob
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if err := store.Put(context.Background(), "todo1/receipt", strings.NewReader("paid 12.50")); err != nil {
+		t.Fatalf("FileStore.Put() error = %v", err)
+	}
+	signer := NewSigner([]byte("secret"), "/files/")
+	handler := NewHandler(store, signer)
+
+	valid := signer.URL("todo1/receipt", time.Now().Add(time.Minute))
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"signed url should download", valid, http.StatusOK, "paid 12.50"},
+		{"expired url should be forbidden", signer.URL("todo1/receipt", time.Now().Add(-time.Minute)), http.StatusForbidden, ""},
+		{"url for another key should be forbidden", strings.Replace(valid, "receipt", "other", 1), http.StatusForbidden, ""},
+		{"url signed with another secret should be forbidden",
+			NewSigner([]byte("guess"), "/files/").URL("todo1/receipt", time.Now().Add(time.Minute)), http.StatusForbidden, ""},
+		{"unsigned url should be forbidden", "/files/todo1/receipt", http.StatusForbidden, ""},
+		{"missing blob should not be found", signer.URL("todo1/missing", time.Now().Add(time.Minute)), http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Handler status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("Handler body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Header().Get("Content-Disposition") != "attachment" {
+				t.Errorf("Handler Content-Disposition = %q, want attachment", rec.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
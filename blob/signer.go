@@ -0,0 +1,53 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Signer creates and checks download URLs that are only valid until they
+// expire, so that blobs can be served without further authentication.
+type Signer struct {
+	secret []byte
+	// prefix is the path the download handler is mounted at.
+	prefix string
+}
+
+// NewSigner returns a Signer for URLs below prefix, e.g. "/files/".
+func NewSigner(secret []byte, prefix string) *Signer {
+	return &Signer{secret: secret, prefix: prefix}
+}
+
+// URL returns a download URL of key that expires at expires.
+func (s *Signer) URL(key string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{"expires": {exp}, "signature": {s.signature(key, exp)}}
+	return s.prefix + key + "?" + query.Encode()
+}
+
+// Verify checks the expiry and signature query values of a download of key.
+func (s *Signer) Verify(key string, query url.Values, now time.Time) error {
+	exp := query.Get("expires")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiry %q", exp)
+	}
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(s.signature(key, exp))) {
+		return fmt.Errorf("invalid signature")
+	}
+	if now.Unix() > expires {
+		return fmt.Errorf("link expired")
+	}
+	return nil
+}
+
+func (s *Signer) signature(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
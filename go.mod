@@ -6,6 +6,15 @@ require (
 	github.com/99designs/gqlgen v0.17.5
 	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/prometheus/client_golang v1.12.2
 	github.com/rs/xid v1.4.0
+	github.com/rs/zerolog v1.27.0
 	github.com/vektah/gqlparser/v2 v2.4.2
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	go.opentelemetry.io/proto/otlp v0.16.0
+	google.golang.org/protobuf v1.28.0
 )
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// MaxAttachmentSize is the largest file accepted by attachFile, in bytes.
+// The server bounds multipart requests with it too.
+const MaxAttachmentSize = 10 << 20
+
+// attachmentURLLifetime is how long signed download links stay valid.
+const attachmentURLLifetime = 15 * time.Minute
+
+// attachmentTypes are the content types accepted by attachFile, enough for
+// receipts and screenshots. The type is sniffed from the contents rather
+// than taken from the client.
+var attachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+}
+
+// sniffAttachment detects the content type of an upload and checks that it
+// may be attached. The returned reader yields the complete contents.
+func sniffAttachment(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, fmt.Errorf("failed to read upload, %v", err)
+	}
+	head = head[:n]
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to detect file type, %v", err)
+	}
+	if !attachmentTypes[contentType] {
+		return "", nil, fmt.Errorf("files of type %q may not be attached", contentType)
+	}
+	return contentType, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// attachmentFilename keeps the base name of an uploaded file, as browsers
+// may send full paths, and bounds its length.
+func attachmentFilename(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	for utf8.RuneCountInString(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+func (r *Resolver) attachmentFromRow(row repository.AttachmentRow) *model.Attachment {
+	return &model.Attachment{
+		ID:          row.ID,
+		TodoID:      row.TodoID,
+		Filename:    row.Filename,
+		ContentType: row.ContentType,
+		Size:        int(row.Size),
+		CreatedAt:   row.CreatedAt.Format(datetimeLayout),
+		URL:         r.Signer.URL(row.BlobKey, time.Now().Add(attachmentURLLifetime)),
+	}
+}
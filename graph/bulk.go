@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// maxBulkTodos is the most todos one updateTodos mutation may change.
+const maxBulkTodos = 500
+
+// tagNames trims the tag names of a bulk change, none of which may be empty.
+func tagNames(names []string) ([]string, error) {
+	trimmed := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("tag name must not be empty")
+		}
+		trimmed = append(trimmed, name)
+	}
+	return trimmed, nil
+}
+
+// bulkResult is the result of a bulk change, with each todo it changed read
+// back after it.
+func (r *Resolver) bulkResult(ctx context.Context, operationId string, results []repository.BulkResult) (*model.BulkTodoOperation, error) {
+	var changed []string
+	for _, result := range results {
+		if result.Err == nil {
+			changed = append(changed, result.TodoID)
+		}
+	}
+	rows, err := r.repo(ctx).Primary().TodosByIDs(changed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todos %q, %v", changed, err)
+	}
+	todos := make(map[string]*model.Todo, len(rows))
+	for _, row := range rows {
+		todos[row.ID] = todoFromRow(row)
+	}
+
+	bulk := &model.BulkTodoOperation{OperationID: operationId, Results: make([]*model.TodoResult, 0, len(results))}
+	for _, result := range results {
+		item := &model.TodoResult{ID: result.TodoID, Todo: todos[result.TodoID]}
+		if result.Err != nil {
+			message := result.Err.Error()
+			item.Error = &message
+		}
+		bulk.Results = append(bulk.Results, item)
+	}
+	return bulk, nil
+}
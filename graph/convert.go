@@ -0,0 +1,304 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/chloexu/hackernews/rrule"
+)
+
+// datetimeLayout is the format of Datetime values sent to clients.
+const datetimeLayout = "2006-01-02 15:04:05"
+
+// dayLayout is the format of days, as in per-day statistics.
+const dayLayout = "2006-01-02"
+
+// todoFromRow maps a repository row to its GraphQL model.
+func todoFromRow(row repository.TodoRow) *model.Todo {
+	return &model.Todo{
+		ID:          row.ID,
+		Text:        row.Text,
+		UserID:      row.UserID,
+		Done:        row.Done,
+		CreatedAt:   row.CreatedAt.Format("2006-01-02 15:04:05"),
+		CompletedAt: row.CompletedAt.Format("2006-01-02 15:04:05"),
+		ListID:      optionalString(row.ListID),
+		ParentID:    optionalString(row.ParentID),
+		DueAt:       optionalDatetime(row.DueAt),
+		Priority:    priorityToModel(row.Priority),
+		Recurrence:  recurrenceToModel(row.Recurrence),
+		Position:    optionalString(row.Position),
+	}
+}
+
+func tagFromRow(row repository.TagRow) *model.Tag {
+	return &model.Tag{
+		ID:     row.ID,
+		UserID: row.UserID,
+		Name:   row.Name,
+	}
+}
+
+func todoListFromRow(row repository.TodoListRow) *model.TodoList {
+	return &model.TodoList{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Name:      row.Name,
+		Archived:  row.Archived,
+		CreatedAt: row.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func commentFromRow(row repository.CommentRow) *model.Comment {
+	return &model.Comment{
+		ID:        row.ID,
+		TodoID:    row.TodoID,
+		AuthorID:  row.AuthorID,
+		Body:      row.Body,
+		CreatedAt: row.CreatedAt.Format(datetimeLayout),
+		UpdatedAt: row.UpdatedAt.Format(datetimeLayout),
+	}
+}
+
+func historyFromRow(row repository.HistoryRow) *model.HistoryEntry {
+	changes := make([]*model.FieldChange, 0, len(row.Changes))
+	for _, change := range row.Changes {
+		changes = append(changes, &model.FieldChange{Field: change.Field, Before: change.Before, After: change.After})
+	}
+	return &model.HistoryEntry{
+		ID:          strconv.FormatInt(row.ID, 10),
+		EntityType:  row.EntityType,
+		EntityID:    row.EntityID,
+		ActorID:     optionalString(row.ActorID),
+		OperationID: optionalString(row.OperationID),
+		Action:      row.Action,
+		Changes:     changes,
+		CreatedAt:   row.CreatedAt.Format(datetimeLayout),
+	}
+}
+
+// historyConnection pages history rows fetched with one more row than the
+// page holds, starting at offset.
+func historyConnection(rows []repository.HistoryRow, limit int, offset int) *model.HistoryConnection {
+	connection := &model.HistoryConnection{
+		Edges:    make([]*model.HistoryEdge, 0, limit),
+		PageInfo: &model.PageInfo{HasNextPage: len(rows) > limit},
+	}
+	for i, row := range rows {
+		if i == limit {
+			break
+		}
+		cursor := offsetCursor(offset + i)
+		connection.Edges = append(connection.Edges, &model.HistoryEdge{Entry: historyFromRow(row), Cursor: cursor})
+		connection.PageInfo.EndCursor = &cursor
+	}
+	return connection
+}
+
+func webhookFromRow(row repository.WebhookRow) *model.Webhook {
+	return &model.Webhook{
+		ID:         row.ID,
+		URL:        row.URL,
+		EventTypes: row.EventTypes,
+		CreatedAt:  row.CreatedAt.Format(datetimeLayout),
+	}
+}
+
+func webhookDeliveryFromRow(row repository.WebhookDeliveryRow) *model.WebhookDelivery {
+	delivery := &model.WebhookDelivery{
+		ID:            strconv.FormatInt(row.ID, 10),
+		EventID:       strconv.FormatInt(row.EventID, 10),
+		EventType:     row.EventType,
+		Status:        deliveryStatusToModel(row.Status),
+		Attempts:      row.Attempts,
+		NextAttemptAt: optionalDatetime(row.NextAttemptAt),
+		LastError:     optionalString(row.LastError),
+		CreatedAt:     row.CreatedAt.Format(datetimeLayout),
+		DeliveredAt:   optionalDatetime(row.DeliveredAt),
+	}
+	if row.LastStatusCode != 0 {
+		delivery.LastStatusCode = &row.LastStatusCode
+	}
+	return delivery
+}
+
+// webhookDeliveryConnection pages delivery rows fetched with one more row
+// than the page holds, starting at offset.
+func webhookDeliveryConnection(rows []repository.WebhookDeliveryRow, limit int, offset int) *model.WebhookDeliveryConnection {
+	connection := &model.WebhookDeliveryConnection{
+		Edges:    make([]*model.WebhookDeliveryEdge, 0, limit),
+		PageInfo: &model.PageInfo{HasNextPage: len(rows) > limit},
+	}
+	for i, row := range rows {
+		if i == limit {
+			break
+		}
+		cursor := offsetCursor(offset + i)
+		connection.Edges = append(connection.Edges, &model.WebhookDeliveryEdge{Delivery: webhookDeliveryFromRow(row), Cursor: cursor})
+		connection.PageInfo.EndCursor = &cursor
+	}
+	return connection
+}
+
+func reminderFromRow(row repository.ReminderRow) *model.Reminder {
+	return &model.Reminder{
+		ID:        row.ID,
+		TodoID:    row.TodoID,
+		UserID:    row.UserID,
+		FireAt:    row.FireAt.Format(datetimeLayout),
+		Channel:   reminderChannelToModel(row.Channel),
+		Address:   optionalString(row.Address),
+		Status:    reminderStatusToModel(row.Status),
+		Attempts:  row.Attempts,
+		LastError: optionalString(row.LastError),
+		CreatedAt: row.CreatedAt.Format(datetimeLayout),
+		SentAt:    optionalDatetime(row.SentAt),
+	}
+}
+
+func todoStatsFromRow(row repository.TodoStatsRow) *model.TodoStats {
+	stats := &model.TodoStats{
+		Total:            row.Created,
+		Completed:        row.Done,
+		Open:             row.Created - row.Done,
+		CompletionsByDay: make([]*model.DayCount, 0, len(row.Completions)),
+	}
+	if len(row.Completions) > 0 {
+		seconds := row.AvgCompletion.Seconds()
+		stats.AverageCompletionSeconds = &seconds
+	}
+	for _, bucket := range row.Completions {
+		stats.CompletionsByDay = append(stats.CompletionsByDay, &model.DayCount{Day: bucket.Day.Format(dayLayout), Count: bucket.Count})
+	}
+	return stats
+}
+
+// optionalString maps the empty strings used by the repository for missing
+// values to null.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// parseDatetime reads a Datetime argument in either the layout the API
+// returns or RFC 3339.
+func parseDatetime(value string) (time.Time, error) {
+	if t, err := time.Parse(datetimeLayout, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid Datetime %q, want %q or RFC 3339", value, datetimeLayout)
+	}
+	return t, nil
+}
+
+func optionalDatetime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	formatted := t.Format(datetimeLayout)
+	return &formatted
+}
+
+var priorities = map[repository.Priority]model.Priority{
+	repository.PriorityLow:    model.PriorityLow,
+	repository.PriorityMedium: model.PriorityMedium,
+	repository.PriorityHigh:   model.PriorityHigh,
+	repository.PriorityUrgent: model.PriorityUrgent,
+}
+
+func priorityToModel(p repository.Priority) model.Priority {
+	if priority, ok := priorities[p]; ok {
+		return priority
+	}
+	return model.PriorityMedium
+}
+
+func priorityFromModel(p model.Priority) repository.Priority {
+	for priority, name := range priorities {
+		if name == p {
+			return priority
+		}
+	}
+	return repository.PriorityMedium
+}
+
+func roleToModel(role repository.Role) model.Role {
+	if role == repository.RoleEditor {
+		return model.RoleEditor
+	}
+	return model.RoleViewer
+}
+
+func roleFromModel(role model.Role) repository.Role {
+	if role == model.RoleEditor {
+		return repository.RoleEditor
+	}
+	return repository.RoleViewer
+}
+
+var deliveryStatuses = map[repository.DeliveryStatus]model.DeliveryStatus{
+	repository.DeliveryPending:   model.DeliveryStatusPending,
+	repository.DeliveryDelivered: model.DeliveryStatusDelivered,
+	repository.DeliveryDead:      model.DeliveryStatusDead,
+}
+
+func deliveryStatusToModel(status repository.DeliveryStatus) model.DeliveryStatus {
+	return deliveryStatuses[status]
+}
+
+func deliveryStatusFromModel(status model.DeliveryStatus) repository.DeliveryStatus {
+	for s, name := range deliveryStatuses {
+		if name == status {
+			return s
+		}
+	}
+	return ""
+}
+
+var reminderChannels = map[repository.ReminderChannel]model.ReminderChannel{
+	repository.ChannelEmail: model.ReminderChannelEmail,
+	repository.ChannelLog:   model.ReminderChannelLog,
+}
+
+func reminderChannelToModel(channel repository.ReminderChannel) model.ReminderChannel {
+	return reminderChannels[channel]
+}
+
+func reminderChannelFromModel(channel model.ReminderChannel) repository.ReminderChannel {
+	for c, name := range reminderChannels {
+		if name == channel {
+			return c
+		}
+	}
+	return repository.ChannelLog
+}
+
+var reminderStatuses = map[repository.ReminderStatus]model.ReminderStatus{
+	repository.ReminderPending:   model.ReminderStatusPending,
+	repository.ReminderSent:      model.ReminderStatusSent,
+	repository.ReminderFailed:    model.ReminderStatusFailed,
+	repository.ReminderCancelled: model.ReminderStatusCancelled,
+}
+
+func reminderStatusToModel(status repository.ReminderStatus) model.ReminderStatus {
+	return reminderStatuses[status]
+}
+
+// recurrenceToModel shows the stored rule without its DTSTART, which follows
+// the due date of the todo.
+func recurrenceToModel(recurrence string) *string {
+	if recurrence == "" {
+		return nil
+	}
+	if rule, err := rrule.Parse(recurrence); err == nil {
+		recurrence = rule.RRule()
+	}
+	return &recurrence
+}
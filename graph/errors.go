@@ -0,0 +1,35 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/chloexu/hackernews/repository/retry"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CodeUnavailable is the extensions code of errors of requests failed fast
+// while the database is unavailable. Clients may retry them later.
+const CodeUnavailable = "UNAVAILABLE"
+
+// ErrorPresenter is the default presenter, adding the code UNAVAILABLE to
+// the errors caused by retry.ErrUnavailable.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+	if unavailable(err) {
+		if presented.Extensions == nil {
+			presented.Extensions = map[string]interface{}{}
+		}
+		presented.Extensions["code"] = CodeUnavailable
+	}
+	return presented
+}
+
+// unavailable reports whether err was caused by retry.ErrUnavailable. The
+// resolvers format the errors they wrap with %v, so the message is checked
+// too.
+func unavailable(err error) bool {
+	return errors.Is(err, retry.ErrUnavailable) || strings.Contains(err.Error(), retry.ErrUnavailable.Error())
+}
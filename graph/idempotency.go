@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// DefaultIdempotencyTTL is how long the result of a mutation made with an
+// idempotency key is kept when Resolver.IdempotencyTTL is not set.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength is the longest idempotency key accepted, in bytes.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLease is how long a mutation may run holding its idempotency
+// key. A key held longer without a result belongs to a mutation that never
+// finished, such as one whose server crashed, and a retry runs it again.
+const idempotencyLease = 5 * time.Minute
+
+type idempotencyKeyContextKey struct{}
+
+// IdempotencyKeys passes the Idempotency-Key header of a request on to the
+// mutations it runs.
+func IdempotencyKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if key := req.Header.Get("Idempotency-Key"); key != "" {
+			req = req.WithContext(context.WithValue(req.Context(), idempotencyKeyContextKey{}, key))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// idempotencyKey returns the key of the running mutation: its
+// clientMutationId, or else the Idempotency-Key header combined with the
+// path of the mutation, so that the mutations of one request differ.
+func idempotencyKey(ctx context.Context, clientMutationId *string) string {
+	if clientMutationId != nil && *clientMutationId != "" {
+		return *clientMutationId
+	}
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	if key == "" {
+		return ""
+	}
+	return key + " " + graphql.GetFieldContext(ctx).Path().String()
+}
+
+// idempotentOperation runs a mutation of userId that returns a
+// TodoOperation, at most once per idempotency key. When the key was used
+// before, the result of that first run is returned instead.
+func (r *Resolver) idempotentOperation(ctx context.Context, userId string, clientMutationId *string, mutate func() (*model.TodoOperation, error)) (*model.TodoOperation, error) {
+	key := idempotencyKey(ctx, clientMutationId)
+	if key == "" {
+		return mutate()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("idempotency key must be at most %d bytes", maxIdempotencyKeyLength)
+	}
+	operation := graphql.GetFieldContext(ctx).Field.Name
+	ttl := r.IdempotencyTTL
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	now := time.Now()
+	reserved, err := r.repo(ctx).ReserveIdempotencyKey(repository.IdempotencyRow{
+		UserID: userId, Key: key, Operation: operation, CreatedAt: now, ExpiresAt: now.Add(ttl),
+	}, idempotencyLease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key %q, %v", key, err)
+	}
+	if !reserved {
+		return r.replayedOperation(ctx, userId, key, operation)
+	}
+
+	result, err := mutate()
+	if err != nil {
+		// the mutation did not happen, so the client may retry it
+		if _, releaseErr := r.repo(ctx).ReleaseIdempotencyKey(userId, key); releaseErr != nil {
+			logging.FromContext(ctx).Error().Err(releaseErr).Str("key", key).Msg("failed to release idempotency key")
+		}
+		return nil, err
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result for idempotency key %q, %v", key, err)
+	}
+	if _, err := r.repo(ctx).SaveIdempotencyResult(userId, key, encoded); err != nil {
+		// the mutation is done, only a retry of it will fail
+		logging.FromContext(ctx).Error().Err(err).Str("key", key).Msg("failed to save result for idempotency key")
+	}
+	return result, nil
+}
+
+// replayedOperation returns the stored result of the mutation that used key
+// first.
+func (r *Resolver) replayedOperation(ctx context.Context, userId string, key string, operation string) (*model.TodoOperation, error) {
+	row, err := r.repo(ctx).IdempotencyKey(userId, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key %q, %v", key, err)
+	}
+	if row.Operation != operation {
+		return nil, fmt.Errorf("idempotency key %q was used for %s", key, row.Operation)
+	}
+	if row.Result == nil {
+		return nil, fmt.Errorf("the mutation with idempotency key %q is still running", key)
+	}
+	var result model.TodoOperation
+	if err := json.Unmarshal(row.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result for idempotency key %q, %v", key, err)
+	}
+	return &result, nil
+}
@@ -2,23 +2,690 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type AddCommentInput struct {
+	TodoID   string `json:"todoId"`
+	AuthorID string `json:"authorId"`
+	Body     string `json:"body"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	TodoID      string `json:"todoId"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	// size in bytes
+	Size      int    `json:"size"`
+	CreatedAt string `json:"createdAt"`
+	// signed download link, valid for a limited time
+	URL string `json:"url"`
+}
+
+// the result of a bulk change, which can be undone as a whole
+type BulkTodoOperation struct {
+	OperationID string        `json:"operationId"`
+	Results     []*TodoResult `json:"results"`
+}
+
+// a change made by a client while offline; omitted fields are left as they are
+type ChangeInput struct {
+	Kind ChangeKind `json:"kind"`
+	// chosen by the client on create, an xid or a UUID
+	ID string `json:"id"`
+	// the version of the todo the change was made to; required unless creating
+	BaseVersion *string   `json:"baseVersion"`
+	Text        *string   `json:"text"`
+	Done        *bool     `json:"done"`
+	Priority    *Priority `json:"priority"`
+	// an empty dueAt clears the due date
+	DueAt  *string `json:"dueAt"`
+	ListID *string `json:"listId"`
+}
+
+// a user a todo or list is shared with
+type Collaborator struct {
+	UserID    string `json:"userId"`
+	Role      Role   `json:"role"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type Comment struct {
+	ID        string `json:"id"`
+	TodoID    string `json:"todoId"`
+	AuthorID  string `json:"authorId"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type CommentConnection struct {
+	Edges    []*CommentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type CommentEdge struct {
+	Comment *Comment `json:"comment"`
+	Cursor  string   `json:"cursor"`
+}
+
 type CreateTodoInput struct {
-	Text   string `json:"text"`
+	// an id chosen by the client, an xid or a UUID; generated when omitted
+	ID         *string   `json:"id"`
+	Text       string    `json:"text"`
+	UserID     string    `json:"userId"`
+	Done       *bool     `json:"done"`
+	ListID     *string   `json:"listId"`
+	ParentID   *string   `json:"parentId"`
+	DueAt      *string   `json:"dueAt"`
+	Priority   *Priority `json:"priority"`
+	Recurrence *string   `json:"recurrence"`
+	// a key for retries: a createTodo repeated with the same key returns the first result instead of creating another todo
+	ClientMutationID *string `json:"clientMutationId"`
+}
+
+type CreateTodoListInput struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+}
+
+type DayCount struct {
+	// the day, formatted 2006-01-02
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+type EditCommentInput struct {
+	ID string `json:"id"`
+	// the user making the change, either the author or the owner of the todo
+	UserID string `json:"userId"`
+	Body   string `json:"body"`
+}
+
+// the value of a field before and after a change; null when it was not set
+type FieldChange struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+type HistoryConnection struct {
+	Edges    []*HistoryEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type HistoryEdge struct {
+	Entry  *HistoryEntry `json:"entry"`
+	Cursor string        `json:"cursor"`
+}
+
+// a change recorded in the audit log
+type HistoryEntry struct {
+	ID string `json:"id"`
+	// todo, list or tag
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	// the user who made the change, if known
+	ActorID *string `json:"actorId"`
+	// the operation the change belongs to, for changes that can be undone
+	OperationID *string `json:"operationId"`
+	// create, update, delete, move, complete, tag, untag, share, unshare, attach, undo or redo
+	Action    string         `json:"action"`
+	Changes   []*FieldChange `json:"changes"`
+	CreatedAt string         `json:"createdAt"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+type Progress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// a reminder of a todo, sent to its user at fireAt
+type Reminder struct {
+	ID      string          `json:"id"`
+	TodoID  string          `json:"todoId"`
+	UserID  string          `json:"userId"`
+	FireAt  string          `json:"fireAt"`
+	Channel ReminderChannel `json:"channel"`
+	// where the reminder is sent, the email address for EMAIL
+	Address   *string        `json:"address"`
+	Status    ReminderStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	LastError *string        `json:"lastError"`
+	CreatedAt string         `json:"createdAt"`
+	SentAt    *string        `json:"sentAt"`
+}
+
+// the outcome of one change of a sync
+type SyncChangeResult struct {
+	ID     string           `json:"id"`
+	Status SyncChangeStatus `json:"status"`
+	// why the change was not applied
+	Error *string `json:"error"`
+}
+
+// a change of a sync that was not applied
+type SyncConflict struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+	// the version on the server, null when the todo is deleted or not visible to the user
+	Version *string `json:"version"`
+	// the todo on the server, null along with version
+	Todo *Todo `json:"todo"`
+}
+
+type SyncResult struct {
+	// undoes the changes of the sync that were applied
+	OperationID string `json:"operationId"`
+	// one per change sent, in the same order
+	Results []*SyncChangeResult `json:"results"`
+	// the todos changed on the server since the cursor, oldest change first
+	Changes   []*SyncedTodo   `json:"changes"`
+	Conflicts []*SyncConflict `json:"conflicts"`
+	// pass as since to the next sync
+	Cursor string `json:"cursor"`
+	// whether more changes follow the cursor
+	HasMore bool `json:"hasMore"`
+}
+
+// a todo as of its latest change, for clients syncing their copies
+type SyncedTodo struct {
+	ID string `json:"id"`
+	// pass as the baseVersion of a change to the todo
+	Version string `json:"version"`
+	Deleted bool   `json:"deleted"`
+	// null when deleted
+	Todo *Todo `json:"todo"`
+}
+
+type Tag struct {
+	ID     string `json:"id"`
 	UserID string `json:"userId"`
-	Done   *bool  `json:"done"`
+	Name   string `json:"name"`
 }
 
 type Todo struct {
-	ID          string `json:"id"`
-	Text        string `json:"text"`
-	Done        bool   `json:"done"`
-	UserID      string `json:"userId"`
-	CreatedAt   string `json:"createdAt"`
-	CompletedAt string `json:"completedAt"`
+	ID          string    `json:"id"`
+	Text        string    `json:"text"`
+	Done        bool      `json:"done"`
+	UserID      string    `json:"userId"`
+	CreatedAt   string    `json:"createdAt"`
+	CompletedAt string    `json:"completedAt"`
+	Tags        []*Tag    `json:"tags"`
+	ListID      *string   `json:"listId"`
+	List        *TodoList `json:"list"`
+	ParentID    *string   `json:"parentId"`
+	// null unless userId may view the parent
+	Parent *Todo `json:"parent"`
+	// the subtasks userId may view
+	Children []*Todo   `json:"children"`
+	Progress *Progress `json:"progress"`
+	DueAt    *string   `json:"dueAt"`
+	Priority Priority  `json:"priority"`
+	// RFC 5545 recurrence rule, e.g. FREQ=WEEKLY;BYDAY=MO
+	Recurrence *string `json:"recurrence"`
+	// opaque key that orders the todos of a user
+	Position *string `json:"position"`
+	// comments on the todo, oldest first; userId must be able to view the todo
+	Comments *CommentConnection `json:"comments"`
+	// userId must be able to view the todo
+	Attachments []*Attachment `json:"attachments"`
+	// userId must be able to view the todo
+	Collaborators []*Collaborator `json:"collaborators"`
+	// changes to the todo, newest first; userId must be able to view the todo
+	History *HistoryConnection `json:"history"`
+}
+
+// selects todos; omitted fields match every todo
+type TodoFilter struct {
+	ListID *string `json:"listId"`
+	// the todos carrying every one of these tags
+	Tags      []string `json:"tags"`
+	DueBefore *string  `json:"dueBefore"`
+}
+
+type TodoList struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Name      string `json:"name"`
+	Archived  bool   `json:"archived"`
+	CreatedAt string `json:"createdAt"`
+	// userId must be able to view the list
+	Todos []*Todo `json:"todos"`
+	// userId must be able to view the list
+	Collaborators []*Collaborator `json:"collaborators"`
+}
+
+// the result of a change that can be undone
+type TodoOperation struct {
+	// pass to undo to revert the change, and to redo to apply it again
+	OperationID string `json:"operationId"`
+	// the todo after the change, null when it is deleted
+	Todo *Todo `json:"todo"`
+}
+
+// a change applied to many todos; omitted fields are left as they are
+type TodoPatch struct {
+	Done     *bool     `json:"done"`
+	Priority *Priority `json:"priority"`
+	// tag names; tags the owner of a todo does not have yet are created
+	AddTags    []string `json:"addTags"`
+	RemoveTags []string `json:"removeTags"`
+}
+
+// the outcome of a bulk change for one todo
+type TodoResult struct {
+	ID string `json:"id"`
+	// the todo after the change, null when it failed
+	Todo *Todo `json:"todo"`
+	// why the todo was left as it is
+	Error *string `json:"error"`
+}
+
+type TodoSearchConnection struct {
+	Edges    []*TodoSearchResult `json:"edges"`
+	PageInfo *PageInfo           `json:"pageInfo"`
+}
+
+type TodoSearchResult struct {
+	Todo *Todo `json:"todo"`
+	// relevance of the match, higher is better
+	Score float64 `json:"score"`
+	// HTML escaped excerpt of the text with the matched words wrapped in <mark>
+	Snippet string `json:"snippet"`
+	Cursor  string `json:"cursor"`
+}
+
+// aggregates of the todos visible to a user over a time window
+type TodoStats struct {
+	// todos created in the window
+	Total int `json:"total"`
+	// todos created in the window that are done
+	Completed int `json:"completed"`
+	// todos created in the window that are still open
+	Open int `json:"open"`
+	// mean seconds from creation to completion of the todos completed in the window, null when none were
+	AverageCompletionSeconds *float64 `json:"averageCompletionSeconds"`
+	// todos completed in the window per day, leaving out days without any
+	CompletionsByDay []*DayCount `json:"completionsByDay"`
 }
 
 type UpdateTodoInput struct {
-	ID   string  `json:"id"`
-	Text *string `json:"text"`
-	Done bool    `json:"done"`
+	ID string `json:"id"`
+	// the user making the change, the owner or an editor of the todo
+	UserID           string    `json:"userId"`
+	Text             *string   `json:"text"`
+	Done             bool      `json:"done"`
+	CompleteChildren *bool     `json:"completeChildren"`
+	DueAt            *string   `json:"dueAt"`
+	ClearDueAt       *bool     `json:"clearDueAt"`
+	Priority         *Priority `json:"priority"`
+	// an empty rule stops the todo from repeating
+	Recurrence *string `json:"recurrence"`
+	// a key for retries, as for createTodo
+	ClientMutationID *string `json:"clientMutationId"`
+}
+
+type UpdateTodoListInput struct {
+	ID string `json:"id"`
+	// the user making the change; editors may rename a list, only its owner may archive it
+	UserID   string  `json:"userId"`
+	Name     *string `json:"name"`
+	Archived *bool   `json:"archived"`
+}
+
+// receives signed posts of the events of the todos, lists and tags of a user
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// event types such as todo.complete, an entity type and an action
+	EventTypes []string `json:"eventTypes"`
+	CreatedAt  string   `json:"createdAt"`
+	// the delivery log, newest first
+	Deliveries *WebhookDeliveryConnection `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	ID string `json:"id"`
+	// the event id, for receivers to skip deliveries they have seen
+	EventID   string         `json:"eventId"`
+	EventType string         `json:"eventType"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	// null unless pending
+	NextAttemptAt *string `json:"nextAttemptAt"`
+	// the status of the last response, null when there was none
+	LastStatusCode *int    `json:"lastStatusCode"`
+	LastError      *string `json:"lastError"`
+	CreatedAt      string  `json:"createdAt"`
+	DeliveredAt    *string `json:"deliveredAt"`
+}
+
+type WebhookDeliveryConnection struct {
+	Edges    []*WebhookDeliveryEdge `json:"edges"`
+	PageInfo *PageInfo              `json:"pageInfo"`
+}
+
+type WebhookDeliveryEdge struct {
+	Delivery *WebhookDelivery `json:"delivery"`
+	Cursor   string           `json:"cursor"`
+}
+
+type ChangeKind string
+
+const (
+	ChangeKindCreate ChangeKind = "CREATE"
+	ChangeKindUpdate ChangeKind = "UPDATE"
+	ChangeKindDelete ChangeKind = "DELETE"
+)
+
+var AllChangeKind = []ChangeKind{
+	ChangeKindCreate,
+	ChangeKindUpdate,
+	ChangeKindDelete,
+}
+
+func (e ChangeKind) IsValid() bool {
+	switch e {
+	case ChangeKindCreate, ChangeKindUpdate, ChangeKindDelete:
+		return true
+	}
+	return false
+}
+
+func (e ChangeKind) String() string {
+	return string(e)
+}
+
+func (e *ChangeKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ChangeKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ChangeKind", str)
+	}
+	return nil
+}
+
+func (e ChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// DEAD deliveries ran out of attempts
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "PENDING"
+	DeliveryStatusDelivered DeliveryStatus = "DELIVERED"
+	DeliveryStatusDead      DeliveryStatus = "DEAD"
+)
+
+var AllDeliveryStatus = []DeliveryStatus{
+	DeliveryStatusPending,
+	DeliveryStatusDelivered,
+	DeliveryStatusDead,
+}
+
+func (e DeliveryStatus) IsValid() bool {
+	switch e {
+	case DeliveryStatusPending, DeliveryStatusDelivered, DeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e DeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *DeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e DeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "LOW"
+	PriorityMedium Priority = "MEDIUM"
+	PriorityHigh   Priority = "HIGH"
+	PriorityUrgent Priority = "URGENT"
+)
+
+var AllPriority = []Priority{
+	PriorityLow,
+	PriorityMedium,
+	PriorityHigh,
+	PriorityUrgent,
+}
+
+func (e Priority) IsValid() bool {
+	switch e {
+	case PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+func (e Priority) String() string {
+	return string(e)
+}
+
+func (e *Priority) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Priority(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Priority", str)
+	}
+	return nil
+}
+
+func (e Priority) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// LOG writes reminders to the server log, for development
+type ReminderChannel string
+
+const (
+	ReminderChannelEmail ReminderChannel = "EMAIL"
+	ReminderChannelLog   ReminderChannel = "LOG"
+)
+
+var AllReminderChannel = []ReminderChannel{
+	ReminderChannelEmail,
+	ReminderChannelLog,
+}
+
+func (e ReminderChannel) IsValid() bool {
+	switch e {
+	case ReminderChannelEmail, ReminderChannelLog:
+		return true
+	}
+	return false
+}
+
+func (e ReminderChannel) String() string {
+	return string(e)
+}
+
+func (e *ReminderChannel) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReminderChannel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReminderChannel", str)
+	}
+	return nil
+}
+
+func (e ReminderChannel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// FAILED reminders ran out of attempts, and CANCELLED ones were of a todo done or
+// deleted by the time they fired
+type ReminderStatus string
+
+const (
+	ReminderStatusPending   ReminderStatus = "PENDING"
+	ReminderStatusSent      ReminderStatus = "SENT"
+	ReminderStatusFailed    ReminderStatus = "FAILED"
+	ReminderStatusCancelled ReminderStatus = "CANCELLED"
+)
+
+var AllReminderStatus = []ReminderStatus{
+	ReminderStatusPending,
+	ReminderStatusSent,
+	ReminderStatusFailed,
+	ReminderStatusCancelled,
+}
+
+func (e ReminderStatus) IsValid() bool {
+	switch e {
+	case ReminderStatusPending, ReminderStatusSent, ReminderStatusFailed, ReminderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e ReminderStatus) String() string {
+	return string(e)
+}
+
+func (e *ReminderStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReminderStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReminderStatus", str)
+	}
+	return nil
+}
+
+func (e ReminderStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleViewer Role = "VIEWER"
+	RoleEditor Role = "EDITOR"
+)
+
+var AllRole = []Role{
+	RoleViewer,
+	RoleEditor,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleViewer, RoleEditor:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// APPLIED changes are on the server. CONFLICT changes are listed in conflicts.
+// A FAILED change may be partly applied, as its todo in changes shows, and the
+// changes after it are SKIPPED; both may be sent again.
+type SyncChangeStatus string
+
+const (
+	SyncChangeStatusApplied  SyncChangeStatus = "APPLIED"
+	SyncChangeStatusConflict SyncChangeStatus = "CONFLICT"
+	SyncChangeStatusFailed   SyncChangeStatus = "FAILED"
+	SyncChangeStatusSkipped  SyncChangeStatus = "SKIPPED"
+)
+
+var AllSyncChangeStatus = []SyncChangeStatus{
+	SyncChangeStatusApplied,
+	SyncChangeStatusConflict,
+	SyncChangeStatusFailed,
+	SyncChangeStatusSkipped,
+}
+
+func (e SyncChangeStatus) IsValid() bool {
+	switch e {
+	case SyncChangeStatusApplied, SyncChangeStatusConflict, SyncChangeStatusFailed, SyncChangeStatusSkipped:
+		return true
+	}
+	return false
+}
+
+func (e SyncChangeStatus) String() string {
+	return string(e)
+}
+
+func (e *SyncChangeStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SyncChangeStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SyncChangeStatus", str)
+	}
+	return nil
+}
+
+func (e SyncChangeStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/rs/xid"
+)
+
+// newOperation starts an operation of userId: the writes made through the
+// returned repository can be undone together by passing the returned id to
+// the undo mutation. Its reads go to the primary.
+func (r *Resolver) newOperation(ctx context.Context, userId string) (string, repository.Repository) {
+	operationId := xid.New().String()
+	return operationId, r.repo(ctx).Primary().WithActor(userId).WithOperation(operationId)
+}
+
+// operationOf returns the history entries of an operation after checking
+// that userId made it.
+func (r *Resolver) operationOf(ctx context.Context, userId string, operationId string) ([]repository.HistoryRow, error) {
+	entries, err := r.repo(ctx).Primary().OperationHistory(operationId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation %q, %v", operationId, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no operation %q", operationId)
+	}
+	if entries[0].ActorID != userId {
+		return nil, fmt.Errorf("operation %q was not made by user %q", operationId, userId)
+	}
+	return entries, nil
+}
+
+// operationResult is the result of undoing, when undone is set, or redoing an
+// operation with the given entries. Its todo is the first one the operation
+// changed, unless that is deleted afterwards. Operations tagging todos may
+// create tags before changing any todo.
+func (r *Resolver) operationResult(ctx context.Context, operationId string, entries []repository.HistoryRow, undone bool) (*model.TodoOperation, error) {
+	result := &model.TodoOperation{OperationID: operationId}
+	var todoId, first, last string
+	for _, entry := range entries {
+		if entry.EntityType != "todo" || entry.Action == repository.ActionUndo || entry.Action == repository.ActionRedo {
+			continue
+		}
+		if todoId == "" {
+			todoId = entry.EntityID
+		}
+		if entry.EntityID != todoId {
+			continue
+		}
+		if first == "" {
+			first = entry.Action
+		}
+		last = entry.Action
+	}
+	if todoId == "" || undone && first == repository.ActionCreate || !undone && last == repository.ActionDelete {
+		return result, nil
+	}
+	row, err := r.repo(ctx).Primary().TodoByID(todoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo %q, %v", todoId, err)
+	}
+	result.Todo = todoFromRow(row)
+	return result, nil
+}
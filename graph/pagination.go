@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultPageSize and maxPageSize bound the first argument of paginated
+// queries.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+const offsetCursorPrefix = "offset:"
+
+// pageSize validates the first argument of a paginated query.
+func pageSize(first *int) (int, error) {
+	if first == nil {
+		return defaultPageSize, nil
+	}
+	if *first < 0 || *first > maxPageSize {
+		return 0, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+	}
+	return *first, nil
+}
+
+// offsetCursor is the opaque cursor of the item at offset in a result list.
+func offsetCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(offsetCursorPrefix + strconv.Itoa(offset)))
+}
+
+// offsetAfter returns the offset of the first item after cursor, or 0 when
+// there is no cursor.
+func offsetAfter(after *string) (int, error) {
+	if after == nil || *after == "" {
+		return 0, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(*after)
+	if err != nil || !strings.HasPrefix(string(decoded), offsetCursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", *after)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(decoded), offsetCursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", *after)
+	}
+	return offset + 1, nil
+}
@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+	"github.com/chloexu/hackernews/rrule"
+	"github.com/rs/xid"
+)
+
+// maxOccurrences caps how far ahead upcomingOccurrences looks.
+const maxOccurrences = 100
+
+// newRecurrence validates a rule sent by a client and anchors it at the due
+// date of the todo, or at now for todos without one. It returns the rule to
+// store and the due date of the todo.
+func newRecurrence(text string, dueAt time.Time, now time.Time) (string, time.Time, error) {
+	rule, err := rrule.Parse(text)
+	if err != nil {
+		return "", dueAt, err
+	}
+	if !dueAt.IsZero() {
+		rule.Start = dueAt
+		return rule.String(), dueAt, nil
+	}
+	rule.Start = now.Truncate(time.Second)
+	first, ok := rule.Next(rule.Start.Add(-time.Second))
+	if !ok {
+		return "", dueAt, fmt.Errorf("recurrence %q has no occurrences", text)
+	}
+	return rule.String(), first, nil
+}
+
+// scheduleNextOccurrence creates the todo for the occurrence after the one
+// that was just completed and hands the rule over to it. The completed todo
+// stops repeating, so completing it again does not create another copy. The
+// writes go through repo so that they are recorded as made by the same user.
+func (r *Resolver) scheduleNextOccurrence(ctx context.Context, repo repository.Repository, completed repository.TodoRow) error {
+	row, err := r.nextOccurrence(ctx, completed)
+	if err != nil {
+		return err
+	}
+	if row != nil {
+		if _, err := repo.AddTodo(*row); err != nil {
+			return fmt.Errorf("failed to add next occurrence of todo %q, %v", completed.ID, err)
+		}
+		tags, err := repo.TagsByTodo(completed.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get tags of todo %q, %v", completed.ID, err)
+		}
+		for _, tag := range tags {
+			if _, err := repo.AddTagToTodo(row.ID, tag.ID); err != nil {
+				return fmt.Errorf("failed to tag next occurrence of todo %q, %v", completed.ID, err)
+			}
+		}
+	}
+	if _, err := repo.SetTodoRecurrence(completed.ID, ""); err != nil {
+		return fmt.Errorf("failed to end recurrence of todo %q, %v", completed.ID, err)
+	}
+	return nil
+}
+
+// nextOccurrence returns the todo for the occurrence after completed, or nil
+// when the rule has no more.
+func (r *Resolver) nextOccurrence(ctx context.Context, completed repository.TodoRow) (*repository.TodoRow, error) {
+	rule, err := rrule.Parse(completed.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence of todo %q, %v", completed.ID, err)
+	}
+	current := completed.DueAt
+	if current.IsZero() {
+		current = rule.Start
+	}
+	next, ok := rule.Next(current)
+	if !ok {
+		return nil, nil
+	}
+	position, err := r.appendPosition(ctx, completed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.TodoRow{
+		ID:         xid.New().String(),
+		Text:       completed.Text,
+		UserID:     completed.UserID,
+		ListID:     completed.ListID,
+		ParentID:   completed.ParentID,
+		DueAt:      next,
+		Priority:   completed.Priority,
+		Recurrence: completed.Recurrence,
+		Position:   position,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// reminderAddress checks the address a reminder is sent to through channel.
+// Only EMAIL reminders have one.
+func reminderAddress(channel model.ReminderChannel, address *string) (string, error) {
+	if channel != model.ReminderChannelEmail {
+		if address != nil && strings.TrimSpace(*address) != "" {
+			return "", fmt.Errorf("%s reminders take no address", channel)
+		}
+		return "", nil
+	}
+	if address == nil || strings.TrimSpace(*address) == "" {
+		return "", fmt.Errorf("EMAIL reminders need an address")
+	}
+	parsed, err := mail.ParseAddress(strings.TrimSpace(*address))
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q, %v", *address, err)
+	}
+	return parsed.Address, nil
+}
+
+// reminderOf returns the reminder with the given id after checking that it
+// belongs to userId.
+func (r *Resolver) reminderOf(userId string, reminderId string) (repository.ReminderRow, error) {
+	reminder, err := r.ReminderRepo.ReminderByID(reminderId)
+	if err != nil {
+		return reminder, fmt.Errorf("failed to get reminder %q, %v", reminderId, err)
+	}
+	if reminder.UserID != userId {
+		return reminder, fmt.Errorf("reminder %q does not belong to user %q", reminderId, userId)
+	}
+	return reminder, nil
+}
@@ -1,6 +1,14 @@
 package graph
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chloexu/hackernews/blob"
+	"github.com/chloexu/hackernews/rank"
 	"github.com/chloexu/hackernews/repository"
 )
 
@@ -8,7 +16,113 @@ import (
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
+// snippetWidth is the length, in characters, of search result snippets.
+const snippetWidth = 80
+
+// maxCommentLength is the longest comment body accepted, in characters.
+const maxCommentLength = 10000
+
 type Resolver struct {
 	// TodoStore map[string]model.Todo
-	Repo repository.Repository
+	Repo         repository.Repository
+	CommentRepo  repository.CommentRepository
+	WebhookRepo  repository.WebhookRepository
+	ReminderRepo repository.ReminderRepository
+	// Blobs keeps the contents of attachments, and Signer makes the links
+	// to download them.
+	Blobs  blob.Store
+	Signer *blob.Signer
+	// IdempotencyTTL is how long the results of mutations made with an
+	// idempotency key are kept, DefaultIdempotencyTTL when zero.
+	IdempotencyTTL time.Duration
+}
+
+// repo returns the repository serving the request of ctx. Mutations read
+// through its Primary, so that they act on the writes before them rather
+// than on a stale replica or cache entry.
+func (r *Resolver) repo(ctx context.Context) repository.Repository {
+	return r.Repo.WithContext(ctx)
+}
+
+// openListOf returns the list with the given id after checking that todos of
+// userId may be put into it, as its owner or an editor.
+func (r *Resolver) openListOf(ctx context.Context, userId string, listId string) (repository.TodoListRow, error) {
+	list, err := r.repo(ctx).Primary().TodoListByID(listId)
+	if err != nil {
+		return list, fmt.Errorf("failed to get list %q, %v", listId, err)
+	}
+	if err := r.listAccess(ctx, userId, list, repository.RoleEditor); err != nil {
+		return list, err
+	}
+	if list.Archived {
+		return list, fmt.Errorf("list %q is archived", listId)
+	}
+	return list, nil
+}
+
+// parentFor returns the todo with the given id after checking that it may
+// become a parent of a todo owned by userId.
+func (r *Resolver) parentFor(ctx context.Context, userId string, parentId string) (repository.TodoRow, error) {
+	parent, err := r.repo(ctx).Primary().TodoByID(parentId)
+	if err != nil {
+		return parent, fmt.Errorf("failed to get parent todo %q, %v", parentId, err)
+	}
+	if parent.UserID != userId {
+		return parent, fmt.Errorf("parent todo %q does not belong to user %q", parentId, userId)
+	}
+	return parent, nil
+}
+
+// appendPosition returns a position after all todos of the user.
+func (r *Resolver) appendPosition(ctx context.Context, userId string) (string, error) {
+	last, err := r.repo(ctx).Primary().LastTodoPosition(userId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get last position of user %q, %v", userId, err)
+	}
+	return rank.Between(last, "")
+}
+
+// positionOf returns the position of a todo of userId that another todo is
+// being moved next to.
+func (r *Resolver) positionOf(ctx context.Context, userId string, todoId string) (string, error) {
+	neighbour, err := r.repo(ctx).Primary().TodoByID(todoId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get todo %q, %v", todoId, err)
+	}
+	if neighbour.UserID != userId {
+		return "", fmt.Errorf("todo %q does not belong to user %q", todoId, userId)
+	}
+	return neighbour.Position, nil
+}
+
+// commentBody validates and trims the body of a new or edited comment.
+func commentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("comment body must not be empty")
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return "", fmt.Errorf("comment body must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// commentEditableBy returns the comment with the given id after checking that
+// userId, as its author or as the owner of its todo, may change it.
+func (r *Resolver) commentEditableBy(ctx context.Context, userId string, commentId string) (repository.CommentRow, error) {
+	comment, err := r.CommentRepo.CommentByID(commentId)
+	if err != nil {
+		return comment, fmt.Errorf("failed to get comment %q, %v", commentId, err)
+	}
+	if comment.AuthorID == userId {
+		return comment, nil
+	}
+	todo, err := r.repo(ctx).Primary().TodoByID(comment.TodoID)
+	if err != nil {
+		return comment, fmt.Errorf("failed to get todo %q, %v", comment.TodoID, err)
+	}
+	if todo.UserID != userId {
+		return comment, fmt.Errorf("user %q may not change comment %q", userId, commentId)
+	}
+	return comment, nil
 }
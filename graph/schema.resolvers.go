@@ -6,126 +6,888 @@ package graph
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/chloexu/hackernews/graph/generated"
 	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/rank"
 	"github.com/chloexu/hackernews/repository"
+	"github.com/chloexu/hackernews/rrule"
+	"github.com/chloexu/hackernews/search"
 	"github.com/rs/xid"
 )
 
-func (r *mutationResolver) CreateTodo(ctx context.Context, input model.CreateTodoInput) (*model.Todo, error) {
-	// n := len(r.Resolver.TodoStore)
-	// if n == 0 {
-	// 	r.Resolver.TodoStore = make(map[string]model.Todo)
-	// }
+func (r *mutationResolver) CreateTodo(ctx context.Context, input model.CreateTodoInput) (*model.TodoOperation, error) {
+	return r.idempotentOperation(ctx, input.UserID, input.ClientMutationID, func() (*model.TodoOperation, error) {
+		// n := len(r.Resolver.TodoStore)
+		// if n == 0 {
+		// 	r.Resolver.TodoStore = make(map[string]model.Todo)
+		// }
 
-	// var todo model.Todo
-	// nid := xid.New().String()
-	// todo.ID = nid
-	// todo.Text = input.Text
-	// todo.UserID = input.UserID
-	// todo.Done = false
-	// currentTime := time.Now()
-	// todo.CreatedAt = currentTime.Format("2006-01-02 15:04:05")
-	// if todo.Done {
-	// 	todo.Done = true
-	// }
-	// r.Resolver.TodoStore[nid] = todo
-	// return &todo, nil
+		// var todo model.Todo
+		// nid := xid.New().String()
+		// todo.ID = nid
+		// todo.Text = input.Text
+		// todo.UserID = input.UserID
+		// todo.Done = false
+		// currentTime := time.Now()
+		// todo.CreatedAt = currentTime.Format("2006-01-02 15:04:05")
+		// if todo.Done {
+		// 	todo.Done = true
+		// }
+		// r.Resolver.TodoStore[nid] = todo
+		// return &todo, nil
+
+		var row repository.TodoRow
+		nid := xid.New().String()
+		if input.ID != nil {
+			if err := checkClientID(*input.ID); err != nil {
+				return nil, fmt.Errorf("CreateTodo %v", err)
+			}
+			nid = *input.ID
+		}
+		row.ID = nid
+		row.Text = input.Text
+		row.UserID = input.UserID
+		row.Done = false
+		row.CreatedAt = time.Now()
+		row.CompletedAt = time.Now()
+		if input.ListID != nil {
+			if _, err := r.openListOf(ctx, input.UserID, *input.ListID); err != nil {
+				return nil, fmt.Errorf("CreateTodo %v", err)
+			}
+			row.ListID = *input.ListID
+		}
+		if input.ParentID != nil {
+			if _, err := r.parentFor(ctx, input.UserID, *input.ParentID); err != nil {
+				return nil, fmt.Errorf("CreateTodo %v", err)
+			}
+			row.ParentID = *input.ParentID
+		}
+		if input.DueAt != nil {
+			dueAt, err := parseDatetime(*input.DueAt)
+			if err != nil {
+				return nil, fmt.Errorf("CreateTodo %v", err)
+			}
+			row.DueAt = dueAt
+		}
+		if input.Recurrence != nil && *input.Recurrence != "" {
+			recurrence, dueAt, err := newRecurrence(*input.Recurrence, row.DueAt, row.CreatedAt)
+			if err != nil {
+				return nil, fmt.Errorf("CreateTodo %v", err)
+			}
+			row.Recurrence = recurrence
+			row.DueAt = dueAt
+		}
+		position, err := r.appendPosition(ctx, input.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("CreateTodo %v", err)
+		}
+		row.Position = position
+		row.Priority = repository.PriorityMedium
+		if input.Priority != nil {
+			row.Priority = priorityFromModel(*input.Priority)
+		}
+		operationID, repo := r.newOperation(ctx, input.UserID)
+		// isSuccessful, err := data.AddTodo(row)
+		isSuccessful, err := repo.AddTodo(row)
+		if err != nil {
+			return nil, fmt.Errorf("CreateTodo failed %v", err)
+		}
+		if !isSuccessful {
+			return nil, fmt.Errorf("CreateTodo no record inserted")
+		}
+		inserted, err := r.repo(ctx).Primary().TodoByID(nid)
+		if err != nil {
+			return nil, fmt.Errorf("CreateTodo failed to get todo %q %v", nid, err)
+		}
+		return &model.TodoOperation{OperationID: operationID, Todo: todoFromRow(inserted)}, nil
+	})
+}
+
+func (r *mutationResolver) UpdateTodo(ctx context.Context, input model.UpdateTodoInput) (*model.TodoOperation, error) {
+	return r.idempotentOperation(ctx, input.UserID, input.ClientMutationID, func() (*model.TodoOperation, error) {
+		// fmt.Sprintln("enter UpsertTodo")
+		// id := input.ID
+		// var todo model.Todo
+
+		// n := len(r.Resolver.TodoStore)
+		// if n == 0 {
+		// 	r.Resolver.TodoStore = make(map[string]model.Todo)
+		// }
+
+		// todo, ok := r.Resolver.TodoStore[id]
+		// if !ok {
+		// 	return nil, fmt.Errorf("not found")
+		// }
+		// if input.Text != nil {
+		// 	todo.Text = *input.Text
+		// }
+		// if input.Done != nil {
+		// 	todo.Done = *input.Done
+		// 	if *input.Done == true {
+		// 		currentTime := time.Now()
+		// 		todo.CompletedAt = currentTime.Format("2006-01-02 15:04:05")
+		// 	} else {
+		// 		todo.CompletedAt = ""
+		// 	}
+		// }
+		// r.Resolver.TodoStore[id] = todo
+		// return &todo, nil
+		var row repository.TodoRow
+		row.ID = input.ID
+		if input.Text != nil {
+			row.Text = *input.Text
+		} else {
+			row.Text = ""
+		}
+		row.Done = input.Done
+		var dueAt time.Time
+		if input.DueAt != nil {
+			parsed, err := parseDatetime(*input.DueAt)
+			if err != nil {
+				return nil, fmt.Errorf("UpdateTodo %v", err)
+			}
+			dueAt = parsed
+		}
+		// the previous state tells whether this update completes a repeating todo
+		previous, err := r.repo(ctx).Primary().TodoByID(input.ID)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateTodo failed to get todo %q, %v", input.ID, err)
+		}
+		if err := r.todoAccess(ctx, input.UserID, previous, repository.RoleEditor); err != nil {
+			return nil, fmt.Errorf("UpdateTodo %v", err)
+		}
+		recurrence := previous.Recurrence
+		if input.Recurrence != nil {
+			recurrence = ""
+			if *input.Recurrence != "" {
+				anchor := previous.DueAt
+				if input.DueAt != nil {
+					anchor = dueAt
+				}
+				recurrence, dueAt, err = newRecurrence(*input.Recurrence, anchor, time.Now())
+				if err != nil {
+					return nil, fmt.Errorf("UpdateTodo %v", err)
+				}
+			}
+		}
+		operationID, repo := r.newOperation(ctx, input.UserID)
+		// isSuccessful, err := data.UpdateTodo(input)
+		isSuccessful, err := repo.UpdateTodo(row)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateTodo failed to update todo %q, %v", input.ID, err)
+		}
+		if !isSuccessful {
+			return nil, fmt.Errorf("UpdateTodo no record to update")
+		}
+		if input.Done && input.CompleteChildren != nil && *input.CompleteChildren {
+			if _, err := repo.CompleteDescendants(input.ID); err != nil {
+				return nil, fmt.Errorf("UpdateTodo failed to complete children of todo %q, %v", input.ID, err)
+			}
+		}
+		if input.DueAt != nil || (input.ClearDueAt != nil && *input.ClearDueAt) || !dueAt.IsZero() {
+			if _, err := repo.SetTodoDueAt(input.ID, dueAt); err != nil {
+				return nil, fmt.Errorf("UpdateTodo failed to set due date of todo %q, %v", input.ID, err)
+			}
+		}
+		if input.Priority != nil {
+			if _, err := repo.SetTodoPriority(input.ID, priorityFromModel(*input.Priority)); err != nil {
+				return nil, fmt.Errorf("UpdateTodo failed to set priority of todo %q, %v", input.ID, err)
+			}
+		}
+		if input.Recurrence != nil {
+			if _, err := repo.SetTodoRecurrence(input.ID, recurrence); err != nil {
+				return nil, fmt.Errorf("UpdateTodo failed to set recurrence of todo %q, %v", input.ID, err)
+			}
+		}
+		if input.Done && !previous.Done && recurrence != "" {
+			completed := previous
+			completed.Recurrence = recurrence
+			if !dueAt.IsZero() {
+				completed.DueAt = dueAt
+			}
+			if err := r.scheduleNextOccurrence(ctx, repo, completed); err != nil {
+				return nil, fmt.Errorf("UpdateTodo %v", err)
+			}
+		}
+		row, err = r.repo(ctx).Primary().TodoByID(input.ID)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateTodo failed to get todo %q, %v", input.ID, err)
+		}
+		return &model.TodoOperation{OperationID: operationID, Todo: todoFromRow(row)}, nil
+	})
+}
+
+func (r *mutationResolver) DeleteTodo(ctx context.Context, id string, userID string) (*model.TodoOperation, error) {
+	return r.idempotentOperation(ctx, userID, nil, func() (*model.TodoOperation, error) {
+		row, err := r.repo(ctx).Primary().TodoByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("DeleteTodo failed to get todo %q, %v", id, err)
+		}
+		if row.UserID != userID {
+			return nil, fmt.Errorf("DeleteTodo only the owner may delete todo %q", id)
+		}
+		operationID, repo := r.newOperation(ctx, userID)
+		isSuccessful, err := repo.DeleteTodo(id)
+		if err != nil {
+			return nil, fmt.Errorf("DeleteTodo failed to delete todo %q, %v", id, err)
+		}
+		if !isSuccessful {
+			return nil, fmt.Errorf("DeleteTodo no record to delete")
+		}
+		return &model.TodoOperation{OperationID: operationID}, nil
+	})
+}
+
+func (r *mutationResolver) Undo(ctx context.Context, operationID string, userID string) (*model.TodoOperation, error) {
+	entries, err := r.operationOf(ctx, userID, operationID)
+	if err != nil {
+		return nil, fmt.Errorf("Undo %v", err)
+	}
+	isSuccessful, err := r.repo(ctx).WithActor(userID).UndoOperation(operationID)
+	if err != nil {
+		return nil, fmt.Errorf("Undo failed %v", err)
+	}
+	if !isSuccessful {
+		return nil, fmt.Errorf("Undo no operation %q", operationID)
+	}
+	result, err := r.operationResult(ctx, operationID, entries, true)
+	if err != nil {
+		return nil, fmt.Errorf("Undo %v", err)
+	}
+	return result, nil
+}
+
+func (r *mutationResolver) Redo(ctx context.Context, operationID string, userID string) (*model.TodoOperation, error) {
+	entries, err := r.operationOf(ctx, userID, operationID)
+	if err != nil {
+		return nil, fmt.Errorf("Redo %v", err)
+	}
+	isSuccessful, err := r.repo(ctx).WithActor(userID).RedoOperation(operationID)
+	if err != nil {
+		return nil, fmt.Errorf("Redo failed %v", err)
+	}
+	if !isSuccessful {
+		return nil, fmt.Errorf("Redo no operation %q", operationID)
+	}
+	result, err := r.operationResult(ctx, operationID, entries, false)
+	if err != nil {
+		return nil, fmt.Errorf("Redo %v", err)
+	}
+	return result, nil
+}
+
+func (r *mutationResolver) UpdateTodos(ctx context.Context, userID string, ids []string, patch model.TodoPatch) (*model.BulkTodoOperation, error) {
+	if len(ids) > maxBulkTodos {
+		return nil, fmt.Errorf("UpdateTodos at most %d todos may be changed at once", maxBulkTodos)
+	}
+	addTags, err := tagNames(patch.AddTags)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodos %v", err)
+	}
+	removeTags, err := tagNames(patch.RemoveTags)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodos %v", err)
+	}
+	rowPatch := repository.TodoPatch{Done: patch.Done, AddTags: addTags, RemoveTags: removeTags}
+	if patch.Priority != nil {
+		priority := priorityFromModel(*patch.Priority)
+		rowPatch.Priority = &priority
+	}
+	operationID, repo := r.newOperation(ctx, userID)
+	results, err := repo.UpdateTodos(userID, ids, rowPatch)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodos failed to update todos, %v", err)
+	}
+	bulk, err := r.bulkResult(ctx, operationID, results)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodos %v", err)
+	}
+	return bulk, nil
+}
+
+func (r *mutationResolver) CompleteAll(ctx context.Context, userID string, filter *model.TodoFilter) (*model.BulkTodoOperation, error) {
+	var rowFilter repository.TodoFilter
+	if filter != nil {
+		if filter.ListID != nil {
+			rowFilter.ListID = *filter.ListID
+		}
+		tags, err := tagNames(filter.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("CompleteAll %v", err)
+		}
+		rowFilter.Tags = tags
+		if filter.DueBefore != nil {
+			dueBefore, err := parseDatetime(*filter.DueBefore)
+			if err != nil {
+				return nil, fmt.Errorf("CompleteAll %v", err)
+			}
+			rowFilter.DueBefore = dueBefore
+		}
+	}
+	operationID, repo := r.newOperation(ctx, userID)
+	results, err := repo.CompleteAll(userID, rowFilter)
+	if err != nil {
+		return nil, fmt.Errorf("CompleteAll failed to complete todos, %v", err)
+	}
+	bulk, err := r.bulkResult(ctx, operationID, results)
+	if err != nil {
+		return nil, fmt.Errorf("CompleteAll %v", err)
+	}
+	return bulk, nil
+}
+
+func (r *mutationResolver) Sync(ctx context.Context, userID string, since *string, changes []*model.ChangeInput) (*model.SyncResult, error) {
+	sinceVersion, err := parseCursor(since)
+	if err != nil {
+		return nil, fmt.Errorf("Sync %v", err)
+	}
+	if len(changes) > maxBulkTodos {
+		return nil, fmt.Errorf("Sync at most %d changes may be sent at once", maxBulkTodos)
+	}
+	operationID, repo := r.newOperation(ctx, userID)
+	result := &model.SyncResult{OperationID: operationID, Results: []*model.SyncChangeResult{}, Changes: []*model.SyncedTodo{},
+		Conflicts: []*model.SyncConflict{}}
+	failed := false
+	for _, change := range changes {
+		outcome := &model.SyncChangeResult{ID: change.ID, Status: model.SyncChangeStatusApplied}
+		result.Results = append(result.Results, outcome)
+		if failed {
+			outcome.Status = model.SyncChangeStatusSkipped
+			continue
+		}
+		conflict, err := r.applyChange(ctx, userID, repo, change)
+		if err != nil {
+			// the changes before stay applied, and the changes since the
+			// cursor show how far this one got
+			logging.FromContext(ctx).Warn().Err(err).Str("todo_id", change.ID).Msg("sync change failed")
+			message := fmt.Sprintf("failed to apply change, %v", err)
+			outcome.Status, outcome.Error = model.SyncChangeStatusFailed, &message
+			failed = true
+			continue
+		}
+		if conflict != nil {
+			outcome.Status, outcome.Error = model.SyncChangeStatusConflict, &conflict.Reason
+			result.Conflicts = append(result.Conflicts, conflict)
+		}
+	}
+	rows, err := r.repo(ctx).Primary().TodoChangesSince(userID, sinceVersion, syncPageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("Sync failed to get changes since %d, %v", sinceVersion, err)
+	}
+	if len(rows) > syncPageSize {
+		rows = rows[:syncPageSize]
+		result.HasMore = true
+	}
+	cursor := sinceVersion
+	for _, row := range rows {
+		result.Changes = append(result.Changes, syncedTodoFromRow(row))
+		cursor = row.Version
+	}
+	result.Cursor = formatCursor(cursor)
+	return result, nil
+}
+
+func (r *mutationResolver) AddReminder(ctx context.Context, todoID string, userID string, fireAt string, channel model.ReminderChannel, address *string) (*model.Reminder, error) {
+	at, err := parseDatetime(fireAt)
+	if err != nil {
+		return nil, fmt.Errorf("AddReminder %v", err)
+	}
+	to, err := reminderAddress(channel, address)
+	if err != nil {
+		return nil, fmt.Errorf("AddReminder %v", err)
+	}
+	todo, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("AddReminder failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, todo, repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("AddReminder %v", err)
+	}
+
+	id := xid.New().String()
+	row := repository.ReminderRow{ID: id, TodoID: todoID, UserID: userID, FireAt: at, Channel: reminderChannelFromModel(channel), Address: to}
+	if _, err := r.ReminderRepo.AddReminder(row); err != nil {
+		return nil, fmt.Errorf("AddReminder failed to add reminder, %v", err)
+	}
+	row, err = r.ReminderRepo.ReminderByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("AddReminder failed to get reminder %q, %v", id, err)
+	}
+	return reminderFromRow(row), nil
+}
+
+func (r *mutationResolver) DeleteReminder(ctx context.Context, id string, userID string) (bool, error) {
+	if _, err := r.reminderOf(userID, id); err != nil {
+		return false, fmt.Errorf("DeleteReminder %v", err)
+	}
+	isSuccessful, err := r.ReminderRepo.DeleteReminder(id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteReminder failed to delete reminder %q, %v", id, err)
+	}
+	return isSuccessful, nil
+}
+
+func (r *mutationResolver) RegisterWebhook(ctx context.Context, userID string, url string, eventTypes []string, secret string) (*model.Webhook, error) {
+	url, err := webhookURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterWebhook %v", err)
+	}
+	eventTypes, err = webhookEventTypes(eventTypes)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterWebhook %v", err)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("RegisterWebhook secret must not be empty")
+	}
+
+	id := xid.New().String()
+	row := repository.WebhookRow{ID: id, UserID: userID, URL: url, EventTypes: eventTypes, Secret: secret}
+	if _, err := r.WebhookRepo.AddWebhook(row); err != nil {
+		return nil, fmt.Errorf("RegisterWebhook failed to add webhook, %v", err)
+	}
+	row, err = r.WebhookRepo.WebhookByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterWebhook failed to get webhook %q, %v", id, err)
+	}
+	return webhookFromRow(row), nil
+}
+
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string, userID string) (bool, error) {
+	if _, err := r.webhookOf(userID, id); err != nil {
+		return false, fmt.Errorf("DeleteWebhook %v", err)
+	}
+	isSuccessful, err := r.WebhookRepo.DeleteWebhook(id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook failed to delete webhook %q, %v", id, err)
+	}
+	return isSuccessful, nil
+}
+
+func (r *mutationResolver) AddTagToTodo(ctx context.Context, todoID string, userID string, name string) (*model.Todo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("AddTagToTodo tag name must not be empty")
+	}
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("AddTagToTodo failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("AddTagToTodo %v", err)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	// tag names are unique per user, so an existing tag is reused
+	if _, err := repo.AddTag(repository.TagRow{ID: xid.New().String(), UserID: row.UserID, Name: name}); err != nil {
+		return nil, fmt.Errorf("AddTagToTodo failed to add tag %q, %v", name, err)
+	}
+	tag, err := r.repo(ctx).Primary().TagByName(row.UserID, name)
+	if err != nil {
+		return nil, fmt.Errorf("AddTagToTodo failed to get tag %q, %v", name, err)
+	}
+	if _, err := repo.AddTagToTodo(row.ID, tag.ID); err != nil {
+		return nil, fmt.Errorf("AddTagToTodo failed to tag todo %q, %v", todoID, err)
+	}
+	return todoFromRow(row), nil
+}
 
-	var row repository.TodoRow
+func (r *mutationResolver) RemoveTagFromTodo(ctx context.Context, todoID string, userID string, name string) (*model.Todo, error) {
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveTagFromTodo failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("RemoveTagFromTodo %v", err)
+	}
+	tag, err := r.repo(ctx).Primary().TagByName(row.UserID, strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("RemoveTagFromTodo failed to get tag %q, %v", name, err)
+	}
+	isSuccessful, err := r.repo(ctx).WithActor(userID).RemoveTagFromTodo(row.ID, tag.ID)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveTagFromTodo failed to untag todo %q, %v", todoID, err)
+	}
+	if !isSuccessful {
+		return nil, fmt.Errorf("RemoveTagFromTodo todo %q is not tagged %q", todoID, name)
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *mutationResolver) RenameTag(ctx context.Context, id string, userID string, name string) (*model.Tag, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("RenameTag tag name must not be empty")
+	}
+	tag, err := r.repo(ctx).Primary().TagByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("RenameTag failed to get tag %q, %v", id, err)
+	}
+	if tag.UserID != userID {
+		return nil, fmt.Errorf("RenameTag tag %q does not belong to user %q", id, userID)
+	}
+	if _, err := r.repo(ctx).WithActor(userID).RenameTag(id, name); err != nil {
+		return nil, fmt.Errorf("RenameTag failed to rename tag %q, %v", id, err)
+	}
+	// renaming to the current name affects no rows, so look the tag up either way
+	tag, err = r.repo(ctx).Primary().TagByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("RenameTag failed to get tag %q, %v", id, err)
+	}
+	return tagFromRow(tag), nil
+}
+
+func (r *mutationResolver) CreateTodoList(ctx context.Context, input model.CreateTodoListInput) (*model.TodoList, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("CreateTodoList list name must not be empty")
+	}
 	nid := xid.New().String()
-	row.ID = nid
-	row.Text = input.Text
-	row.UserID = input.UserID
-	row.Done = false
-	row.CreatedAt = time.Now()
-	row.CompletedAt = time.Now()
-	// isSuccessful, err := data.AddTodo(row)
-	isSuccessful, err := r.Repo.AddTodo(row)
+	repo := r.repo(ctx).Primary().WithActor(input.UserID)
+	isSuccessful, err := repo.AddTodoList(repository.TodoListRow{ID: nid, UserID: input.UserID, Name: name})
 	if err != nil {
-		return nil, fmt.Errorf("CreateTodo failed %v", err)
+		return nil, fmt.Errorf("CreateTodoList failed %v", err)
 	}
 	if !isSuccessful {
-		return nil, fmt.Errorf("CreateTodo no record inserted")
+		return nil, fmt.Errorf("CreateTodoList no record inserted")
 	}
-	inserted, err := r.Repo.TodoByID(nid)
+	inserted, err := r.repo(ctx).Primary().TodoListByID(nid)
 	if err != nil {
-		return nil, fmt.Errorf("CreateTodo failed to get todo %q %v", nid, err)
+		return nil, fmt.Errorf("CreateTodoList failed to get list %q %v", nid, err)
 	}
-	todo := &model.Todo{
-		ID:          inserted.ID,
-		Text:        inserted.Text,
-		UserID:      inserted.UserID,
-		Done:        inserted.Done,
-		CreatedAt:   inserted.CreatedAt.Format("2006-01-02 15:04:05"),
-		CompletedAt: inserted.CompletedAt.Format("2006-01-02 15:04:05"),
+	return todoListFromRow(inserted), nil
+}
+
+func (r *mutationResolver) UpdateTodoList(ctx context.Context, input model.UpdateTodoListInput) (*model.TodoList, error) {
+	row, err := r.repo(ctx).Primary().TodoListByID(input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodoList failed to get list %q, %v", input.ID, err)
 	}
-	return todo, nil
+	if err := r.listAccess(ctx, input.UserID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("UpdateTodoList %v", err)
+	}
+	if input.Archived != nil && *input.Archived != row.Archived && input.UserID != row.UserID {
+		return nil, fmt.Errorf("UpdateTodoList only the owner may archive list %q", input.ID)
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, fmt.Errorf("UpdateTodoList list name must not be empty")
+		}
+		row.Name = name
+	}
+	if input.Archived != nil {
+		row.Archived = *input.Archived
+	}
+	repo := r.repo(ctx).Primary().WithActor(input.UserID)
+	if _, err := repo.UpdateTodoList(row); err != nil {
+		return nil, fmt.Errorf("UpdateTodoList failed to update list %q, %v", input.ID, err)
+	}
+	row, err = r.repo(ctx).Primary().TodoListByID(input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateTodoList failed to get list %q, %v", input.ID, err)
+	}
+	return todoListFromRow(row), nil
 }
 
-func (r *mutationResolver) UpdateTodo(ctx context.Context, input model.UpdateTodoInput) (*model.Todo, error) {
-	// fmt.Sprintln("enter UpsertTodo")
-	// id := input.ID
-	// var todo model.Todo
+func (r *mutationResolver) DeleteTodoList(ctx context.Context, id string, userID string) (bool, error) {
+	list, err := r.repo(ctx).Primary().TodoListByID(id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteTodoList failed to get list %q, %v", id, err)
+	}
+	if list.UserID != userID {
+		return false, fmt.Errorf("DeleteTodoList only the owner may delete list %q", id)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	isSuccessful, err := repo.DeleteTodoList(id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteTodoList failed to delete list %q, %v", id, err)
+	}
+	return isSuccessful, nil
+}
 
-	// n := len(r.Resolver.TodoStore)
-	// if n == 0 {
-	// 	r.Resolver.TodoStore = make(map[string]model.Todo)
-	// }
+func (r *mutationResolver) MoveTodoToList(ctx context.Context, todoID string, userID string, listID *string) (*model.Todo, error) {
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("MoveTodoToList failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("MoveTodoToList %v", err)
+	}
+	target := ""
+	if listID != nil {
+		if _, err := r.openListOf(ctx, userID, *listID); err != nil {
+			return nil, fmt.Errorf("MoveTodoToList %v", err)
+		}
+		target = *listID
+	}
+	if _, err := r.repo(ctx).WithActor(userID).MoveTodoToList(todoID, target); err != nil {
+		return nil, fmt.Errorf("MoveTodoToList failed to move todo %q, %v", todoID, err)
+	}
+	row, err = r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("MoveTodoToList failed to get todo %q, %v", todoID, err)
+	}
+	return todoFromRow(row), nil
+}
 
-	// todo, ok := r.Resolver.TodoStore[id]
-	// if !ok {
-	// 	return nil, fmt.Errorf("not found")
-	// }
-	// if input.Text != nil {
-	// 	todo.Text = *input.Text
-	// }
-	// if input.Done != nil {
-	// 	todo.Done = *input.Done
-	// 	if *input.Done == true {
-	// 		currentTime := time.Now()
-	// 		todo.CompletedAt = currentTime.Format("2006-01-02 15:04:05")
-	// 	} else {
-	// 		todo.CompletedAt = ""
-	// 	}
-	// }
-	// r.Resolver.TodoStore[id] = todo
-	// return &todo, nil
-	var row repository.TodoRow
-	row.ID = input.ID
-	if input.Text != nil {
-		row.Text = *input.Text
-	} else {
-		row.Text = ""
+func (r *mutationResolver) SetTodoParent(ctx context.Context, todoID string, userID string, parentID *string) (*model.Todo, error) {
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("SetTodoParent failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("SetTodoParent %v", err)
+	}
+	target := ""
+	if parentID != nil {
+		parent, err := r.parentFor(ctx, row.UserID, *parentID)
+		if err != nil {
+			return nil, fmt.Errorf("SetTodoParent %v", err)
+		}
+		if err := r.todoAccess(ctx, userID, parent, repository.RoleEditor); err != nil {
+			return nil, fmt.Errorf("SetTodoParent %v", err)
+		}
+		target = *parentID
+	}
+	if _, err := r.repo(ctx).WithActor(userID).SetTodoParent(todoID, target); err != nil {
+		return nil, fmt.Errorf("SetTodoParent failed to reparent todo %q, %v", todoID, err)
+	}
+	row, err = r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("SetTodoParent failed to get todo %q, %v", todoID, err)
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *mutationResolver) MoveTodo(ctx context.Context, id string, userID string, beforeID *string, afterID *string) (*model.Todo, error) {
+	if beforeID == nil && afterID == nil {
+		return nil, fmt.Errorf("MoveTodo needs beforeId or afterId")
+	}
+	row, err := r.repo(ctx).Primary().TodoByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("MoveTodo failed to get todo %q, %v", id, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("MoveTodo %v", err)
+	}
+	var lower, upper string
+	if beforeID != nil {
+		if lower, err = r.positionOf(ctx, row.UserID, *beforeID); err != nil {
+			return nil, fmt.Errorf("MoveTodo %v", err)
+		}
+	}
+	if afterID != nil {
+		if upper, err = r.positionOf(ctx, row.UserID, *afterID); err != nil {
+			return nil, fmt.Errorf("MoveTodo %v", err)
+		}
+	}
+	// with a single neighbour the todo goes right next to it
+	if afterID == nil {
+		if upper, err = r.repo(ctx).Primary().TodoPositionAfter(row.UserID, lower); err != nil {
+			return nil, fmt.Errorf("MoveTodo failed to find position after %q, %v", *beforeID, err)
+		}
+	}
+	if beforeID == nil {
+		if lower, err = r.repo(ctx).Primary().TodoPositionBefore(row.UserID, upper); err != nil {
+			return nil, fmt.Errorf("MoveTodo failed to find position before %q, %v", *afterID, err)
+		}
+	}
+	position, err := rank.Between(lower, upper)
+	if err != nil {
+		return nil, fmt.Errorf("MoveTodo %v", err)
+	}
+	if _, err := r.repo(ctx).WithActor(userID).SetTodoPosition(id, position); err != nil {
+		return nil, fmt.Errorf("MoveTodo failed to move todo %q, %v", id, err)
+	}
+	row, err = r.repo(ctx).Primary().TodoByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("MoveTodo failed to get todo %q, %v", id, err)
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *mutationResolver) AttachFile(ctx context.Context, todoID string, userID string, file graphql.Upload) (*model.Attachment, error) {
+	if file.Size > MaxAttachmentSize {
+		return nil, fmt.Errorf("AttachFile file is larger than %d bytes", MaxAttachmentSize)
+	}
+	todo, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("AttachFile failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, todo, repository.RoleEditor); err != nil {
+		return nil, fmt.Errorf("AttachFile %v", err)
+	}
+	contentType, contents, err := sniffAttachment(file.File)
+	if err != nil {
+		return nil, fmt.Errorf("AttachFile %v", err)
+	}
+
+	nid := xid.New().String()
+	row := repository.AttachmentRow{
+		ID:          nid,
+		TodoID:      todoID,
+		Filename:    attachmentFilename(file.Filename),
+		ContentType: contentType,
+		Size:        file.Size,
+		BlobKey:     todoID + "/" + nid,
+	}
+	// the limit guards against uploads that are larger than they claimed
+	if err := r.Blobs.Put(ctx, row.BlobKey, io.LimitReader(contents, MaxAttachmentSize)); err != nil {
+		return nil, fmt.Errorf("AttachFile failed to store file, %v", err)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	isSuccessful, err := repo.AddAttachment(row)
+	if err == nil && !isSuccessful {
+		err = fmt.Errorf("no record inserted")
+	}
+	if err != nil {
+		if delErr := r.Blobs.Delete(ctx, row.BlobKey); delErr != nil {
+			logging.FromContext(ctx).Error().Err(delErr).Str("blob", row.BlobKey).Msg("AttachFile failed to remove orphaned blob")
+		}
+		return nil, fmt.Errorf("AttachFile failed %v", err)
+	}
+	attachments, err := r.repo(ctx).Primary().AttachmentsByTodo(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("AttachFile failed to get attachments of todo %q, %v", todoID, err)
+	}
+	for _, a := range attachments {
+		if a.ID == nid {
+			return r.attachmentFromRow(a), nil
+		}
+	}
+	return nil, fmt.Errorf("AttachFile failed to get attachment %q", nid)
+}
+
+func (r *mutationResolver) AddComment(ctx context.Context, input model.AddCommentInput) (*model.Comment, error) {
+	body, err := commentBody(input.Body)
+	if err != nil {
+		return nil, fmt.Errorf("AddComment %v", err)
+	}
+	todo, err := r.repo(ctx).Primary().TodoByID(input.TodoID)
+	if err != nil {
+		return nil, fmt.Errorf("AddComment failed to get todo %q, %v", input.TodoID, err)
 	}
-	row.Done = input.Done
-	// isSuccessful, err := data.UpdateTodo(input)
-	isSuccessful, err := r.Repo.UpdateTodo(row)
+	if err := r.todoAccess(ctx, input.AuthorID, todo, repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("AddComment %v", err)
+	}
+	nid := xid.New().String()
+	isSuccessful, err := r.CommentRepo.AddComment(repository.CommentRow{ID: nid, TodoID: input.TodoID, AuthorID: input.AuthorID, Body: body})
 	if err != nil {
-		return nil, fmt.Errorf("UpdateTodo failed to update todo %q, %v", input.ID, err)
+		return nil, fmt.Errorf("AddComment failed %v", err)
 	}
 	if !isSuccessful {
-		return nil, fmt.Errorf("UpdateTodo no record to update")
+		return nil, fmt.Errorf("AddComment no record inserted")
 	}
-	row, err = r.Repo.TodoByID(input.ID)
+	inserted, err := r.CommentRepo.CommentByID(nid)
 	if err != nil {
-		return nil, fmt.Errorf("UpdateTodo failed to get todo %q, %v", input.ID, err)
+		return nil, fmt.Errorf("AddComment failed to get comment %q %v", nid, err)
 	}
-	todo := &model.Todo{
-		ID:          row.ID,
-		Text:        row.Text,
-		UserID:      row.UserID,
-		Done:        row.Done,
-		CreatedAt:   row.CreatedAt.Format("2006-01-02 15:04:05"),
-		CompletedAt: row.CompletedAt.Format("2006-01-02 15:04:05"),
+	return commentFromRow(inserted), nil
+}
+
+func (r *mutationResolver) EditComment(ctx context.Context, input model.EditCommentInput) (*model.Comment, error) {
+	body, err := commentBody(input.Body)
+	if err != nil {
+		return nil, fmt.Errorf("EditComment %v", err)
+	}
+	row, err := r.commentEditableBy(ctx, input.UserID, input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("EditComment %v", err)
+	}
+	row.Body = body
+	if _, err := r.CommentRepo.UpdateComment(row); err != nil {
+		return nil, fmt.Errorf("EditComment failed to update comment %q, %v", input.ID, err)
 	}
+	row, err = r.CommentRepo.CommentByID(input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("EditComment failed to get comment %q, %v", input.ID, err)
+	}
+	return commentFromRow(row), nil
+}
 
-	return todo, nil
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string, userID string) (bool, error) {
+	if _, err := r.commentEditableBy(ctx, userID, id); err != nil {
+		return false, fmt.Errorf("DeleteComment %v", err)
+	}
+	isSuccessful, err := r.CommentRepo.DeleteComment(id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteComment failed to delete comment %q, %v", id, err)
+	}
+	return isSuccessful, nil
 }
 
-func (r *queryResolver) Todo(ctx context.Context, id string) (*model.Todo, error) {
+func (r *mutationResolver) ShareTodo(ctx context.Context, todoID string, userID string, collaboratorID string, role model.Role) (*model.Todo, error) {
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("ShareTodo failed to get todo %q, %v", todoID, err)
+	}
+	if row.UserID != userID {
+		return nil, fmt.Errorf("ShareTodo only the owner may share todo %q", todoID)
+	}
+	if collaboratorID == row.UserID {
+		return nil, fmt.Errorf("ShareTodo todo %q cannot be shared with its owner", todoID)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	if _, err := repo.ShareTodo(todoID, collaboratorID, roleFromModel(role)); err != nil {
+		return nil, fmt.Errorf("ShareTodo failed to share todo %q, %v", todoID, err)
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *mutationResolver) UnshareTodo(ctx context.Context, todoID string, userID string, collaboratorID string) (*model.Todo, error) {
+	row, err := r.repo(ctx).Primary().TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("UnshareTodo failed to get todo %q, %v", todoID, err)
+	}
+	if err := mayUnshare(userID, row.UserID, collaboratorID); err != nil {
+		return nil, fmt.Errorf("UnshareTodo %v", err)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	if _, err := repo.UnshareTodo(todoID, collaboratorID); err != nil {
+		return nil, fmt.Errorf("UnshareTodo failed to unshare todo %q, %v", todoID, err)
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *mutationResolver) ShareTodoList(ctx context.Context, listID string, userID string, collaboratorID string, role model.Role) (*model.TodoList, error) {
+	row, err := r.repo(ctx).Primary().TodoListByID(listID)
+	if err != nil {
+		return nil, fmt.Errorf("ShareTodoList failed to get list %q, %v", listID, err)
+	}
+	if row.UserID != userID {
+		return nil, fmt.Errorf("ShareTodoList only the owner may share list %q", listID)
+	}
+	if collaboratorID == row.UserID {
+		return nil, fmt.Errorf("ShareTodoList list %q cannot be shared with its owner", listID)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	if _, err := repo.ShareTodoList(listID, collaboratorID, roleFromModel(role)); err != nil {
+		return nil, fmt.Errorf("ShareTodoList failed to share list %q, %v", listID, err)
+	}
+	return todoListFromRow(row), nil
+}
+
+func (r *mutationResolver) UnshareTodoList(ctx context.Context, listID string, userID string, collaboratorID string) (*model.TodoList, error) {
+	row, err := r.repo(ctx).Primary().TodoListByID(listID)
+	if err != nil {
+		return nil, fmt.Errorf("UnshareTodoList failed to get list %q, %v", listID, err)
+	}
+	if err := mayUnshare(userID, row.UserID, collaboratorID); err != nil {
+		return nil, fmt.Errorf("UnshareTodoList %v", err)
+	}
+	repo := r.repo(ctx).Primary().WithActor(userID)
+	if _, err := repo.UnshareTodoList(listID, collaboratorID); err != nil {
+		return nil, fmt.Errorf("UnshareTodoList failed to unshare list %q, %v", listID, err)
+	}
+	return todoListFromRow(row), nil
+}
+
+func (r *queryResolver) Todo(ctx context.Context, id string, userID string) (*model.Todo, error) {
 	// START - USING IN-MEMORY STORE
 	// todo, ok := r.Resolver.TodoStore[id]
 	// if !ok {
@@ -136,23 +898,44 @@ func (r *queryResolver) Todo(ctx context.Context, id string) (*model.Todo, error
 
 	// START - USING LOCAL DB
 	// row, err := data.TodoByID(id)
-	row, err := r.Repo.TodoByID(id)
+	row, err := r.repo(ctx).TodoByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("Todo Failed to retrieve TodoByID %q, %v", id, err)
 	}
-	todo := &model.Todo{ // ???
-		ID:          row.ID,
-		Text:        row.Text,
-		UserID:      row.UserID,
-		Done:        row.Done,
-		CreatedAt:   row.CreatedAt.Format("2006-01-02 15:04:05"),
-		CompletedAt: "",
+	if err := r.todoAccess(ctx, userID, row, repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Todo %v", err)
 	}
+	todo := todoFromRow(row)
+	todo.CompletedAt = "" // ???
 	return todo, nil
 	// END - USING LOCAL DB
 }
 
-func (r *queryResolver) Todos(ctx context.Context, userID string) ([]*model.Todo, error) {
+func (r *queryResolver) Webhooks(ctx context.Context, userID string) ([]*model.Webhook, error) {
+	rows, err := r.WebhookRepo.WebhooksByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("Webhooks failed to get webhooks of user %q: %v", userID, err)
+	}
+	webhooks := make([]*model.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, webhookFromRow(row))
+	}
+	return webhooks, nil
+}
+
+func (r *queryResolver) Reminders(ctx context.Context, userID string) ([]*model.Reminder, error) {
+	rows, err := r.ReminderRepo.RemindersByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("Reminders failed to get reminders of user %q: %v", userID, err)
+	}
+	reminders := make([]*model.Reminder, 0, len(rows))
+	for _, row := range rows {
+		reminders = append(reminders, reminderFromRow(row))
+	}
+	return reminders, nil
+}
+
+func (r *queryResolver) Todos(ctx context.Context, userID string, tags []string) ([]*model.Todo, error) {
 	// START - USING IN-MEMORY STORE
 	// n := len(r.Resolver.TodoStore)
 	// if n == 0 {
@@ -172,31 +955,397 @@ func (r *queryResolver) Todos(ctx context.Context, userID string) ([]*model.Todo
 
 	// START - USING LOCAL DB
 	// todoRows, err := data.TodosByUser(userID)
-	todoRows, err := r.Repo.TodosByUser(userID)
+	todoRows, err := r.repo(ctx).TodosByUserAndTags(userID, tags)
 	if err != nil {
 		return nil, fmt.Errorf("Todos Failed to retrieve todos: %v", err)
 	}
 	todos := make([]*model.Todo, 0)
 	for _, row := range todoRows {
-		todo := &model.Todo{
-			ID:          row.ID,
-			Text:        row.Text,
-			UserID:      row.UserID,
-			Done:        row.Done,
-			CreatedAt:   row.CreatedAt.Format("2006-01-02 15:04:05"),
-			CompletedAt: row.CompletedAt.Format("2006-01-02 15:04:05"),
-		}
-		todos = append(todos, todo)
+		todos = append(todos, todoFromRow(row))
 	}
 	return todos, nil
 	// END - USING LOCAL DB
 }
 
+func (r *queryResolver) OverdueTodos(ctx context.Context, userID string) ([]*model.Todo, error) {
+	todoRows, err := r.repo(ctx).OverdueTodos(userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("OverdueTodos Failed to retrieve todos: %v", err)
+	}
+	todos := make([]*model.Todo, 0, len(todoRows))
+	for _, row := range todoRows {
+		todos = append(todos, todoFromRow(row))
+	}
+	return todos, nil
+}
+
+func (r *queryResolver) TodosDueBetween(ctx context.Context, userID string, from string, to string) ([]*model.Todo, error) {
+	fromTime, err := parseDatetime(from)
+	if err != nil {
+		return nil, fmt.Errorf("TodosDueBetween %v", err)
+	}
+	toTime, err := parseDatetime(to)
+	if err != nil {
+		return nil, fmt.Errorf("TodosDueBetween %v", err)
+	}
+	if !toTime.After(fromTime) {
+		return nil, fmt.Errorf("TodosDueBetween from %q must be before to %q", from, to)
+	}
+	todoRows, err := r.repo(ctx).TodosDueBetween(userID, fromTime, toTime)
+	if err != nil {
+		return nil, fmt.Errorf("TodosDueBetween Failed to retrieve todos: %v", err)
+	}
+	todos := make([]*model.Todo, 0, len(todoRows))
+	for _, row := range todoRows {
+		todos = append(todos, todoFromRow(row))
+	}
+	return todos, nil
+}
+
+func (r *queryResolver) TodoStats(ctx context.Context, userID string, from string, to string) (*model.TodoStats, error) {
+	fromTime, err := parseDatetime(from)
+	if err != nil {
+		return nil, fmt.Errorf("TodoStats %v", err)
+	}
+	toTime, err := parseDatetime(to)
+	if err != nil {
+		return nil, fmt.Errorf("TodoStats %v", err)
+	}
+	if !toTime.After(fromTime) {
+		return nil, fmt.Errorf("TodoStats from %q must be before to %q", from, to)
+	}
+	row, err := r.repo(ctx).TodoStats(userID, fromTime, toTime)
+	if err != nil {
+		return nil, fmt.Errorf("TodoStats failed to aggregate todos of user %q: %v", userID, err)
+	}
+	return todoStatsFromRow(row), nil
+}
+
+func (r *queryResolver) UpcomingOccurrences(ctx context.Context, todoID string, userID string, count *int) ([]string, error) {
+	row, err := r.repo(ctx).TodoByID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("UpcomingOccurrences failed to get todo %q, %v", todoID, err)
+	}
+	if err := r.todoAccess(ctx, userID, row, repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("UpcomingOccurrences %v", err)
+	}
+	occurrences := make([]string, 0)
+	if row.Recurrence == "" {
+		return occurrences, nil
+	}
+	rule, err := rrule.Parse(row.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("UpcomingOccurrences invalid recurrence of todo %q, %v", todoID, err)
+	}
+	limit := 5
+	if count != nil {
+		limit = *count
+	}
+	if limit < 0 || limit > maxOccurrences {
+		return nil, fmt.Errorf("UpcomingOccurrences count must be between 0 and %d", maxOccurrences)
+	}
+	after := row.DueAt
+	if after.IsZero() {
+		after = time.Now()
+	}
+	for _, occurrence := range rule.Occurrences(after, limit) {
+		occurrences = append(occurrences, occurrence.Format(datetimeLayout))
+	}
+	return occurrences, nil
+}
+
+func (r *queryResolver) TodoList(ctx context.Context, id string, userID string) (*model.TodoList, error) {
+	row, err := r.repo(ctx).TodoListByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("TodoList Failed to retrieve TodoListByID %q, %v", id, err)
+	}
+	if err := r.listAccess(ctx, userID, row, repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("TodoList %v", err)
+	}
+	return todoListFromRow(row), nil
+}
+
+func (r *queryResolver) TodoLists(ctx context.Context, userID string, includeArchived *bool) ([]*model.TodoList, error) {
+	listRows, err := r.repo(ctx).TodoListsByUser(userID, includeArchived != nil && *includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("TodoLists Failed to retrieve lists: %v", err)
+	}
+	lists := make([]*model.TodoList, 0, len(listRows))
+	for _, row := range listRows {
+		lists = append(lists, todoListFromRow(row))
+	}
+	return lists, nil
+}
+
+func (r *queryResolver) SearchTodos(ctx context.Context, userID string, query string, first *int, after *string) (*model.TodoSearchConnection, error) {
+	terms := search.Tokenize(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("SearchTodos query %q has no words to search for", query)
+	}
+	limit, err := pageSize(first)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTodos %v", err)
+	}
+	offset, err := offsetAfter(after)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTodos %v", err)
+	}
+
+	// one more row than asked for tells whether there is a next page
+	rows, err := r.repo(ctx).SearchTodos(userID, query, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTodos Failed to search todos: %v", err)
+	}
+	connection := &model.TodoSearchConnection{
+		Edges:    make([]*model.TodoSearchResult, 0, limit),
+		PageInfo: &model.PageInfo{HasNextPage: len(rows) > limit},
+	}
+	for i, row := range rows {
+		if i == limit {
+			break
+		}
+		cursor := offsetCursor(offset + i)
+		connection.Edges = append(connection.Edges, &model.TodoSearchResult{
+			Todo:    todoFromRow(row.Todo),
+			Score:   row.Score,
+			Snippet: search.Snippet(row.Todo.Text, terms, snippetWidth),
+			Cursor:  cursor,
+		})
+		connection.PageInfo.EndCursor = &cursor
+	}
+	return connection, nil
+}
+
+func (r *queryResolver) Activity(ctx context.Context, userID string, first *int, after *string) (*model.HistoryConnection, error) {
+	limit, err := pageSize(first)
+	if err != nil {
+		return nil, fmt.Errorf("Activity %v", err)
+	}
+	offset, err := offsetAfter(after)
+	if err != nil {
+		return nil, fmt.Errorf("Activity %v", err)
+	}
+
+	// one more row than asked for tells whether there is a next page
+	rows, err := r.repo(ctx).ActivityByUser(userID, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Activity failed to get activity of user %q: %v", userID, err)
+	}
+	return historyConnection(rows, limit, offset), nil
+}
+
+func (r *todoResolver) Tags(ctx context.Context, obj *model.Todo) ([]*model.Tag, error) {
+	tagRows, err := r.repo(ctx).TagsByTodo(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Tags failed to retrieve tags of todo %q: %v", obj.ID, err)
+	}
+	tags := make([]*model.Tag, 0, len(tagRows))
+	for _, row := range tagRows {
+		tags = append(tags, tagFromRow(row))
+	}
+	return tags, nil
+}
+
+func (r *todoResolver) List(ctx context.Context, obj *model.Todo) (*model.TodoList, error) {
+	if obj.ListID == nil {
+		return nil, nil
+	}
+	row, err := r.repo(ctx).TodoListByID(*obj.ListID)
+	if err != nil {
+		return nil, fmt.Errorf("List failed to retrieve list of todo %q: %v", obj.ID, err)
+	}
+	return todoListFromRow(row), nil
+}
+
+func (r *todoResolver) Parent(ctx context.Context, obj *model.Todo, userID string) (*model.Todo, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	row, err := r.repo(ctx).TodoByID(*obj.ParentID)
+	if err != nil {
+		return nil, fmt.Errorf("Parent failed to retrieve parent of todo %q: %v", obj.ID, err)
+	}
+	visible, err := r.todoAllows(ctx, userID, row, repository.RoleViewer)
+	if err != nil {
+		return nil, fmt.Errorf("Parent %v", err)
+	}
+	if !visible {
+		return nil, nil
+	}
+	return todoFromRow(row), nil
+}
+
+func (r *todoResolver) Children(ctx context.Context, obj *model.Todo, userID string) ([]*model.Todo, error) {
+	todoRows, err := r.repo(ctx).TodosByParent(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Children failed to retrieve children of todo %q: %v", obj.ID, err)
+	}
+	todos := make([]*model.Todo, 0, len(todoRows))
+	for _, row := range todoRows {
+		visible, err := r.todoAllows(ctx, userID, row, repository.RoleViewer)
+		if err != nil {
+			return nil, fmt.Errorf("Children %v", err)
+		}
+		if visible {
+			todos = append(todos, todoFromRow(row))
+		}
+	}
+	return todos, nil
+}
+
+func (r *todoResolver) Progress(ctx context.Context, obj *model.Todo) (*model.Progress, error) {
+	completed, total, err := r.repo(ctx).ChildProgress(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Progress failed to count children of todo %q: %v", obj.ID, err)
+	}
+	return &model.Progress{Completed: completed, Total: total}, nil
+}
+
+func (r *todoResolver) Comments(ctx context.Context, obj *model.Todo, userID string, first *int, after *string) (*model.CommentConnection, error) {
+	if err := r.todoAccess(ctx, userID, todoRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Comments %v", err)
+	}
+	limit, err := pageSize(first)
+	if err != nil {
+		return nil, fmt.Errorf("Comments %v", err)
+	}
+	offset, err := offsetAfter(after)
+	if err != nil {
+		return nil, fmt.Errorf("Comments %v", err)
+	}
+
+	// one more row than asked for tells whether there is a next page
+	rows, err := r.CommentRepo.CommentsByTodo(obj.ID, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Comments failed to get comments of todo %q: %v", obj.ID, err)
+	}
+	connection := &model.CommentConnection{
+		Edges:    make([]*model.CommentEdge, 0, limit),
+		PageInfo: &model.PageInfo{HasNextPage: len(rows) > limit},
+	}
+	for i, row := range rows {
+		if i == limit {
+			break
+		}
+		cursor := offsetCursor(offset + i)
+		connection.Edges = append(connection.Edges, &model.CommentEdge{Comment: commentFromRow(row), Cursor: cursor})
+		connection.PageInfo.EndCursor = &cursor
+	}
+	return connection, nil
+}
+
+func (r *todoResolver) Attachments(ctx context.Context, obj *model.Todo, userID string) ([]*model.Attachment, error) {
+	if err := r.todoAccess(ctx, userID, todoRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Attachments %v", err)
+	}
+	rows, err := r.repo(ctx).AttachmentsByTodo(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Attachments failed to get attachments of todo %q: %v", obj.ID, err)
+	}
+	attachments := make([]*model.Attachment, 0, len(rows))
+	for _, row := range rows {
+		attachments = append(attachments, r.attachmentFromRow(row))
+	}
+	return attachments, nil
+}
+
+func (r *todoResolver) Collaborators(ctx context.Context, obj *model.Todo, userID string) ([]*model.Collaborator, error) {
+	if err := r.todoAccess(ctx, userID, todoRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Collaborators %v", err)
+	}
+	rows, err := r.repo(ctx).TodoCollaborators(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Collaborators failed to get collaborators of todo %q: %v", obj.ID, err)
+	}
+	return collaboratorsFromRows(rows), nil
+}
+
+func (r *todoResolver) History(ctx context.Context, obj *model.Todo, userID string, first *int, after *string) (*model.HistoryConnection, error) {
+	if err := r.todoAccess(ctx, userID, todoRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("History %v", err)
+	}
+	limit, err := pageSize(first)
+	if err != nil {
+		return nil, fmt.Errorf("History %v", err)
+	}
+	offset, err := offsetAfter(after)
+	if err != nil {
+		return nil, fmt.Errorf("History %v", err)
+	}
+
+	// one more row than asked for tells whether there is a next page
+	rows, err := r.repo(ctx).TodoHistory(obj.ID, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("History failed to get history of todo %q: %v", obj.ID, err)
+	}
+	return historyConnection(rows, limit, offset), nil
+}
+
+func (r *todoListResolver) Todos(ctx context.Context, obj *model.TodoList, userID string) ([]*model.Todo, error) {
+	if err := r.listAccess(ctx, userID, listRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Todos %v", err)
+	}
+	todoRows, err := r.repo(ctx).TodosByList(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Todos failed to retrieve todos of list %q: %v", obj.ID, err)
+	}
+	todos := make([]*model.Todo, 0, len(todoRows))
+	for _, row := range todoRows {
+		todos = append(todos, todoFromRow(row))
+	}
+	return todos, nil
+}
+
+func (r *todoListResolver) Collaborators(ctx context.Context, obj *model.TodoList, userID string) ([]*model.Collaborator, error) {
+	if err := r.listAccess(ctx, userID, listRowOf(obj), repository.RoleViewer); err != nil {
+		return nil, fmt.Errorf("Collaborators %v", err)
+	}
+	rows, err := r.repo(ctx).TodoListCollaborators(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Collaborators failed to get collaborators of list %q: %v", obj.ID, err)
+	}
+	return collaboratorsFromRows(rows), nil
+}
+
+func (r *webhookResolver) Deliveries(ctx context.Context, obj *model.Webhook, status *model.DeliveryStatus, first *int, after *string) (*model.WebhookDeliveryConnection, error) {
+	limit, err := pageSize(first)
+	if err != nil {
+		return nil, fmt.Errorf("Deliveries %v", err)
+	}
+	offset, err := offsetAfter(after)
+	if err != nil {
+		return nil, fmt.Errorf("Deliveries %v", err)
+	}
+	var only repository.DeliveryStatus
+	if status != nil {
+		only = deliveryStatusFromModel(*status)
+	}
+
+	// one more row than asked for tells whether there is a next page
+	rows, err := r.WebhookRepo.WebhookDeliveries(obj.ID, only, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Deliveries failed to get deliveries of webhook %q: %v", obj.ID, err)
+	}
+	return webhookDeliveryConnection(rows, limit, offset), nil
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// Todo returns generated.TodoResolver implementation.
+func (r *Resolver) Todo() generated.TodoResolver { return &todoResolver{r} }
+
+// TodoList returns generated.TodoListResolver implementation.
+func (r *Resolver) TodoList() generated.TodoListResolver { return &todoListResolver{r} }
+
+// Webhook returns generated.WebhookResolver implementation.
+func (r *Resolver) Webhook() generated.WebhookResolver { return &webhookResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type todoResolver struct{ *Resolver }
+type todoListResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// roleAllows reports whether a collaborator role grants the access need asks
+// for. Editors may do everything viewers may.
+func roleAllows(role repository.Role, need repository.Role) bool {
+	return role == repository.RoleEditor || role != "" && role == need
+}
+
+// todoAccess checks that userId owns the todo or has at least the need role
+// on it, through a share of the todo or of its list.
+func (r *Resolver) todoAccess(ctx context.Context, userId string, todo repository.TodoRow, need repository.Role) error {
+	allowed, err := r.todoAllows(ctx, userId, todo, need)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("user %q may not %s todo %q", userId, accessVerb(need), todo.ID)
+	}
+	return nil
+}
+
+// todoAllows is todoAccess for todos left out rather than refused.
+func (r *Resolver) todoAllows(ctx context.Context, userId string, todo repository.TodoRow, need repository.Role) (bool, error) {
+	if todo.UserID == userId {
+		return true, nil
+	}
+	role, err := r.repo(ctx).Primary().TodoRole(todo.ID, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to get role of user %q on todo %q, %v", userId, todo.ID, err)
+	}
+	return roleAllows(role, need), nil
+}
+
+// todoRowOf returns the fields of a todo that todoAccess checks.
+func todoRowOf(todo *model.Todo) repository.TodoRow {
+	return repository.TodoRow{ID: todo.ID, UserID: todo.UserID}
+}
+
+// listAccess checks that userId owns the list or has at least the need role
+// on it.
+func (r *Resolver) listAccess(ctx context.Context, userId string, list repository.TodoListRow, need repository.Role) error {
+	if list.UserID == userId {
+		return nil
+	}
+	role, err := r.repo(ctx).Primary().TodoListRole(list.ID, userId)
+	if err != nil {
+		return fmt.Errorf("failed to get role of user %q on list %q, %v", userId, list.ID, err)
+	}
+	if !roleAllows(role, need) {
+		return fmt.Errorf("user %q may not %s list %q", userId, accessVerb(need), list.ID)
+	}
+	return nil
+}
+
+// listRowOf returns the fields of a list that listAccess checks.
+func listRowOf(list *model.TodoList) repository.TodoListRow {
+	return repository.TodoListRow{ID: list.ID, UserID: list.UserID}
+}
+
+func accessVerb(need repository.Role) string {
+	if need == repository.RoleEditor {
+		return "edit"
+	}
+	return "view"
+}
+
+// mayUnshare checks that userId may take collaboratorId off an item owned by
+// ownerId: owners may remove anyone, collaborators only themselves.
+func mayUnshare(userId string, ownerId string, collaboratorId string) error {
+	if userId != ownerId && userId != collaboratorId {
+		return fmt.Errorf("user %q may not remove collaborator %q", userId, collaboratorId)
+	}
+	return nil
+}
+
+func collaboratorsFromRows(rows []repository.CollaboratorRow) []*model.Collaborator {
+	collaborators := make([]*model.Collaborator, 0, len(rows))
+	for _, row := range rows {
+		collaborators = append(collaborators, &model.Collaborator{
+			UserID:    row.UserID,
+			Role:      roleToModel(row.Role),
+			CreatedAt: row.CreatedAt.Format(datetimeLayout),
+		})
+	}
+	return collaborators
+}
@@ -0,0 +1,211 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/chloexu/hackernews/graph/model"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/rs/xid"
+)
+
+// syncPageSize is the most server changes one sync returns.
+const syncPageSize = 200
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkClientID checks that a todo id chosen by a client is an xid or a UUID.
+func checkClientID(id string) error {
+	if _, err := xid.FromString(id); err == nil {
+		return nil
+	}
+	if uuidPattern.MatchString(id) {
+		return nil
+	}
+	return fmt.Errorf("todo id %q must be an xid or a UUID", id)
+}
+
+// parseCursor reads a Cursor argument, which is a todo version. No cursor
+// stands for the start of the changes.
+func parseCursor(cursor *string) (int64, error) {
+	if cursor == nil || *cursor == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(*cursor, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", *cursor)
+	}
+	return version, nil
+}
+
+func formatCursor(version int64) string {
+	return strconv.FormatInt(version, 10)
+}
+
+func syncedTodoFromRow(row repository.TodoChangeRow) *model.SyncedTodo {
+	synced := &model.SyncedTodo{ID: row.Todo.ID, Version: formatCursor(row.Version), Deleted: row.Deleted}
+	if !row.Deleted {
+		synced.Todo = todoFromRow(row.Todo)
+	}
+	return synced
+}
+
+// applyChange applies one change of a sync by userId through repo. A change
+// that cannot be applied, foremost one made to an outdated version of its
+// todo, is returned as a conflict. The todo is read here without a lock, to
+// tell why a change is refused, and SyncTodo checks the version again with
+// the todo locked: at the same version, the todo is still as read here.
+func (r *Resolver) applyChange(ctx context.Context, userId string, repo repository.Repository, change *model.ChangeInput) (*model.SyncConflict, error) {
+	version, err := r.repo(ctx).Primary().TodoVersion(change.ID)
+	if err != nil {
+		return nil, err
+	}
+	if change.Kind == model.ChangeKindCreate {
+		if err := checkClientID(change.ID); err != nil {
+			return r.conflict(ctx, userId, change.ID, version, err.Error())
+		}
+		if version > 0 {
+			return r.conflict(ctx, userId, change.ID, version, "the todo exists already")
+		}
+		return r.createSynced(ctx, userId, repo, change)
+	}
+
+	if change.BaseVersion == nil {
+		return r.conflict(ctx, userId, change.ID, version, "baseVersion is required")
+	}
+	base, err := parseCursor(change.BaseVersion)
+	if err != nil {
+		return r.conflict(ctx, userId, change.ID, version, err.Error())
+	}
+	current, err := r.repo(ctx).Primary().TodoByID(change.ID)
+	if err != nil {
+		return r.conflict(ctx, userId, change.ID, version, "the todo is deleted or does not exist")
+	}
+	if base != version {
+		return r.conflict(ctx, userId, change.ID, version, fmt.Sprintf("the todo changed on the server after version %d", base))
+	}
+
+	if change.Kind == model.ChangeKindDelete {
+		if current.UserID != userId {
+			return r.conflict(ctx, userId, change.ID, version, "only the owner may delete the todo")
+		}
+		return r.syncTodo(ctx, userId, repo, repository.TodoSync{ID: change.ID, BaseVersion: base, Delete: true})
+	}
+	if err := r.todoAccess(ctx, userId, current, repository.RoleEditor); err != nil {
+		return r.conflict(ctx, userId, change.ID, version, err.Error())
+	}
+	return r.updateSynced(ctx, userId, repo, current, base, change)
+}
+
+func (r *Resolver) createSynced(ctx context.Context, userId string, repo repository.Repository, change *model.ChangeInput) (*model.SyncConflict, error) {
+	if change.Text == nil || *change.Text == "" {
+		return r.conflict(ctx, userId, change.ID, 0, "a new todo needs a text")
+	}
+	row := repository.TodoRow{ID: change.ID, Text: *change.Text, UserID: userId, CreatedAt: time.Now(), CompletedAt: time.Now(),
+		Done: change.Done != nil && *change.Done, Priority: repository.PriorityMedium}
+	if change.ListID != nil {
+		if _, err := r.openListOf(ctx, userId, *change.ListID); err != nil {
+			return r.conflict(ctx, userId, change.ID, 0, err.Error())
+		}
+		row.ListID = *change.ListID
+	}
+	if change.DueAt != nil {
+		dueAt, err := parseDatetime(*change.DueAt)
+		if err != nil {
+			return r.conflict(ctx, userId, change.ID, 0, err.Error())
+		}
+		row.DueAt = dueAt
+	}
+	if change.Priority != nil {
+		row.Priority = priorityFromModel(*change.Priority)
+	}
+	position, err := r.appendPosition(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	row.Position = position
+	return r.syncTodo(ctx, userId, repo, repository.TodoSync{ID: row.ID, Create: &row})
+}
+
+func (r *Resolver) updateSynced(ctx context.Context, userId string, repo repository.Repository, current repository.TodoRow, base int64, change *model.ChangeInput) (*model.SyncConflict, error) {
+	sync := repository.TodoSync{ID: current.ID, BaseVersion: base, ListID: change.ListID}
+	// an empty dueAt clears the due date
+	if change.DueAt != nil {
+		var dueAt time.Time
+		if *change.DueAt != "" {
+			parsed, err := parseDatetime(*change.DueAt)
+			if err != nil {
+				return r.conflict(ctx, userId, change.ID, 0, err.Error())
+			}
+			dueAt = parsed
+		}
+		sync.DueAt = &dueAt
+	}
+	if change.ListID != nil {
+		if _, err := r.openListOf(ctx, current.UserID, *change.ListID); err != nil {
+			return r.conflict(ctx, userId, change.ID, 0, err.Error())
+		}
+	}
+
+	if change.Text != nil && *change.Text != "" {
+		sync.Text = change.Text
+	}
+	if change.Done != nil {
+		sync.Done = change.Done
+		if *change.Done && !current.Done && current.Recurrence != "" {
+			next, err := r.nextOccurrence(ctx, current)
+			if err != nil {
+				return nil, err
+			}
+			// the rule moves over to the next occurrence
+			ended := ""
+			sync.Next, sync.Recurrence = next, &ended
+		}
+	}
+	if change.Priority != nil {
+		priority := priorityFromModel(*change.Priority)
+		sync.Priority = &priority
+	}
+	return r.syncTodo(ctx, userId, repo, sync)
+}
+
+// syncTodo applies a change with SyncTodo, which refuses it when the todo
+// changed after applyChange read it.
+func (r *Resolver) syncTodo(ctx context.Context, userId string, repo repository.Repository, change repository.TodoSync) (*model.SyncConflict, error) {
+	applied, version, err := repo.SyncTodo(change)
+	if err != nil {
+		return nil, err
+	}
+	if applied {
+		return nil, nil
+	}
+	if change.Create != nil {
+		return r.conflict(ctx, userId, change.ID, version, "the todo exists already")
+	}
+	return r.conflict(ctx, userId, change.ID, version, fmt.Sprintf("the todo changed on the server after version %d", change.BaseVersion))
+}
+
+// conflict reports a change that was not applied, along with the todo as it
+// is on the server when userId may see it. A zero version is looked up.
+func (r *Resolver) conflict(ctx context.Context, userId string, id string, version int64, reason string) (*model.SyncConflict, error) {
+	conflict := &model.SyncConflict{ID: id, Reason: reason}
+	if version == 0 {
+		var err error
+		if version, err = r.repo(ctx).Primary().TodoVersion(id); err != nil {
+			return nil, err
+		}
+	}
+	row, err := r.repo(ctx).Primary().TodoByID(id)
+	if err != nil || r.todoAccess(ctx, userId, row, repository.RoleViewer) != nil {
+		return conflict, nil
+	}
+	if version > 0 {
+		cursor := formatCursor(version)
+		conflict.Version = &cursor
+	}
+	conflict.Todo = todoFromRow(row)
+	return conflict, nil
+}
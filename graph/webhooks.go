@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// maxWebhookEventTypes is the most event types a webhook receives.
+const maxWebhookEventTypes = 50
+
+// eventTypePattern matches event types, an entity type and an action as in
+// the history of changes.
+var eventTypePattern = regexp.MustCompile(`^[a-z]+\.[a-z]+$`)
+
+// webhookURL checks that a webhook posts to an absolute http or https URL.
+func webhookURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("webhook url %q must be an absolute http or https URL", rawURL)
+	}
+	return rawURL, nil
+}
+
+// webhookEventTypes checks and deduplicates the event types of a webhook.
+func webhookEventTypes(eventTypes []string) ([]string, error) {
+	var types []string
+	seen := map[string]bool{}
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !eventTypePattern.MatchString(eventType) {
+			return nil, fmt.Errorf("invalid event type %q, want an entity type and an action such as todo.complete", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			types = append(types, eventType)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("a webhook must receive at least one event type")
+	}
+	if len(types) > maxWebhookEventTypes {
+		return nil, fmt.Errorf("a webhook receives at most %d event types", maxWebhookEventTypes)
+	}
+	return types, nil
+}
+
+// webhookOf returns the webhook with the given id after checking that it
+// belongs to userId.
+func (r *Resolver) webhookOf(userId string, webhookId string) (repository.WebhookRow, error) {
+	webhook, err := r.WebhookRepo.WebhookByID(webhookId)
+	if err != nil {
+		return webhook, fmt.Errorf("failed to get webhook %q, %v", webhookId, err)
+	}
+	if webhook.UserID != userId {
+		return webhook, fmt.Errorf("webhook %q does not belong to user %q", webhookId, userId)
+	}
+	return webhook, nil
+}
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rs/zerolog"
+)
+
+// redacted replaces the values of redacted variables.
+const redacted = "[REDACTED]"
+
+// sensitiveNames are parts of the names of variables, and of the fields of
+// input objects, whose values are not logged: secrets, and the addresses
+// reminders are sent to.
+var sensitiveNames = []string{"secret", "password", "token", "key", "auth", "email", "address"}
+
+// Extension is a gqlgen extension logging each operation with its name, its
+// variables, redacted, its duration and its errors, with the logger of its
+// context.
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "Logging"
+}
+
+func (Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	start := time.Now()
+	response := next(ctx)
+
+	opCtx := graphql.GetOperationContext(ctx)
+	name, kind := opCtx.OperationName, ""
+	if opCtx.Operation != nil {
+		name, kind = opCtx.Operation.Name, string(opCtx.Operation.Operation)
+	}
+	if !opCtx.Stats.OperationStart.IsZero() {
+		start = opCtx.Stats.OperationStart
+	}
+	logger := FromContext(ctx)
+	var event *zerolog.Event
+	if response != nil && len(response.Errors) > 0 {
+		errs := make([]string, len(response.Errors))
+		for i, err := range response.Errors {
+			errs[i] = err.Error()
+		}
+		event = logger.Warn().Strs("errors", errs)
+	} else {
+		event = logger.Info()
+	}
+	event.Str("operation", name).Str("type", kind).
+		Interface("variables", Redact(opCtx.Variables)).
+		Dur("duration", time.Since(start)).
+		Msg("graphql operation")
+	return response
+}
+
+// Redact returns a copy of the variables of an operation in which the
+// values of sensitive variables and input fields are replaced, and uploads
+// are described by their name and size.
+func Redact(variables map[string]interface{}) map[string]interface{} {
+	if variables == nil {
+		return nil
+	}
+	return redactValue("", variables).(map[string]interface{})
+}
+
+func redactValue(name string, value interface{}) interface{} {
+	if value != nil && sensitive(name) {
+		return redacted
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			copied[key] = redactValue(key, field)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = redactValue(name, item)
+		}
+		return copied
+	case graphql.Upload:
+		return map[string]interface{}{"filename": v.Filename, "size": v.Size}
+	case *graphql.Upload:
+		return map[string]interface{}{"filename": v.Filename, "size": v.Size}
+	}
+	return value
+}
+
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Package logging sets up the structured, leveled logger of the service, and
+// carries it in contexts tagged with the id of the request being served, so
+// that everything logged for a request can be found by that id.
+package logging
+
+import (
+	"context"
+	"io"
+	stdlog "log"
+	"net/http"
+	"regexp"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the id of a request, from clients that name their
+// requests and back to all clients.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern restricts the request ids taken from clients, which are
+// logged as they are.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// New returns a logger writing entries of level and above to w, as lines of
+// JSON.
+func New(w io.Writer, level zerolog.Level) zerolog.Logger {
+	return zerolog.New(w).Level(level).With().Timestamp().Logger()
+}
+
+// Setup makes logger the logger of contexts without one, and of the zerolog
+// and standard log packages.
+func Setup(logger zerolog.Logger) {
+	log.Logger = logger
+	zerolog.DefaultContextLogger = &log.Logger
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(logger)
+}
+
+// FromContext returns the logger of ctx, or the logger set up with Setup
+// when ctx has none or is nil.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+			return logger
+		}
+	}
+	return &log.Logger
+}
+
+// RequestID gives each request an id, the one in its X-Request-ID header
+// when that is valid, returns it in the X-Request-ID header of the response,
+// and puts a logger tagged with it, and with the trace of the request if
+// any, into the context of the request.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = xid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := req.Context()
+		fields := FromContext(ctx).With().Str("request_id", id)
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			fields = fields.Str("trace_id", span.TraceID().String())
+		}
+		logger := fields.Logger()
+		next.ServeHTTP(w, req.WithContext(logger.WithContext(ctx)))
+	})
+}
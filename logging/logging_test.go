@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rs/zerolog"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// entries decodes the lines of JSON logged to buf.
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var logged []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("logged line %q is not JSON: %v", line, err)
+		}
+		logged = append(logged, entry)
+	}
+	return logged
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"test valid request id should be kept", "client-42.retry:1", true},
+		{"test missing request id should be generated", "", false},
+		{"test request id with spaces should be replaced", "drop table todos", false},
+		{"test long request id should be replaced", strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			base := New(&buf, zerolog.DebugLevel)
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				FromContext(req.Context()).Info().Msg("handled")
+			}))
+			req := httptest.NewRequest("POST", "/query", nil)
+			req = req.WithContext(base.WithContext(req.Context()))
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.keep && id != tt.header {
+				t.Errorf("response %s = %q, want %q", RequestIDHeader, id, tt.header)
+			}
+			if !tt.keep && (id == "" || id == tt.header) {
+				t.Errorf("response %s = %q, want a generated id", RequestIDHeader, id)
+			}
+			logged := entries(t, &buf)
+			if len(logged) != 1 || logged[0]["request_id"] != id {
+				t.Errorf("logged %v, want one entry with request_id %q", logged, id)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	variables := map[string]interface{}{
+		"userId": "chloexu1124",
+		"secret": "hunter2",
+		"input": map[string]interface{}{
+			"text":    "Water roses",
+			"address": "chloe@example.com",
+			"tags":    []interface{}{"garden"},
+		},
+		"file":   graphql.Upload{Filename: "roses.png", Size: 2048},
+		"apiKey": nil,
+	}
+	want := map[string]interface{}{
+		"userId": "chloexu1124",
+		"secret": redacted,
+		"input": map[string]interface{}{
+			"text":    "Water roses",
+			"address": redacted,
+			"tags":    []interface{}{"garden"},
+		},
+		"file":   map[string]interface{}{"filename": "roses.png", "size": int64(2048)},
+		"apiKey": nil,
+	}
+	if got := Redact(variables); !reflect.DeepEqual(got, want) {
+		t.Errorf("Redact() = %v, want %v", got, want)
+	}
+	if variables["secret"] != "hunter2" {
+		t.Error("Redact() changed the variables it was given")
+	}
+}
+
+func TestExtensionLogsOperations(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, zerolog.InfoLevel).With().Str("request_id", "req-1").Logger()
+	opCtx := &graphql.OperationContext{
+		Operation: &ast.OperationDefinition{Name: "RegisterWebhook", Operation: ast.Mutation},
+		Variables: map[string]interface{}{"url": "https://example.com/hook", "secret": "hunter2"},
+	}
+	opCtx.Stats.OperationStart = time.Now().Add(-time.Second)
+	ctx := graphql.WithOperationContext(logger.WithContext(context.Background()), opCtx)
+
+	Extension{}.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+		return &graphql.Response{Errors: gqlerror.List{gqlerror.Errorf("RegisterWebhook failed")}}
+	})
+
+	logged := entries(t, &buf)
+	if len(logged) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(logged))
+	}
+	entry := logged[0]
+	if entry["request_id"] != "req-1" || entry["operation"] != "RegisterWebhook" || entry["type"] != "mutation" || entry["level"] != "warn" {
+		t.Errorf("logged %v, want a warning of mutation RegisterWebhook for req-1", entry)
+	}
+	if duration, _ := entry["duration"].(float64); duration < 1000 {
+		t.Errorf("logged duration %v, want at least 1000ms", entry["duration"])
+	}
+	variables, _ := entry["variables"].(map[string]interface{})
+	if variables["secret"] != redacted || variables["url"] != "https://example.com/hook" {
+		t.Errorf("logged variables %v, want the secret redacted", variables)
+	}
+	if errs, _ := entry["errors"].([]interface{}); len(errs) != 1 {
+		t.Errorf("logged errors %v, want 1", entry["errors"])
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"github.com/chloexu/hackernews/repository/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache returns collectors of the hits, misses and entries of a repository
+// cache, read from its stats when scraped.
+func Cache(cached *cache.Repository) []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "repository_cache",
+			Name:      "hits_total",
+			Help:      "Repository reads served from the cache.",
+		}, func() float64 { return float64(cached.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "repository_cache",
+			Name:      "misses_total",
+			Help:      "Repository reads the cache passed on to the database.",
+		}, func() float64 { return float64(cached.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "repository_cache",
+			Name:      "entries",
+			Help:      "Entries held by the repository cache.",
+		}, func() float64 { return float64(cached.Stats().Entries) }),
+	}
+}
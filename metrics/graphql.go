@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// otherOperation labels the operations whose names are not allowed as
+// labels, and those past maxRootFieldLabels. Clients choose operation names
+// and which root fields they combine, so the labels are bounded this way.
+const otherOperation = "other"
+
+// maxRootFieldLabels caps the labels made of root fields. Operations
+// selecting one root field each stay well below it.
+const maxRootFieldLabels = 200
+
+// Extension is a gqlgen extension counting and timing operations, and
+// counting the errors of resolvers.
+type Extension struct {
+	metrics *Metrics
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+// Extension returns the extension to add to the GraphQL server.
+func (m *Metrics) Extension() Extension {
+	return Extension{metrics: m}
+}
+
+func (Extension) ExtensionName() string {
+	return "Metrics"
+}
+
+func (Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	response := next(ctx)
+	opCtx := graphql.GetOperationContext(ctx)
+	kind := ""
+	if opCtx.Operation != nil {
+		kind = string(opCtx.Operation.Operation)
+	}
+	name := e.metrics.operationLabel(opCtx.Operation)
+	failed := response == nil || len(response.Errors) > 0
+	e.metrics.operations.WithLabelValues(name, kind, status(failed)).Inc()
+	if start := opCtx.Stats.OperationStart; !start.IsZero() {
+		e.metrics.operationDuration.WithLabelValues(name, kind).Observe(time.Since(start).Seconds())
+	}
+	return response
+}
+
+func (e Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	res, err := next(ctx)
+	if err != nil {
+		if fc := graphql.GetFieldContext(ctx); fc != nil && fc.Field.Field != nil {
+			e.metrics.resolverErrors.WithLabelValues(fc.Object, fc.Field.Name).Inc()
+		}
+	}
+	return res, err
+}
+
+// operationLabel returns the label of an operation: its name when it is
+// allowed, or, when no names are, the root fields it selects, sorted and
+// joined by commas.
+func (m *Metrics) operationLabel(operation *ast.OperationDefinition) string {
+	if operation == nil {
+		return otherOperation
+	}
+	if len(m.allowedOperations) > 0 {
+		if m.allowedOperations[operation.Name] {
+			return operation.Name
+		}
+		return otherOperation
+	}
+
+	seen := map[string]bool{}
+	var fields []string
+	collectRootFields(operation.SelectionSet, seen, &fields)
+	if len(fields) == 0 {
+		return otherOperation
+	}
+	sort.Strings(fields)
+	label := strings.Join(fields, ",")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.rootFieldLabels[label] {
+		if len(m.rootFieldLabels) >= maxRootFieldLabels {
+			return otherOperation
+		}
+		m.rootFieldLabels[label] = true
+	}
+	return label
+}
+
+// collectRootFields appends the names of the fields selected by set, and by
+// the fragments it spreads, to fields, once each.
+func collectRootFields(set ast.SelectionSet, seen map[string]bool, fields *[]string) {
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				*fields = append(*fields, s.Name)
+			}
+		case *ast.InlineFragment:
+			collectRootFields(s.SelectionSet, seen, fields)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				collectRootFields(s.Definition.SelectionSet, seen, fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Code generated by decorate -template metrics. DO NOT EDIT.
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// The operations of the wrapped repository, each timed.
+
+var _ repository.Repository = (*Repository)(nil)
+
+func (r *Repository) TodoByID(id string) (value repository.TodoRow, err error) {
+	defer r.observe("TodoByID", time.Now(), &err)
+	return r.next.TodoByID(id)
+}
+
+func (r *Repository) TodosByUser(userId string) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosByUser", time.Now(), &err)
+	return r.next.TodosByUser(userId)
+}
+
+func (r *Repository) TodosByUserAndTags(userId string, tags []string) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosByUserAndTags", time.Now(), &err)
+	return r.next.TodosByUserAndTags(userId, tags)
+}
+
+func (r *Repository) AddTodo(row repository.TodoRow) (value bool, err error) {
+	defer r.observe("AddTodo", time.Now(), &err)
+	return r.next.AddTodo(row)
+}
+
+func (r *Repository) UpdateTodo(row repository.TodoRow) (value bool, err error) {
+	defer r.observe("UpdateTodo", time.Now(), &err)
+	return r.next.UpdateTodo(row)
+}
+
+func (r *Repository) TodosByIDs(ids []string) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosByIDs", time.Now(), &err)
+	return r.next.TodosByIDs(ids)
+}
+
+func (r *Repository) UpdateTodos(userId string, ids []string, patch repository.TodoPatch) (value []repository.BulkResult, err error) {
+	defer r.observe("UpdateTodos", time.Now(), &err)
+	return r.next.UpdateTodos(userId, ids, patch)
+}
+
+func (r *Repository) CompleteAll(userId string, filter repository.TodoFilter) (value []repository.BulkResult, err error) {
+	defer r.observe("CompleteAll", time.Now(), &err)
+	return r.next.CompleteAll(userId, filter)
+}
+
+func (r *Repository) DeleteTodo(id string) (value bool, err error) {
+	defer r.observe("DeleteTodo", time.Now(), &err)
+	return r.next.DeleteTodo(id)
+}
+
+func (r *Repository) TagByID(id string) (value repository.TagRow, err error) {
+	defer r.observe("TagByID", time.Now(), &err)
+	return r.next.TagByID(id)
+}
+
+func (r *Repository) TagByName(userId string, name string) (value repository.TagRow, err error) {
+	defer r.observe("TagByName", time.Now(), &err)
+	return r.next.TagByName(userId, name)
+}
+
+func (r *Repository) TagsByTodo(todoId string) (value []repository.TagRow, err error) {
+	defer r.observe("TagsByTodo", time.Now(), &err)
+	return r.next.TagsByTodo(todoId)
+}
+
+func (r *Repository) AddTag(row repository.TagRow) (value bool, err error) {
+	defer r.observe("AddTag", time.Now(), &err)
+	return r.next.AddTag(row)
+}
+
+func (r *Repository) RenameTag(id string, name string) (value bool, err error) {
+	defer r.observe("RenameTag", time.Now(), &err)
+	return r.next.RenameTag(id, name)
+}
+
+func (r *Repository) AddTagToTodo(todoId string, tagId string) (value bool, err error) {
+	defer r.observe("AddTagToTodo", time.Now(), &err)
+	return r.next.AddTagToTodo(todoId, tagId)
+}
+
+func (r *Repository) RemoveTagFromTodo(todoId string, tagId string) (value bool, err error) {
+	defer r.observe("RemoveTagFromTodo", time.Now(), &err)
+	return r.next.RemoveTagFromTodo(todoId, tagId)
+}
+
+func (r *Repository) TodoListByID(id string) (value repository.TodoListRow, err error) {
+	defer r.observe("TodoListByID", time.Now(), &err)
+	return r.next.TodoListByID(id)
+}
+
+func (r *Repository) TodoListsByUser(userId string, includeArchived bool) (value []repository.TodoListRow, err error) {
+	defer r.observe("TodoListsByUser", time.Now(), &err)
+	return r.next.TodoListsByUser(userId, includeArchived)
+}
+
+func (r *Repository) TodosByList(listId string) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosByList", time.Now(), &err)
+	return r.next.TodosByList(listId)
+}
+
+func (r *Repository) AddTodoList(row repository.TodoListRow) (value bool, err error) {
+	defer r.observe("AddTodoList", time.Now(), &err)
+	return r.next.AddTodoList(row)
+}
+
+func (r *Repository) UpdateTodoList(row repository.TodoListRow) (value bool, err error) {
+	defer r.observe("UpdateTodoList", time.Now(), &err)
+	return r.next.UpdateTodoList(row)
+}
+
+func (r *Repository) DeleteTodoList(id string) (value bool, err error) {
+	defer r.observe("DeleteTodoList", time.Now(), &err)
+	return r.next.DeleteTodoList(id)
+}
+
+func (r *Repository) MoveTodoToList(todoId string, listId string) (value bool, err error) {
+	defer r.observe("MoveTodoToList", time.Now(), &err)
+	return r.next.MoveTodoToList(todoId, listId)
+}
+
+func (r *Repository) TodosByParent(parentId string) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosByParent", time.Now(), &err)
+	return r.next.TodosByParent(parentId)
+}
+
+func (r *Repository) ChildProgress(parentId string) (completed int, total int, err error) {
+	defer r.observe("ChildProgress", time.Now(), &err)
+	return r.next.ChildProgress(parentId)
+}
+
+func (r *Repository) SetTodoParent(todoId string, parentId string) (value bool, err error) {
+	defer r.observe("SetTodoParent", time.Now(), &err)
+	return r.next.SetTodoParent(todoId, parentId)
+}
+
+func (r *Repository) CompleteDescendants(todoId string) (value int64, err error) {
+	defer r.observe("CompleteDescendants", time.Now(), &err)
+	return r.next.CompleteDescendants(todoId)
+}
+
+func (r *Repository) SetTodoDueAt(todoId string, dueAt time.Time) (value bool, err error) {
+	defer r.observe("SetTodoDueAt", time.Now(), &err)
+	return r.next.SetTodoDueAt(todoId, dueAt)
+}
+
+func (r *Repository) SetTodoPriority(todoId string, priority repository.Priority) (value bool, err error) {
+	defer r.observe("SetTodoPriority", time.Now(), &err)
+	return r.next.SetTodoPriority(todoId, priority)
+}
+
+func (r *Repository) SetTodoRecurrence(todoId string, recurrence string) (value bool, err error) {
+	defer r.observe("SetTodoRecurrence", time.Now(), &err)
+	return r.next.SetTodoRecurrence(todoId, recurrence)
+}
+
+func (r *Repository) OverdueTodos(userId string, now time.Time) (value []repository.TodoRow, err error) {
+	defer r.observe("OverdueTodos", time.Now(), &err)
+	return r.next.OverdueTodos(userId, now)
+}
+
+func (r *Repository) TodosDueBetween(userId string, from time.Time, to time.Time) (value []repository.TodoRow, err error) {
+	defer r.observe("TodosDueBetween", time.Now(), &err)
+	return r.next.TodosDueBetween(userId, from, to)
+}
+
+func (r *Repository) LastTodoPosition(userId string) (value string, err error) {
+	defer r.observe("LastTodoPosition", time.Now(), &err)
+	return r.next.LastTodoPosition(userId)
+}
+
+func (r *Repository) TodoPositionBefore(userId string, position string) (value string, err error) {
+	defer r.observe("TodoPositionBefore", time.Now(), &err)
+	return r.next.TodoPositionBefore(userId, position)
+}
+
+func (r *Repository) TodoPositionAfter(userId string, position string) (value string, err error) {
+	defer r.observe("TodoPositionAfter", time.Now(), &err)
+	return r.next.TodoPositionAfter(userId, position)
+}
+
+func (r *Repository) SetTodoPosition(todoId string, position string) (value bool, err error) {
+	defer r.observe("SetTodoPosition", time.Now(), &err)
+	return r.next.SetTodoPosition(todoId, position)
+}
+
+func (r *Repository) SearchTodos(userId string, query string, limit int, offset int) (value []repository.TodoSearchRow, err error) {
+	defer r.observe("SearchTodos", time.Now(), &err)
+	return r.next.SearchTodos(userId, query, limit, offset)
+}
+
+func (r *Repository) TodoStats(userId string, from time.Time, to time.Time) (value repository.TodoStatsRow, err error) {
+	defer r.observe("TodoStats", time.Now(), &err)
+	return r.next.TodoStats(userId, from, to)
+}
+
+func (r *Repository) AttachmentsByTodo(todoId string) (value []repository.AttachmentRow, err error) {
+	defer r.observe("AttachmentsByTodo", time.Now(), &err)
+	return r.next.AttachmentsByTodo(todoId)
+}
+
+func (r *Repository) AddAttachment(row repository.AttachmentRow) (value bool, err error) {
+	defer r.observe("AddAttachment", time.Now(), &err)
+	return r.next.AddAttachment(row)
+}
+
+func (r *Repository) ShareTodo(todoId string, userId string, role repository.Role) (value bool, err error) {
+	defer r.observe("ShareTodo", time.Now(), &err)
+	return r.next.ShareTodo(todoId, userId, role)
+}
+
+func (r *Repository) UnshareTodo(todoId string, userId string) (value bool, err error) {
+	defer r.observe("UnshareTodo", time.Now(), &err)
+	return r.next.UnshareTodo(todoId, userId)
+}
+
+func (r *Repository) TodoCollaborators(todoId string) (value []repository.CollaboratorRow, err error) {
+	defer r.observe("TodoCollaborators", time.Now(), &err)
+	return r.next.TodoCollaborators(todoId)
+}
+
+func (r *Repository) TodoRole(todoId string, userId string) (value repository.Role, err error) {
+	defer r.observe("TodoRole", time.Now(), &err)
+	return r.next.TodoRole(todoId, userId)
+}
+
+func (r *Repository) ShareTodoList(listId string, userId string, role repository.Role) (value bool, err error) {
+	defer r.observe("ShareTodoList", time.Now(), &err)
+	return r.next.ShareTodoList(listId, userId, role)
+}
+
+func (r *Repository) UnshareTodoList(listId string, userId string) (value bool, err error) {
+	defer r.observe("UnshareTodoList", time.Now(), &err)
+	return r.next.UnshareTodoList(listId, userId)
+}
+
+func (r *Repository) TodoListCollaborators(listId string) (value []repository.CollaboratorRow, err error) {
+	defer r.observe("TodoListCollaborators", time.Now(), &err)
+	return r.next.TodoListCollaborators(listId)
+}
+
+func (r *Repository) TodoListRole(listId string, userId string) (value repository.Role, err error) {
+	defer r.observe("TodoListRole", time.Now(), &err)
+	return r.next.TodoListRole(listId, userId)
+}
+
+func (r *Repository) TodoHistory(todoId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	defer r.observe("TodoHistory", time.Now(), &err)
+	return r.next.TodoHistory(todoId, limit, offset)
+}
+
+func (r *Repository) ActivityByUser(userId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	defer r.observe("ActivityByUser", time.Now(), &err)
+	return r.next.ActivityByUser(userId, limit, offset)
+}
+
+func (r *Repository) OperationHistory(operationId string) (value []repository.HistoryRow, err error) {
+	defer r.observe("OperationHistory", time.Now(), &err)
+	return r.next.OperationHistory(operationId)
+}
+
+func (r *Repository) UndoOperation(operationId string) (value bool, err error) {
+	defer r.observe("UndoOperation", time.Now(), &err)
+	return r.next.UndoOperation(operationId)
+}
+
+func (r *Repository) RedoOperation(operationId string) (value bool, err error) {
+	defer r.observe("RedoOperation", time.Now(), &err)
+	return r.next.RedoOperation(operationId)
+}
+
+func (r *Repository) TodoVersion(todoId string) (value int64, err error) {
+	defer r.observe("TodoVersion", time.Now(), &err)
+	return r.next.TodoVersion(todoId)
+}
+
+func (r *Repository) TodoChangesSince(userId string, since int64, limit int) (value []repository.TodoChangeRow, err error) {
+	defer r.observe("TodoChangesSince", time.Now(), &err)
+	return r.next.TodoChangesSince(userId, since, limit)
+}
+
+func (r *Repository) SyncTodo(change repository.TodoSync) (applied bool, version int64, err error) {
+	defer r.observe("SyncTodo", time.Now(), &err)
+	return r.next.SyncTodo(change)
+}
+
+func (r *Repository) ReserveIdempotencyKey(row repository.IdempotencyRow, lease time.Duration) (value bool, err error) {
+	defer r.observe("ReserveIdempotencyKey", time.Now(), &err)
+	return r.next.ReserveIdempotencyKey(row, lease)
+}
+
+func (r *Repository) IdempotencyKey(userId string, key string) (value repository.IdempotencyRow, err error) {
+	defer r.observe("IdempotencyKey", time.Now(), &err)
+	return r.next.IdempotencyKey(userId, key)
+}
+
+func (r *Repository) SaveIdempotencyResult(userId string, key string, result []byte) (value bool, err error) {
+	defer r.observe("SaveIdempotencyResult", time.Now(), &err)
+	return r.next.SaveIdempotencyResult(userId, key, result)
+}
+
+func (r *Repository) ReleaseIdempotencyKey(userId string, key string) (value bool, err error) {
+	defer r.observe("ReleaseIdempotencyKey", time.Now(), &err)
+	return r.next.ReleaseIdempotencyKey(userId, key)
+}
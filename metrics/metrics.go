@@ -0,0 +1,100 @@
+// Package metrics collects Prometheus metrics of the GraphQL operations, the
+// resolvers and the repository of the service.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "todos"
+
+// Metrics are the collectors of the service, registered with one registry.
+type Metrics struct {
+	registry *prometheus.Registry
+	// allowedOperations are the operation names used as labels, if any.
+	allowedOperations map[string]bool
+	// mu guards rootFieldLabels, the labels given to operations by the root
+	// fields they select when no operation names are allowed.
+	mu              sync.Mutex
+	rootFieldLabels map[string]bool
+
+	operations        *prometheus.CounterVec
+	operationDuration *prometheus.HistogramVec
+	resolverErrors    *prometheus.CounterVec
+	repositoryCalls   *prometheus.HistogramVec
+}
+
+// New returns metrics registered with a new registry, along with the
+// metrics of the Go runtime and of the process. Operations are labelled with
+// the root fields they select, such as "todos" or "createTodo", or, given
+// operations, with their name when it is one of them, and as "other"
+// otherwise.
+func New(operations ...string) *Metrics {
+	m := &Metrics{
+		registry:          prometheus.NewRegistry(),
+		allowedOperations: make(map[string]bool, len(operations)),
+		rootFieldLabels:   map[string]bool{},
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "graphql",
+			Name:      "operations_total",
+			Help:      "GraphQL operations by name, type and whether their response had errors.",
+		}, []string{"operation", "type", "status"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "graphql",
+			Name:      "operation_duration_seconds",
+			Help:      "Time from reading a GraphQL operation to its response, by name and type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "type"}),
+		resolverErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "graphql",
+			Name:      "resolver_errors_total",
+			Help:      "Errors returned by resolvers, by object and field.",
+		}, []string{"object", "field"}),
+		repositoryCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "call_duration_seconds",
+			Help:      "Duration of repository calls, by method and whether they failed.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method", "status"}),
+	}
+	for _, name := range operations {
+		m.allowedOperations[name] = true
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.operations, m.operationDuration, m.resolverErrors, m.repositoryCalls,
+	)
+	return m
+}
+
+// Register registers more collectors, such as those of database pools.
+func (m *Metrics) Register(more ...prometheus.Collector) error {
+	for _, collector := range more {
+		if err := m.registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func status(failed bool) string {
+	if failed {
+		return "error"
+	}
+	return "ok"
+}
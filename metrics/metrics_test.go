@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/chloexu/hackernews/repository/cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// memoryRepository fails TodoByID for unknown ids.
+type memoryRepository struct {
+	repository.Repository
+	todos map[string]repository.TodoRow
+}
+
+func (m *memoryRepository) TodoByID(id string) (repository.TodoRow, error) {
+	todo, ok := m.todos[id]
+	if !ok {
+		return todo, errors.New("TodoByID row scan: no row.")
+	}
+	return todo, nil
+}
+
+func (m *memoryRepository) WithActor(actorId string) repository.Repository {
+	return m
+}
+
+func operationContext(name string, operation ast.Operation) context.Context {
+	opCtx := &graphql.OperationContext{Operation: &ast.OperationDefinition{Name: name, Operation: operation}}
+	opCtx.Stats.OperationStart = time.Now().Add(-time.Second)
+	return graphql.WithOperationContext(context.Background(), opCtx)
+}
+
+func TestExtensionCountsOperations(t *testing.T) {
+	m := New("Todos", "CreateTodo")
+	ext := m.Extension()
+	ok := func(ctx context.Context) *graphql.Response { return &graphql.Response{} }
+	failed := func(ctx context.Context) *graphql.Response {
+		return &graphql.Response{Errors: gqlerror.List{gqlerror.Errorf("failed")}}
+	}
+
+	ext.InterceptResponse(operationContext("Todos", ast.Query), ok)
+	ext.InterceptResponse(operationContext("Todos", ast.Query), ok)
+	ext.InterceptResponse(operationContext("CreateTodo", ast.Mutation), failed)
+	ext.InterceptResponse(operationContext(strings.Repeat("x", 100), ast.Query), ok)
+	ext.InterceptResponse(operationContext("Todos2", ast.Query), ok)
+
+	tests := []struct {
+		name                  string
+		operation, kind, stat string
+		want                  float64
+	}{
+		{"test successful queries should be counted by name", "Todos", "query", "ok", 2},
+		{"test failed mutations should be counted as errors", "CreateTodo", "mutation", "error", 1},
+		{"test unknown names should be counted as other", "other", "query", "ok", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.ToFloat64(m.operations.WithLabelValues(tt.operation, tt.kind, tt.stat)); got != tt.want {
+				t.Errorf("operations{%q, %q, %q} = %v, want %v", tt.operation, tt.kind, tt.stat, got, tt.want)
+			}
+		})
+	}
+	if got := testutil.CollectAndCount(m.operationDuration); got != 3 {
+		t.Errorf("operation durations collected %d series, want 3", got)
+	}
+}
+
+func rootFieldsContext(operation ast.Operation, fields ...string) context.Context {
+	var set ast.SelectionSet
+	for _, name := range fields {
+		set = append(set, &ast.Field{Alias: name, Name: name})
+	}
+	opCtx := &graphql.OperationContext{Operation: &ast.OperationDefinition{Name: "Client", Operation: operation, SelectionSet: set}}
+	return graphql.WithOperationContext(context.Background(), opCtx)
+}
+
+func TestExtensionLabelsOperationsByRootFields(t *testing.T) {
+	m := New()
+	ext := m.Extension()
+	ok := func(ctx context.Context) *graphql.Response { return &graphql.Response{} }
+
+	ext.InterceptResponse(rootFieldsContext(ast.Query, "todos"), ok)
+	ext.InterceptResponse(rootFieldsContext(ast.Query, "todos", "todoLists", "todos"), ok)
+	ext.InterceptResponse(rootFieldsContext(ast.Mutation, "createTodo"), ok)
+	for i := 0; i < maxRootFieldLabels; i++ {
+		ext.InterceptResponse(rootFieldsContext(ast.Query, fmt.Sprintf("field%d", i)), ok)
+	}
+
+	tests := []struct {
+		name            string
+		operation, kind string
+		want            float64
+	}{
+		{"test an operation should be labelled by its root field", "todos", "query", 1},
+		{"test root fields should be labelled sorted and once each", "todoLists,todos", "query", 1},
+		{"test mutations should be labelled by their root field", "createTodo", "mutation", 1},
+		{"test labels past the cap should be counted as other", "other", "query", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.ToFloat64(m.operations.WithLabelValues(tt.operation, tt.kind, "ok")); got != tt.want {
+				t.Errorf("operations{%q, %q} = %v, want %v", tt.operation, tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtensionCountsResolverErrors(t *testing.T) {
+	m := New()
+	ext := m.Extension()
+	fc := &graphql.FieldContext{Object: "Mutation", Field: graphql.CollectedField{Field: &ast.Field{Name: "createTodo"}}}
+	ctx := graphql.WithFieldContext(context.Background(), fc)
+
+	ext.InterceptField(ctx, func(ctx context.Context) (interface{}, error) { return nil, errors.New("failed") })
+	ext.InterceptField(ctx, func(ctx context.Context) (interface{}, error) { return "todo", nil })
+
+	if got := testutil.ToFloat64(m.resolverErrors.WithLabelValues("Mutation", "createTodo")); got != 1 {
+		t.Errorf("resolver errors of Mutation.createTodo = %v, want 1", got)
+	}
+}
+
+func TestRepositoryTimesCalls(t *testing.T) {
+	m := New()
+	r := m.Repository(&memoryRepository{todos: map[string]repository.TodoRow{"id": {ID: "id"}}})
+
+	if _, err := r.TodoByID("id"); err != nil {
+		t.Fatalf("Repository.TodoByID() error = %v", err)
+	}
+	if _, err := r.WithActor("user").TodoByID("missing"); err == nil {
+		t.Fatal("Repository.TodoByID() of a missing todo error = nil")
+	}
+
+	out := httptest.NewRecorder()
+	m.Handler().ServeHTTP(out, httptest.NewRequest("GET", "/metrics", nil))
+	body := out.Body.String()
+	for _, want := range []string{
+		`todos_repository_call_duration_seconds_count{method="TodoByID",status="ok"} 1`,
+		`todos_repository_call_duration_seconds_count{method="TodoByID",status="error"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %s", want)
+		}
+	}
+}
+
+func TestCacheCollectsStats(t *testing.T) {
+	m := New()
+	cached := cache.New(&memoryRepository{todos: map[string]repository.TodoRow{"id": {ID: "id"}}}, 10, time.Minute)
+	if err := m.Register(Cache(cached)...); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	cached.TodoByID("id")
+	cached.TodoByID("id")
+	cached.TodoByID("id")
+
+	out := httptest.NewRecorder()
+	m.Handler().ServeHTTP(out, httptest.NewRequest("GET", "/metrics", nil))
+	body := out.Body.String()
+	for _, want := range []string{
+		"todos_repository_cache_hits_total 2",
+		"todos_repository_cache_misses_total 1",
+		"todos_repository_cache_entries 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+//go:generate go run github.com/chloexu/hackernews/repository/decorate -template metrics -interface ../repository/repository.go
+
+// Repository times the operations of the repository it wraps.
+type Repository struct {
+	next    repository.Repository
+	metrics *Metrics
+}
+
+// Repository returns next decorated to time its operations.
+func (m *Metrics) Repository(next repository.Repository) *Repository {
+	return &Repository{next: next, metrics: m}
+}
+
+// observe records the duration of the operation name started at start. It
+// is deferred, so it reads the error the operation returned through err.
+func (r *Repository) observe(name string, start time.Time, err *error) {
+	r.metrics.repositoryCalls.WithLabelValues(name, status(*err != nil)).Observe(time.Since(start).Seconds())
+}
+
+func (r *Repository) WithActor(actorId string) repository.Repository {
+	return r.metrics.Repository(r.next.WithActor(actorId))
+}
+
+func (r *Repository) WithOperation(operationId string) repository.Repository {
+	return r.metrics.Repository(r.next.WithOperation(operationId))
+}
+
+func (r *Repository) WithContext(ctx context.Context) repository.Repository {
+	return r.metrics.Repository(r.next.WithContext(ctx))
+}
+
+func (r *Repository) Primary() repository.Repository {
+	return r.metrics.Repository(r.next.Primary())
+}
+
+func (r *Repository) Close() {
+	r.next.Close()
+}
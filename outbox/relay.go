@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// batchSize is the most events a relay sends to its sink at once.
+const batchSize = 100
+
+// Relay delivers the events of an outbox to a sink, oldest first, and marks
+// them sent once the sink accepted them. Events are sent again until that
+// happens, so a sink sees every event at least once. Only one relay should
+// run per outbox, since two would deliver events out of order.
+type Relay struct {
+	outbox   repository.OutboxRepository
+	sink     Sink
+	interval time.Duration
+}
+
+// NewRelay returns a relay that looks for new events every interval.
+func NewRelay(outbox repository.OutboxRepository, sink Sink, interval time.Duration) *Relay {
+	return &Relay{outbox: outbox, sink: sink, interval: interval}
+}
+
+// Run relays events until ctx is done. Batches follow each other right away
+// while the outbox is backed up, and failures are retried after interval.
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		sent, err := r.RelayOnce(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error().Err(err).Msg("outbox relay")
+		}
+		if err == nil && sent == batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// RelayOnce sends one batch of unsent events and returns how many it sent.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.outbox.UnsentEvents(batchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := r.sink.Send(ctx, events); err != nil {
+		return 0, err
+	}
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	// when marking fails the events are sent again, which sinks allow for
+	if _, err := r.outbox.MarkEventsSent(ids); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
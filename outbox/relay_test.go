@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// memoryOutbox is an outbox kept in memory.
+type memoryOutbox struct {
+	events []repository.EventRow
+	sent   map[int64]bool
+}
+
+func (o *memoryOutbox) UnsentEvents(limit int) ([]repository.EventRow, error) {
+	var unsent []repository.EventRow
+	for _, event := range o.events {
+		if !o.sent[event.ID] && len(unsent) < limit {
+			unsent = append(unsent, event)
+		}
+	}
+	return unsent, nil
+}
+
+func (o *memoryOutbox) MarkEventsSent(ids []int64) (int64, error) {
+	for _, id := range ids {
+		o.sent[id] = true
+	}
+	return int64(len(ids)), nil
+}
+
+// flakySink fails its first send.
+type flakySink struct {
+	failed bool
+	got    []int64
+}
+
+func (s *flakySink) Send(ctx context.Context, events []repository.EventRow) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("sink unavailable")
+	}
+	for _, event := range events {
+		s.got = append(s.got, event.ID)
+	}
+	return nil
+}
+
+func testEvents() []repository.EventRow {
+	createdAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	return []repository.EventRow{
+		{ID: 1, Type: "todo.create", EntityType: "todo", EntityID: "caajol287d5nsfvlcdd0", Payload: []byte(`{"action":"create"}`), CreatedAt: createdAt},
+		{ID: 2, Type: "todo.complete", EntityType: "todo", EntityID: "caajol287d5nsfvlcdd0", Payload: []byte(`{"action":"complete"}`), CreatedAt: createdAt},
+	}
+}
+
+func TestRelayOnceRetriesFailedEvents(t *testing.T) {
+	outbox := &memoryOutbox{events: testEvents(), sent: map[int64]bool{}}
+	sink := &flakySink{}
+	relay := NewRelay(outbox, sink, time.Second)
+
+	if _, err := relay.RelayOnce(context.Background()); err == nil {
+		t.Fatalf("Relay.RelayOnce() error = nil, want the sink error")
+	}
+	if len(outbox.sent) != 0 {
+		t.Fatalf("Relay.RelayOnce() marked %v sent after a failed send", outbox.sent)
+	}
+	sent, err := relay.RelayOnce(context.Background())
+	if err != nil || sent != 2 {
+		t.Fatalf("Relay.RelayOnce() = %v, %v, want 2, nil", sent, err)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(sink.got, want) {
+		t.Errorf("sink got events %v, want %v", sink.got, want)
+	}
+	if sent, err := relay.RelayOnce(context.Background()); err != nil || sent != 0 {
+		t.Errorf("Relay.RelayOnce() = %v, %v, want 0, nil once all events are sent", sent, err)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var out strings.Builder
+	if err := NewWriterSink(&out).Send(context.Background(), testEvents()[:1]); err != nil {
+		t.Fatalf("WriterSink.Send() error = %v", err)
+	}
+	want := `{"id":1,"type":"todo.create","entityType":"todo","entityId":"caajol287d5nsfvlcdd0",` +
+		`"createdAt":"2022-05-20T14:00:00Z","data":{"action":"create"}}` + "\n"
+	if out.String() != want {
+		t.Errorf("WriterSink.Send() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var got []envelope
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("receiver decode %q: %v", body, err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	sink := NewHTTPSink(receiver.URL, receiver.Client())
+	if err := sink.Send(context.Background(), testEvents()); err != nil {
+		t.Fatalf("HTTPSink.Send() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].Type != "todo.complete" {
+		t.Errorf("receiver got %+v, want both events in order", got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := sink.Send(context.Background(), testEvents()); err == nil {
+		t.Errorf("HTTPSink.Send() error = nil, want an error for status %d", status)
+	}
+}
@@ -0,0 +1,159 @@
+// Package outbox relays the events written to the outbox table, along with
+// every change, to sinks outside the service.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// Sink receives events, in the order they were written. An event may be sent
+// to a sink more than once, so consumers should skip ids they have seen.
+type Sink interface {
+	Send(ctx context.Context, events []repository.EventRow) error
+}
+
+// envelope is an event as sinks write it.
+type envelope struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func envelopes(events []repository.EventRow) []envelope {
+	wrapped := make([]envelope, len(events))
+	for i, event := range events {
+		wrapped[i] = envelope{ID: event.ID, Type: event.Type, EntityType: event.EntityType, EntityID: event.EntityID,
+			CreatedAt: event.CreatedAt, Data: event.Payload}
+	}
+	return wrapped
+}
+
+// NewSink returns the sink described by spec: "stdout", "file:" followed by
+// a path, or an http or https URL.
+func NewSink(spec string) (Sink, error) {
+	switch {
+	case spec == "stdout":
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSink(strings.TrimPrefix(spec, "file:"))
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewHTTPSink(spec, nil), nil
+	}
+	return nil, fmt.Errorf("NewSink unknown sink %q", spec)
+}
+
+// MultiSink sends events to each of its sinks in turn. When one fails, all
+// of them get the events again.
+type MultiSink []Sink
+
+func (m MultiSink) Send(ctx context.Context, events []repository.EventRow) error {
+	for _, sink := range m {
+		if err := sink.Send(ctx, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriterSink writes each event as a line of JSON.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(ctx context.Context, events []repository.EventRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	encoder := json.NewEncoder(s.w)
+	for _, event := range envelopes(events) {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("WriterSink encode event %d: %v", event.ID, err)
+		}
+	}
+	return nil
+}
+
+// FileSink appends events to a file as lines of JSON, and syncs the file
+// before they count as sent.
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("NewFileSink open %q: %v", path, err)
+	}
+	return &FileSink{WriterSink: NewWriterSink(file), file: file}, nil
+}
+
+func (s *FileSink) Send(ctx context.Context, events []repository.EventRow) error {
+	if err := s.WriterSink.Send(ctx, events); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("FileSink sync %q: %v", s.file.Name(), err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink posts each batch of events to a URL as a JSON array. Any status
+// other than 2xx fails the batch, to be sent again.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a sink posting to url with client, or with a client
+// timing out after 10 seconds when client is nil.
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSink{url: url, client: client}
+}
+
+func (s *HTTPSink) Send(ctx context.Context, events []repository.EventRow) error {
+	body, err := json.Marshal(envelopes(events))
+	if err != nil {
+		return fmt.Errorf("HTTPSink encode events: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("HTTPSink new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTPSink post %q: %v", s.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTPSink post %q: status %s", s.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package rank generates fractional ordering keys. A key is a string of
+// base-62 digits read as the fraction 0.<digits>, so keys compare the same
+// way as strings and there is always room for another key between two
+// others: placing an item never requires renumbering its neighbours.
+package rank
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Digits are the key digits in ascending byte order. Keys must be compared
+// bytewise, e.g. with a binary collation in MySQL.
+const Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(Digits)
+
+// Between returns a key that sorts strictly after before and strictly before
+// after. An empty before means the start of the order and an empty after its
+// end, so Between("", "") yields a first key and Between(last, "") appends.
+func Between(before string, after string) (string, error) {
+	if err := validate(before); err != nil {
+		return "", err
+	}
+	if err := validate(after); err != nil {
+		return "", err
+	}
+	if after != "" && before >= after {
+		return "", fmt.Errorf("rank: %q is not before %q", before, after)
+	}
+	return midpoint(before, after), nil
+}
+
+// midpoint assumes a < b, reading an empty b as 1.
+func midpoint(a string, b string) string {
+	if b != "" {
+		// keep the prefix both keys share, reading missing digits of a as 0
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	// the first digits differ
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(Digits, a[0])
+	}
+	digitB := base
+	if b != "" {
+		digitB = strings.IndexByte(Digits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(Digits[(digitA+digitB+1)/2])
+	}
+	// the first digits are adjacent
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(Digits[digitA]) + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return Digits[0]
+}
+
+// validate rejects keys with foreign characters and keys ending in the zero
+// digit, which would equal a shorter key in value but not in byte order.
+func validate(key string) error {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(Digits, key[i]) < 0 {
+			return fmt.Errorf("rank: invalid character %q in key %q", key[i], key)
+		}
+	}
+	if strings.HasSuffix(key, Digits[:1]) {
+		return fmt.Errorf("rank: key %q must not end with %q", key, Digits[:1])
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		want    string
+		wantErr bool
+	}{
+		{"first key", "", "", "V", false},
+		{"append", "V", "", "l", false},
+		{"prepend", "", "V", "G", false},
+		{"between distant digits", "A", "C", "B", false},
+		{"between adjacent digits", "A", "B", "AV", false},
+		{"between adjacent digits with a longer after", "A", "BV", "B", false},
+		{"after a long key", "zz", "", "zzV", false},
+		{"before a key with leading zeros", "", "01", "00V", false},
+		{"shared prefix", "AB", "AD", "AC", false},
+		{"before is not less than after", "B", "A", "", true},
+		{"equal keys", "B", "B", "", true},
+		{"trailing zero", "A0", "", "", true},
+		{"foreign character", "A-", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.before, tt.after)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Between() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Between() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	// insert at random places and check every key stays valid and ordered
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		at := rng.Intn(len(keys) + 1)
+		before, after := "", ""
+		if at > 0 {
+			before = keys[at-1]
+		}
+		if at < len(keys) {
+			after = keys[at]
+		}
+		key, err := Between(before, after)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) error = %v", before, after, err)
+		}
+		if key <= before || (after != "" && key >= after) {
+			t.Fatalf("Between(%q, %q) = %q, out of order", before, after, key)
+		}
+		if err := validate(key); err != nil {
+			t.Fatalf("Between(%q, %q) = %q, invalid: %v", before, after, key, err)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Errorf("keys are not sorted")
+	}
+}
+
+func TestBetweenRepeatedInsertsStayShort(t *testing.T) {
+	// always inserting right after the first key grows keys slowly
+	before, after := "V", "l"
+	for i := 0; i < 100; i++ {
+		key, err := Between(before, after)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) error = %v", before, after, err)
+		}
+		after = key
+	}
+	if len(after) > 20 {
+		t.Errorf("key after 100 inserts has length %d, want at most 20", len(after))
+	}
+}
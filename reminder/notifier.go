@@ -0,0 +1,138 @@
+// Package reminder sends the reminders of todos when they are due, through a
+// notifier for each channel.
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// Notifier sends a reminder to its user.
+type Notifier interface {
+	Notify(ctx context.Context, reminder repository.ReminderRow) error
+}
+
+// LogNotifier writes reminders to the logger of the context they are sent
+// with.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, reminder repository.ReminderRow) error {
+	logging.FromContext(ctx).Info().
+		Str("reminder_id", reminder.ID).
+		Str("user_id", reminder.UserID).
+		Str("todo_id", reminder.TodoID).
+		Msg(summary(reminder))
+	return nil
+}
+
+// summary is the text of a reminder: the todo, and when it is due.
+func summary(reminder repository.ReminderRow) string {
+	if reminder.TodoDueAt.IsZero() {
+		return reminder.TodoText
+	}
+	return fmt.Sprintf("%s (due %s)", reminder.TodoText, reminder.TodoDueAt.UTC().Format(time.RFC1123))
+}
+
+// SMTPNotifier mails reminders to their address through an SMTP server,
+// upgrading the connection with STARTTLS when the server offers it.
+type SMTPNotifier struct {
+	addr    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+// NewSMTPNotifier returns a notifier mailing from the address from through
+// the server at addr, a host and port, authenticating with auth unless it is
+// nil.
+func NewSMTPNotifier(addr string, from string, auth smtp.Auth) *SMTPNotifier {
+	return &SMTPNotifier{addr: addr, from: from, auth: auth, timeout: 30 * time.Second}
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, reminder repository.ReminderRow) error {
+	if reminder.Address == "" {
+		return fmt.Errorf("SMTPNotifier reminder %q has no address", reminder.ID)
+	}
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return fmt.Errorf("SMTPNotifier address %q: %v", n.addr, err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return fmt.Errorf("SMTPNotifier dial %q: %v", n.addr, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(n.timeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTPNotifier hello %q: %v", n.addr, err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("SMTPNotifier starttls %q: %v", n.addr, err)
+		}
+	}
+	if n.auth != nil {
+		if err := client.Auth(n.auth); err != nil {
+			return fmt.Errorf("SMTPNotifier auth %q: %v", n.addr, err)
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return fmt.Errorf("SMTPNotifier mail from %q: %v", n.from, err)
+	}
+	if err := client.Rcpt(reminder.Address); err != nil {
+		return fmt.Errorf("SMTPNotifier rcpt to %q: %v", reminder.Address, err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTPNotifier data: %v", err)
+	}
+	if _, err := w.Write(n.message(reminder)); err != nil {
+		return fmt.Errorf("SMTPNotifier write message: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTPNotifier send message: %v", err)
+	}
+	return client.Quit()
+}
+
+// message is the mail of a reminder. The todo text goes into the subject
+// encoded, so that it cannot add headers.
+func (n *SMTPNotifier) message(reminder repository.ReminderRow) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", reminder.Address)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+oneLine(reminder.TodoText)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(oneLine(summary(reminder)))
+	msg.WriteString("\r\n")
+	return msg.Bytes()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,125 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+	"github.com/rs/zerolog"
+)
+
+// message is a mail the fake SMTP server received.
+type message struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP serves one SMTP session on a local port and sends the mail it
+// received on the returned channel.
+func fakeSMTP(t *testing.T) (string, <-chan message) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan message, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var msg message
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch verb {
+			case "EHLO", "HELO":
+				text.PrintfLine("250-localhost")
+				text.PrintfLine("250 8BITMIME")
+			case "MAIL":
+				msg.from = strings.Trim(strings.Fields(strings.TrimPrefix(line, "MAIL FROM:"))[0], "<>")
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				received <- msg
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	reminder := repository.ReminderRow{ID: "caajol287d5nsremd001", UserID: "user1", Channel: repository.ChannelEmail,
+		Address: "gopher@example.com", TodoText: "File taxes\r\nBcc: everyone@example.com",
+		TodoDueAt: time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)}
+
+	if err := NewSMTPNotifier(addr, "reminders@example.com", nil).Notify(context.Background(), reminder); err != nil {
+		t.Fatalf("SMTPNotifier.Notify() error = %v", err)
+	}
+	select {
+	case msg := <-received:
+		if msg.from != "reminders@example.com" || len(msg.to) != 1 || msg.to[0] != "gopher@example.com" {
+			t.Errorf("server got mail from %q to %q", msg.from, msg.to)
+		}
+		headers, body := msg.data, ""
+		if i := strings.Index(msg.data, "\n\n"); i >= 0 {
+			headers, body = msg.data[:i], msg.data[i+2:]
+		}
+		if strings.Contains("\n"+headers, "\nBcc:") {
+			t.Errorf("todo text added a header:\n%s", headers)
+		}
+		if want := "File taxes Bcc: everyone@example.com (due Fri, 20 May 2022 14:00:00 UTC)"; !strings.Contains(body, want) {
+			t.Errorf("body = %q, want it to contain %q", body, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server got no mail")
+	}
+}
+
+func TestSMTPNotifierWithoutAddress(t *testing.T) {
+	if err := NewSMTPNotifier("127.0.0.1:25", "reminders@example.com", nil).Notify(context.Background(), repository.ReminderRow{ID: "r"}); err == nil {
+		t.Error("SMTPNotifier.Notify() of a reminder without address succeeded")
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var out bytes.Buffer
+	ctx := zerolog.New(&out).WithContext(context.Background())
+	reminder := repository.ReminderRow{ID: "caajol287d5nsremd001", UserID: "user1", TodoID: "todo1", TodoText: "File taxes"}
+	if err := NewLogNotifier().Notify(ctx, reminder); err != nil {
+		t.Fatalf("LogNotifier.Notify() error = %v", err)
+	}
+	want := `{"level":"info","reminder_id":"caajol287d5nsremd001","user_id":"user1","todo_id":"todo1","message":"File taxes"}` + "\n"
+	if out.String() != want {
+		t.Errorf("LogNotifier wrote %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,127 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// MaxAttempts is how often a reminder is tried before it failed.
+const MaxAttempts = 5
+
+// Lease is how long a scheduler holds the reminders it claims. A reminder
+// whose scheduler stopped before recording the attempt is claimed again once
+// the lease is over, so a reminder may be sent twice but is not lost.
+const Lease = 5 * time.Minute
+
+// firstRetryDelay is the wait after the first failed attempt, doubling with
+// every further one.
+const firstRetryDelay = time.Minute
+
+// batchSize is the most reminders a scheduler claims at once.
+const batchSize = 50
+
+// maxErrorLength is the longest error kept with a reminder.
+const maxErrorLength = 1024
+
+// RetryDelay is the wait before the next attempt at a reminder that failed
+// attempts times.
+func RetryDelay(attempts int) time.Duration {
+	return firstRetryDelay << (attempts - 1)
+}
+
+// Scheduler sends reminders when they are due. Any number of schedulers may
+// run against the same database, each claiming its own reminders.
+type Scheduler struct {
+	reminders repository.ReminderRepository
+	notifiers map[repository.ReminderChannel]Notifier
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+func NewScheduler(reminders repository.ReminderRepository, notifiers map[repository.ReminderChannel]Notifier) *Scheduler {
+	return &Scheduler{reminders: reminders, notifiers: notifiers, now: time.Now}
+}
+
+// Run sends due reminders every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	for {
+		sent, err := s.FireDue(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error().Err(err).Msg("reminder scheduler")
+		}
+		if err == nil && sent == batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// FireDue claims the due reminders, up to a batch, makes one attempt at
+// each and returns how many it claimed.
+func (s *Scheduler) FireDue(ctx context.Context) (int, error) {
+	due, err := s.reminders.ClaimDueReminders(s.now(), Lease, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, reminder := range due {
+		err := s.fire(ctx, reminder)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if _, err := s.reminders.RecordReminderAttempt(s.attempted(reminder, err)); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// errCancelled is the outcome of a reminder of a todo that is done or
+// deleted, which is not sent.
+var errCancelled = fmt.Errorf("todo is no longer open")
+
+func (s *Scheduler) fire(ctx context.Context, reminder repository.ReminderRow) error {
+	if !reminder.TodoOpen {
+		return errCancelled
+	}
+	notifier, ok := s.notifiers[reminder.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %q", reminder.Channel)
+	}
+	return notifier.Notify(ctx, reminder)
+}
+
+// attempted returns the reminder after an attempt that failed with err, or
+// succeeded when err is nil.
+func (s *Scheduler) attempted(reminder repository.ReminderRow, err error) repository.ReminderRow {
+	now := s.now()
+	reminder.NextAttemptAt = time.Time{}
+	if err == errCancelled {
+		reminder.Status = repository.ReminderCancelled
+		return reminder
+	}
+	reminder.Attempts++
+	reminder.LastError = ""
+	if err == nil {
+		reminder.Status = repository.ReminderSent
+		reminder.SentAt = now
+		return reminder
+	}
+	reminder.LastError = err.Error()
+	if len(reminder.LastError) > maxErrorLength {
+		reminder.LastError = reminder.LastError[:maxErrorLength]
+	}
+	if reminder.Attempts >= MaxAttempts {
+		reminder.Status = repository.ReminderFailed
+		return reminder
+	}
+	reminder.NextAttemptAt = now.Add(RetryDelay(reminder.Attempts))
+	return reminder
+}
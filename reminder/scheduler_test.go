@@ -0,0 +1,126 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// memoryReminders keeps reminders in memory.
+type memoryReminders struct {
+	repository.ReminderRepository
+	reminders []repository.ReminderRow
+}
+
+func (m *memoryReminders) ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]repository.ReminderRow, error) {
+	var due []repository.ReminderRow
+	for i := range m.reminders {
+		reminder := &m.reminders[i]
+		if reminder.Status == repository.ReminderPending && !reminder.NextAttemptAt.After(now) && len(due) < limit {
+			reminder.NextAttemptAt = now.Add(lease)
+			due = append(due, *reminder)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryReminders) RecordReminderAttempt(row repository.ReminderRow) (bool, error) {
+	for i := range m.reminders {
+		if m.reminders[i].ID == row.ID {
+			m.reminders[i] = row
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notifierFunc is a notifier calling a function.
+type notifierFunc func(reminder repository.ReminderRow) error
+
+func (f notifierFunc) Notify(ctx context.Context, reminder repository.ReminderRow) error {
+	return f(reminder)
+}
+
+func TestSchedulerFiresDueReminders(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	reminders := &memoryReminders{reminders: []repository.ReminderRow{
+		{ID: "due", Channel: repository.ChannelLog, Status: repository.ReminderPending, NextAttemptAt: now, TodoOpen: true},
+		{ID: "later", Channel: repository.ChannelLog, Status: repository.ReminderPending, NextAttemptAt: now.Add(time.Minute), TodoOpen: true},
+		{ID: "done", Channel: repository.ChannelLog, Status: repository.ReminderPending, NextAttemptAt: now, TodoOpen: false},
+	}}
+	var notified []string
+	scheduler := NewScheduler(reminders, map[repository.ReminderChannel]Notifier{
+		repository.ChannelLog: notifierFunc(func(reminder repository.ReminderRow) error {
+			notified = append(notified, reminder.ID)
+			return nil
+		}),
+	})
+	scheduler.now = func() time.Time { return now }
+
+	if fired, err := scheduler.FireDue(context.Background()); err != nil || fired != 2 {
+		t.Fatalf("Scheduler.FireDue() = %v, %v, want 2, nil", fired, err)
+	}
+	if len(notified) != 1 || notified[0] != "due" {
+		t.Errorf("Scheduler.FireDue() notified %q, want [due]", notified)
+	}
+	if got := reminders.reminders[0]; got.Status != repository.ReminderSent || got.Attempts != 1 || !got.SentAt.Equal(now) || !got.NextAttemptAt.IsZero() {
+		t.Errorf("due reminder = %+v, want sent", got)
+	}
+	if got := reminders.reminders[1]; got.Status != repository.ReminderPending {
+		t.Errorf("later reminder = %+v, want pending", got)
+	}
+	if got := reminders.reminders[2]; got.Status != repository.ReminderCancelled || got.Attempts != 0 {
+		t.Errorf("reminder of a done todo = %+v, want cancelled", got)
+	}
+}
+
+func TestSchedulerRetriesUntilFailed(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	reminders := &memoryReminders{reminders: []repository.ReminderRow{
+		{ID: "r", Channel: repository.ChannelEmail, Status: repository.ReminderPending, NextAttemptAt: now, TodoOpen: true},
+	}}
+	scheduler := NewScheduler(reminders, map[repository.ReminderChannel]Notifier{
+		repository.ChannelEmail: notifierFunc(func(reminder repository.ReminderRow) error {
+			return fmt.Errorf("connection refused")
+		}),
+	})
+	scheduler.now = func() time.Time { return now }
+
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		if fired, err := scheduler.FireDue(context.Background()); err != nil || fired != 1 {
+			t.Fatalf("attempt %d: Scheduler.FireDue() = %v, %v, want 1, nil", attempt, fired, err)
+		}
+		got := reminders.reminders[0]
+		if got.Attempts != attempt || got.LastError != "connection refused" {
+			t.Fatalf("attempt %d: reminder = %+v", attempt, got)
+		}
+		if attempt < MaxAttempts {
+			if want := now.Add(RetryDelay(attempt)); got.Status != repository.ReminderPending || !got.NextAttemptAt.Equal(want) {
+				t.Fatalf("attempt %d: reminder = %+v, want pending until %v", attempt, got, want)
+			}
+			now = got.NextAttemptAt
+		}
+	}
+	if got := reminders.reminders[0]; got.Status != repository.ReminderFailed {
+		t.Errorf("reminder after %d attempts = %+v, want failed", MaxAttempts, got)
+	}
+}
+
+func TestSchedulerWithoutNotifier(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	reminders := &memoryReminders{reminders: []repository.ReminderRow{
+		{ID: "r", Channel: repository.ChannelEmail, Status: repository.ReminderPending, NextAttemptAt: now, TodoOpen: true},
+	}}
+	scheduler := NewScheduler(reminders, nil)
+	scheduler.now = func() time.Time { return now }
+
+	if _, err := scheduler.FireDue(context.Background()); err != nil {
+		t.Fatalf("Scheduler.FireDue() error = %v", err)
+	}
+	if got := reminders.reminders[0]; got.Attempts != 1 || got.LastError == "" {
+		t.Errorf("reminder without notifier = %+v, want a failed attempt", got)
+	}
+}
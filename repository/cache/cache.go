@@ -0,0 +1,290 @@
+// Package cache decorates a repository with a cache of the todos read most,
+// by id and by user.
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// Stats counts the reads of a cache.
+type Stats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// counters are shared by a repository and those derived from it.
+type counters struct {
+	hits   uint64
+	misses uint64
+}
+
+// Repository caches TodoByID and TodosByUser of the repository it wraps, and
+// TodosByUserAndTags without tags.
+// Writes through it drop the entries they may change, and writes whose
+// effect cannot be told from their arguments drop every entry.
+//
+// Entries are kept for at most the TTL, which bounds how stale they get
+// through writes the cache does not see, such as those of other instances.
+type Repository struct {
+	repository.Repository
+	entries  *lru
+	counters *counters
+	// onPrimary skips the entries, which may have been read from replicas
+	// behind the primary, and reads from the primary.
+	onPrimary bool
+}
+
+const (
+	todoPrefix = "todo:"
+	userPrefix = "user:"
+)
+
+// New returns next with a cache of at most size entries, each kept for ttl.
+func New(next repository.Repository, size int, ttl time.Duration) *Repository {
+	return &Repository{Repository: next, entries: newLRU(size, ttl, time.Now), counters: &counters{}}
+}
+
+// Stats returns the hits and misses of the cache so far, and its size.
+func (r *Repository) Stats() Stats {
+	return Stats{
+		Hits:    atomic.LoadUint64(&r.counters.hits),
+		Misses:  atomic.LoadUint64(&r.counters.misses),
+		Entries: r.entries.len(),
+	}
+}
+
+func (r *Repository) WithActor(actorId string) repository.Repository {
+	return &Repository{Repository: r.Repository.WithActor(actorId), entries: r.entries, counters: r.counters, onPrimary: r.onPrimary}
+}
+
+func (r *Repository) WithOperation(operationId string) repository.Repository {
+	return &Repository{Repository: r.Repository.WithOperation(operationId), entries: r.entries, counters: r.counters, onPrimary: r.onPrimary}
+}
+
+func (r *Repository) WithContext(ctx context.Context) repository.Repository {
+	return &Repository{Repository: r.Repository.WithContext(ctx), entries: r.entries, counters: r.counters, onPrimary: r.onPrimary}
+}
+
+// Primary returns a repository that reads past the cache, from the primary,
+// and still drops the entries its writes change.
+func (r *Repository) Primary() repository.Repository {
+	return &Repository{Repository: r.Repository.Primary(), entries: r.entries, counters: r.counters, onPrimary: true}
+}
+
+func (r *Repository) TodoByID(id string) (repository.TodoRow, error) {
+	if r.onPrimary {
+		return r.Repository.TodoByID(id)
+	}
+	if cached, ok := r.entries.get(todoPrefix + id); ok {
+		atomic.AddUint64(&r.counters.hits, 1)
+		return cached.(repository.TodoRow), nil
+	}
+	atomic.AddUint64(&r.counters.misses, 1)
+	generation := r.entries.current()
+	todo, err := r.Repository.TodoByID(id)
+	if err != nil {
+		return todo, err
+	}
+	r.entries.put(todoPrefix+id, todo, generation)
+	return todo, nil
+}
+
+// TodosByUser returns a copy of the cached todos, so that callers may change
+// it.
+func (r *Repository) TodosByUser(userId string) ([]repository.TodoRow, error) {
+	if r.onPrimary {
+		return r.Repository.TodosByUser(userId)
+	}
+	if cached, ok := r.entries.get(userPrefix + userId); ok {
+		atomic.AddUint64(&r.counters.hits, 1)
+		return copyTodos(cached.([]repository.TodoRow)), nil
+	}
+	atomic.AddUint64(&r.counters.misses, 1)
+	generation := r.entries.current()
+	todos, err := r.Repository.TodosByUser(userId)
+	if err != nil {
+		return todos, err
+	}
+	r.entries.put(userPrefix+userId, copyTodos(todos), generation)
+	return todos, nil
+}
+
+// TodosByUserAndTags serves the todos of the user from the cache when no
+// tags are given, as they are then the todos of TodosByUser. The todos of
+// tags are not cached.
+func (r *Repository) TodosByUserAndTags(userId string, tags []string) ([]repository.TodoRow, error) {
+	if len(tags) == 0 {
+		return r.TodosByUser(userId)
+	}
+	return r.Repository.TodosByUserAndTags(userId, tags)
+}
+
+func copyTodos(todos []repository.TodoRow) []repository.TodoRow {
+	if todos == nil {
+		return nil
+	}
+	return append([]repository.TodoRow(nil), todos...)
+}
+
+// dropTodos drops the given todos, and the todos of every user who sees one
+// of them.
+func (r *Repository) dropTodos(ids ...string) {
+	changed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		changed[id] = true
+	}
+	r.entries.deleteIf(func(key string, value interface{}) bool {
+		if strings.HasPrefix(key, todoPrefix) {
+			return changed[strings.TrimPrefix(key, todoPrefix)]
+		}
+		for _, todo := range value.([]repository.TodoRow) {
+			if changed[todo.ID] {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// dropUser drops the todos of a user.
+func (r *Repository) dropUser(userId string) {
+	r.entries.delete(userPrefix + userId)
+}
+
+// dropAll drops every entry.
+func (r *Repository) dropAll() {
+	r.entries.deleteIf(func(string, interface{}) bool { return true })
+}
+
+// AddTodo drops the todos of the owner, or of everyone when the todo goes
+// into a list, which may be shared.
+func (r *Repository) AddTodo(row repository.TodoRow) (bool, error) {
+	defer func() {
+		if row.ListID != "" {
+			r.dropAll()
+		} else {
+			r.dropUser(row.UserID)
+		}
+	}()
+	return r.Repository.AddTodo(row)
+}
+
+func (r *Repository) UpdateTodo(row repository.TodoRow) (bool, error) {
+	defer r.dropTodos(row.ID)
+	return r.Repository.UpdateTodo(row)
+}
+
+func (r *Repository) UpdateTodos(userId string, ids []string, patch repository.TodoPatch) ([]repository.BulkResult, error) {
+	defer r.dropTodos(ids...)
+	return r.Repository.UpdateTodos(userId, ids, patch)
+}
+
+func (r *Repository) CompleteAll(userId string, filter repository.TodoFilter) ([]repository.BulkResult, error) {
+	results, err := r.Repository.CompleteAll(userId, filter)
+	if err != nil {
+		r.dropAll()
+		return results, err
+	}
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.TodoID)
+	}
+	r.dropTodos(ids...)
+	return results, nil
+}
+
+// DeleteTodo drops everything, as it deletes the subtasks of the todo too.
+func (r *Repository) DeleteTodo(id string) (bool, error) {
+	defer r.dropAll()
+	return r.Repository.DeleteTodo(id)
+}
+
+// DeleteTodoList drops everything, as the todos of the list leave it.
+func (r *Repository) DeleteTodoList(id string) (bool, error) {
+	defer r.dropAll()
+	return r.Repository.DeleteTodoList(id)
+}
+
+// MoveTodoToList drops everything, as the todo may move into a list shared
+// with other users.
+func (r *Repository) MoveTodoToList(todoId string, listId string) (bool, error) {
+	defer r.dropAll()
+	return r.Repository.MoveTodoToList(todoId, listId)
+}
+
+func (r *Repository) SetTodoParent(todoId string, parentId string) (bool, error) {
+	defer r.dropTodos(todoId)
+	return r.Repository.SetTodoParent(todoId, parentId)
+}
+
+// CompleteDescendants drops everything, as it completes todos it is not
+// given.
+func (r *Repository) CompleteDescendants(todoId string) (int64, error) {
+	defer r.dropAll()
+	return r.Repository.CompleteDescendants(todoId)
+}
+
+func (r *Repository) SetTodoDueAt(todoId string, dueAt time.Time) (bool, error) {
+	defer r.dropTodos(todoId)
+	return r.Repository.SetTodoDueAt(todoId, dueAt)
+}
+
+func (r *Repository) SetTodoPriority(todoId string, priority repository.Priority) (bool, error) {
+	defer r.dropTodos(todoId)
+	return r.Repository.SetTodoPriority(todoId, priority)
+}
+
+func (r *Repository) SetTodoRecurrence(todoId string, recurrence string) (bool, error) {
+	defer r.dropTodos(todoId)
+	return r.Repository.SetTodoRecurrence(todoId, recurrence)
+}
+
+func (r *Repository) SetTodoPosition(todoId string, position string) (bool, error) {
+	defer r.dropTodos(todoId)
+	return r.Repository.SetTodoPosition(todoId, position)
+}
+
+func (r *Repository) ShareTodo(todoId string, userId string, role repository.Role) (bool, error) {
+	defer r.dropUser(userId)
+	return r.Repository.ShareTodo(todoId, userId, role)
+}
+
+func (r *Repository) UnshareTodo(todoId string, userId string) (bool, error) {
+	defer r.dropUser(userId)
+	return r.Repository.UnshareTodo(todoId, userId)
+}
+
+func (r *Repository) ShareTodoList(listId string, userId string, role repository.Role) (bool, error) {
+	defer r.dropUser(userId)
+	return r.Repository.ShareTodoList(listId, userId, role)
+}
+
+func (r *Repository) UnshareTodoList(listId string, userId string) (bool, error) {
+	defer r.dropUser(userId)
+	return r.Repository.UnshareTodoList(listId, userId)
+}
+
+// UndoOperation drops everything, as an operation may have changed any
+// todo.
+func (r *Repository) UndoOperation(operationId string) (bool, error) {
+	defer r.dropAll()
+	return r.Repository.UndoOperation(operationId)
+}
+
+func (r *Repository) RedoOperation(operationId string) (bool, error) {
+	defer r.dropAll()
+	return r.Repository.RedoOperation(operationId)
+}
+
+// SyncTodo drops everything, as a change may delete subtasks or move the
+// todo into a shared list.
+func (r *Repository) SyncTodo(change repository.TodoSync) (bool, int64, error) {
+	defer r.dropAll()
+	return r.Repository.SyncTodo(change)
+}
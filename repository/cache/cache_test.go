@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// memoryRepository keeps todos in memory and counts its reads.
+type memoryRepository struct {
+	repository.Repository
+	todos  map[string]repository.TodoRow
+	shared map[string][]string // user id to the ids of todos shared with them
+	reads  int
+}
+
+func newMemoryRepository(todos ...repository.TodoRow) *memoryRepository {
+	m := &memoryRepository{todos: map[string]repository.TodoRow{}, shared: map[string][]string{}}
+	for _, todo := range todos {
+		m.todos[todo.ID] = todo
+	}
+	return m
+}
+
+func (m *memoryRepository) WithActor(actorId string) repository.Repository {
+	return m
+}
+
+func (m *memoryRepository) Primary() repository.Repository {
+	return m
+}
+
+func (m *memoryRepository) TodoByID(id string) (repository.TodoRow, error) {
+	m.reads++
+	todo, ok := m.todos[id]
+	if !ok {
+		return todo, fmt.Errorf("no todo %q", id)
+	}
+	return todo, nil
+}
+
+func (m *memoryRepository) TodosByUser(userId string) ([]repository.TodoRow, error) {
+	m.reads++
+	var todos []repository.TodoRow
+	for _, todo := range m.todos {
+		if todo.UserID == userId {
+			todos = append(todos, todo)
+		}
+	}
+	for _, id := range m.shared[userId] {
+		todos = append(todos, m.todos[id])
+	}
+	return todos, nil
+}
+
+// TodosByUserAndTags ignores the tags, which memoryRepository does not keep.
+func (m *memoryRepository) TodosByUserAndTags(userId string, tags []string) ([]repository.TodoRow, error) {
+	return m.TodosByUser(userId)
+}
+
+func (m *memoryRepository) UpdateTodo(row repository.TodoRow) (bool, error) {
+	m.todos[row.ID] = row
+	return true, nil
+}
+
+func (m *memoryRepository) ShareTodo(todoId string, userId string, role repository.Role) (bool, error) {
+	m.shared[userId] = append(m.shared[userId], todoId)
+	return true, nil
+}
+
+var groceries = repository.TodoRow{ID: "caajol287d5nstodo001", Text: "Buy groceries", UserID: "alice"}
+
+func TestRepositoryHitsAndMisses(t *testing.T) {
+	next := newMemoryRepository(groceries)
+	cached := New(next, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if todo, err := cached.TodoByID(groceries.ID); err != nil || todo != groceries {
+			t.Fatalf("Repository.TodoByID() = %+v, %v, want %+v", todo, err, groceries)
+		}
+	}
+	if _, err := cached.TodoByID("missing"); err == nil {
+		t.Error("Repository.TodoByID() of a missing todo succeeded")
+	}
+	if _, err := cached.TodoByID("missing"); err == nil {
+		t.Error("Repository.TodoByID() of a missing todo succeeded the second time")
+	}
+	if got, want := cached.Stats(), (Stats{Hits: 2, Misses: 3, Entries: 1}); got != want {
+		t.Errorf("Repository.Stats() = %+v, want %+v", got, want)
+	}
+	if next.reads != 3 {
+		t.Errorf("wrapped repository read %d times, want 3", next.reads)
+	}
+}
+
+func TestRepositoryExpiresEntries(t *testing.T) {
+	next := newMemoryRepository(groceries)
+	cached := New(next, 10, time.Minute)
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	cached.entries.now = func() time.Time { return now }
+
+	cached.TodosByUser("alice")
+	now = now.Add(59 * time.Second)
+	cached.TodosByUser("alice")
+	now = now.Add(time.Second)
+	cached.TodosByUser("alice")
+	if next.reads != 2 {
+		t.Errorf("wrapped repository read %d times, want 2", next.reads)
+	}
+}
+
+func TestRepositoryEvictsLeastRecentlyUsed(t *testing.T) {
+	next := newMemoryRepository()
+	cached := New(next, 2, time.Minute)
+
+	cached.TodosByUser("alice")
+	cached.TodosByUser("bob")
+	cached.TodosByUser("alice")
+	cached.TodosByUser("carol") // evicts bob
+	reads := next.reads
+	cached.TodosByUser("alice")
+	cached.TodosByUser("bob")
+	if got := next.reads - reads; got != 1 {
+		t.Errorf("wrapped repository read %d times after eviction, want 1 for bob", got)
+	}
+}
+
+func TestRepositoryWritesInvalidate(t *testing.T) {
+	next := newMemoryRepository(groceries)
+	cached := New(next, 10, time.Minute)
+
+	cached.TodoByID(groceries.ID)
+	cached.TodosByUser("alice")
+	cached.ShareTodo(groceries.ID, "bob", repository.RoleViewer)
+	if todos, _ := cached.TodosByUser("bob"); len(todos) != 1 {
+		t.Fatalf("Repository.TodosByUser() after sharing = %+v, want the shared todo", todos)
+	}
+
+	done := groceries
+	done.Done = true
+	// writes through a derived repository invalidate the same cache
+	if _, err := cached.WithActor("alice").UpdateTodo(done); err != nil {
+		t.Fatalf("Repository.UpdateTodo() error = %v", err)
+	}
+	if todo, _ := cached.TodoByID(groceries.ID); !todo.Done {
+		t.Errorf("Repository.TodoByID() after update = %+v, want it done", todo)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if todos, _ := cached.TodosByUser(user); len(todos) != 1 || !todos[0].Done {
+			t.Errorf("Repository.TodosByUser(%q) after update = %+v, want the todo done", user, todos)
+		}
+	}
+}
+
+func TestRepositoryCachesTodosWithoutTags(t *testing.T) {
+	next := newMemoryRepository(groceries)
+	cached := New(next, 10, time.Minute)
+
+	cached.TodosByUserAndTags("alice", nil)
+	cached.TodosByUser("alice")
+	cached.TodosByUserAndTags("alice", []string{})
+	if next.reads != 1 {
+		t.Errorf("wrapped repository read %d times, want 1", next.reads)
+	}
+	cached.TodosByUserAndTags("alice", []string{"errands"})
+	cached.TodosByUserAndTags("alice", []string{"errands"})
+	if next.reads != 3 {
+		t.Errorf("wrapped repository read %d times with tags, want 3", next.reads)
+	}
+
+	done := groceries
+	done.Done = true
+	cached.UpdateTodo(done)
+	if todos, _ := cached.TodosByUserAndTags("alice", nil); len(todos) != 1 || !todos[0].Done {
+		t.Errorf("Repository.TodosByUserAndTags() after update = %+v, want the todo done", todos)
+	}
+}
+
+func TestRepositoryPrimaryReadsPastCache(t *testing.T) {
+	next := newMemoryRepository(groceries)
+	cached := New(next, 10, time.Minute)
+
+	cached.TodoByID(groceries.ID)
+	// a write the cache does not see, as if replicated from elsewhere
+	renamed := groceries
+	renamed.Text = "Buy more groceries"
+	next.todos[groceries.ID] = renamed
+	if todo, _ := cached.Primary().TodoByID(groceries.ID); todo.Text != renamed.Text {
+		t.Errorf("Repository.Primary().TodoByID() = %+v, want %+v", todo, renamed)
+	}
+}
+
+func TestRepositoryReturnsCopies(t *testing.T) {
+	cached := New(newMemoryRepository(groceries), 10, time.Minute)
+
+	todos, _ := cached.TodosByUser("alice")
+	todos[0].Text = "changed by the caller"
+	if todos, _ := cached.TodosByUser("alice"); todos[0].Text != groceries.Text {
+		t.Errorf("Repository.TodosByUser() = %+v, want the cached todos unchanged", todos)
+	}
+}
+
+func TestLRUSkipsStaleValues(t *testing.T) {
+	entries := newLRU(10, time.Minute, time.Now)
+
+	generation := entries.current()
+	// a write drops the entry while the old value is being read
+	entries.delete("todo:1")
+	entries.put("todo:1", "stale", generation)
+	if _, ok := entries.get("todo:1"); ok {
+		t.Error("lru.put() stored a value read before a deletion")
+	}
+}
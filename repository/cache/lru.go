@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a map of at most size entries, each kept for ttl, that evicts the
+// least recently used entry when it is full.
+//
+// Deleting entries starts a new generation. Values read before a deletion
+// may be stale, so put only stores values read in the current generation.
+type lru struct {
+	mu         sync.Mutex
+	generation uint64
+	size       int
+	ttl        time.Duration
+	now        func() time.Time
+	order      *list.List // of *entry, most recently used first
+	entries    map[string]*list.Element
+}
+
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newLRU(size int, ttl time.Duration, now func() time.Time) *lru {
+	return &lru{size: size, ttl: ttl, now: now, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *lru) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// current returns the generation to pass to put for a value about to be
+// read.
+func (c *lru) current() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put stores value, read in generation, unless entries were deleted since.
+func (c *lru) put(key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value = &entry{key: key, value: value, expiresAt: expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// deleteIf deletes the entries for which drop returns true.
+func (c *lru) deleteIf(drop func(key string, value interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if e := element.Value.(*entry); drop(e.key, e.value) {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+func (c *lru) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
+
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
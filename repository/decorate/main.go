@@ -0,0 +1,231 @@
+// Command decorate writes the pass-through methods of a decorator of
+// repository.Repository, one per operation of the interface, from a template
+// per decorator. It is run by go generate in the package of the decorator:
+//
+//	//go:generate go run github.com/chloexu/hackernews/repository/decorate -template metrics -interface ../repository/repository.go
+//
+// The methods that derive repositories, and Close, are left out, to be
+// written by hand.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// writeVerbs start the names of the operations that change the repository.
+// The retry decorator retries the others as reads.
+var writeVerbs = []string{
+	"Add", "Apply", "Complete", "Delete", "Move", "Redo", "Release", "Remove",
+	"Rename", "Reserve", "Save", "Set", "Share", "Sync", "Undo", "Unshare",
+	"Update",
+}
+
+// templates are the decorators, each a comment describing its methods and
+// the body of a method.
+var templates = map[string]struct {
+	comment string
+	body    string
+	time    bool
+}{
+	"metrics": {
+		comment: "The operations of the wrapped repository, each timed.",
+		body: `defer r.observe("{{.Name}}", time.Now(), &err)
+	return r.next.{{.Name}}({{.Args}})`,
+		time: true,
+	},
+	"tracing": {
+		comment: "The operations of the wrapped repository, each in a span.",
+		body: `next, span := r.start("{{.Name}}")
+	defer end(span, &err)
+	return next.{{.Name}}({{.Args}})`,
+	},
+	"retry": {
+		comment: "The operations of the wrapped repository, each retried as a read or a\n// write.",
+		body: `err = r.{{if .Write}}write{{else}}read{{end}}("{{.Name}}", func() (err error) {
+		{{.Values}} = r.next.{{.Name}}({{.Args}})
+		return err
+	})
+	return {{.Values}}`,
+	},
+}
+
+// method is an operation of the interface, as the templates use it.
+type method struct {
+	Name    string
+	Params  string
+	Args    string
+	Results string
+	Values  string
+	Write   bool
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("decorate: ")
+	name := flag.String("template", "", "the decorator to write: metrics, tracing or retry")
+	src := flag.String("interface", "", "the file declaring repository.Repository")
+	out := flag.String("o", "methods.go", "the file to write")
+	flag.Parse()
+
+	tmpl, ok := templates[*name]
+	if !ok {
+		log.Fatalf("unknown template %q", *name)
+	}
+	body, err := template.New(*name).Parse("func (r *Repository) {{.Name}}({{.Params}}) ({{.Results}}) {\n\t" + tmpl.body + "\n}\n\n")
+	if err != nil {
+		log.Fatal(err)
+	}
+	methods, usesTime, err := parse(*src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by decorate -template %s. DO NOT EDIT.\n\npackage %s\n\nimport (\n", *name, os.Getenv("GOPACKAGE"))
+	if tmpl.time || usesTime {
+		buf.WriteString("\t\"time\"\n\n")
+	}
+	fmt.Fprintf(&buf, "\t\"github.com/chloexu/hackernews/repository\"\n)\n\n// %s\n\nvar _ repository.Repository = (*Repository)(nil)\n\n", tmpl.comment)
+	for _, m := range methods {
+		if err := body.Execute(&buf, m); err != nil {
+			log.Fatal(err)
+		}
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("format %s: %v", *out, err)
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parse reads the operations of the Repository interface declared in src,
+// and whether their signatures use the time package.
+func parse(src string) ([]method, bool, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), src, nil, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	obj := file.Scope.Lookup("Repository")
+	if obj == nil {
+		return nil, false, fmt.Errorf("no Repository in %s", src)
+	}
+	spec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, false, fmt.Errorf("Repository in %s is not a type", src)
+	}
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, false, fmt.Errorf("Repository in %s is not an interface", src)
+	}
+
+	var methods []method
+	usesTime := false
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return nil, false, fmt.Errorf("Repository embeds %s", typeString(field.Type))
+		}
+		if derives(fn) {
+			continue
+		}
+		m := method{Name: field.Names[0].Name, Write: isWrite(field.Names[0].Name)}
+
+		var params, args []string
+		for _, p := range fn.Params.List {
+			t := typeString(p.Type)
+			for _, n := range p.Names {
+				params = append(params, n.Name+" "+t)
+				args = append(args, n.Name)
+			}
+		}
+		m.Params, m.Args = strings.Join(params, ", "), strings.Join(args, ", ")
+
+		results, values, err := resultsOf(m.Name, fn.Results)
+		if err != nil {
+			return nil, false, err
+		}
+		m.Results, m.Values = strings.Join(results, ", "), strings.Join(values, ", ")
+		usesTime = usesTime || strings.Contains(m.Params+m.Results, "time.")
+		methods = append(methods, m)
+	}
+	return methods, usesTime, nil
+}
+
+// derives reports whether an operation is one left out: it returns another
+// repository, or nothing at all.
+func derives(fn *ast.FuncType) bool {
+	if fn.Results == nil || len(fn.Results.List) == 0 {
+		return true
+	}
+	id, ok := fn.Results.List[0].Type.(*ast.Ident)
+	return ok && id.Name == "Repository"
+}
+
+func isWrite(name string) bool {
+	for _, verb := range writeVerbs {
+		if strings.HasPrefix(name, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// resultsOf names the results of an operation, which end with an error: by
+// the names the interface gives them, or as value and err.
+func resultsOf(name string, list *ast.FieldList) (results []string, values []string, err error) {
+	for _, r := range list.List {
+		t := typeString(r.Type)
+		if len(r.Names) == 0 {
+			n := "value"
+			if t == "error" {
+				n = "err"
+			} else if len(values) > 0 {
+				return nil, nil, fmt.Errorf("%s has more than one unnamed result", name)
+			}
+			results, values = append(results, n+" "+t), append(values, n)
+			continue
+		}
+		for _, n := range r.Names {
+			results, values = append(results, n.Name+" "+t), append(values, n.Name)
+		}
+	}
+	if len(values) == 0 || values[len(values)-1] != "err" {
+		return nil, nil, fmt.Errorf("%s does not return an error last", name)
+	}
+	return results, values, nil
+}
+
+// typeString writes a type of the interface as the decorators refer to it,
+// with the types of the repository package qualified.
+func typeString(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			return "repository." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	}
+	log.Fatalf("unsupported type %T", e)
+	return ""
+}
@@ -0,0 +1,39 @@
+package mysql
+
+import (
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// AttachmentsByTodo returns the attachments of a todo, oldest first.
+func (r *mysqlRepository) AttachmentsByTodo(todoId string) ([]repo.AttachmentRow, error) {
+	var attachments []repo.AttachmentRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT id, todo_id, filename, content_type, size, blob_key, created_at FROM attachments "+
+		"WHERE todo_id = ? ORDER BY created_at, id", todoId)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentsByTodo query %q: %v", todoId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a repo.AttachmentRow
+		if err := rows.Scan(&a.ID, &a.TodoID, &a.Filename, &a.ContentType, &a.Size, &a.BlobKey, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("AttachmentsByTodo scan row %q: %v", todoId, err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("AttachmentsByTodo rows err %q: %v", todoId, err)
+	}
+
+	return attachments, nil
+}
+
+func (r *mysqlRepository) AddAttachment(row repo.AttachmentRow) (bool, error) {
+	return r.auditedExec("AddAttachment", entityTodo, repo.ActionAttach, row.TodoID,
+		"INSERT INTO attachments(id, todo_id, filename, content_type, size, blob_key, created_at) VALUES (?, ?, ?, ?, ?, ?, now())",
+		row.ID, row.TodoID, row.Filename, row.ContentType, row.Size, row.BlobKey)
+}
@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+var attachment = &repo.AttachmentRow{
+	ID:          "caajol287d5nseratt1",
+	TodoID:      "caajol287d5nser73bs0",
+	Filename:    "receipt.pdf",
+	ContentType: "application/pdf",
+	Size:        48213,
+	BlobKey:     "caajol287d5nser73bs0/caajol287d5nseratt1",
+	CreatedAt:   time.Date(2022, 5, 20, 11, 0, 0, 0, time.UTC),
+}
+
+func TestAttachmentsByTodo(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	query := "SELECT id, todo_id, filename, content_type, size, blob_key, created_at FROM attachments " +
+		"WHERE todo_id = ? ORDER BY created_at, id"
+	mock.ExpectQuery(query).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "filename", "content_type", "size", "blob_key", "created_at"}).
+			AddRow(attachment.ID, attachment.TodoID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.BlobKey, attachment.CreatedAt))
+
+	got, err := mysqlRepo.AttachmentsByTodo(todo.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.AttachmentsByTodo() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []repo.AttachmentRow{*attachment}) {
+		t.Errorf("mysqlRepository.AttachmentsByTodo() = %v, want %v", got, []repo.AttachmentRow{*attachment})
+	}
+}
+
+func TestAddAttachment(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "INSERT INTO attachments(id, todo_id, filename, content_type, size, blob_key, created_at) VALUES (?, ?, ?, ?, ?, ?, now())"
+	expectAudited(mock, entityTodo, attachment.TodoID, repo.ActionAttach, func() {
+		mock.ExpectExec(statement).
+			WithArgs(attachment.ID, attachment.TodoID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.BlobKey).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	got, err := mysqlRepo.AddAttachment(*attachment)
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.AddAttachment() = %v, %v, want true, nil", got, err)
+	}
+}
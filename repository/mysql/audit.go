@@ -0,0 +1,457 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// Every write records an entry in the append-only history table, in the same
+// transaction as the write itself, so that no change goes unrecorded. An
+// entry holds the fields of the todo, list or tag that the write changed,
+// taken from snapshots before and after it.
+
+const (
+	entityTodo = "todo"
+	entityList = "list"
+	entityTag  = "tag"
+)
+
+const historyColumns = "id, entity_type, entity_id, owner_id, actor_id, operation_id, action, changes, created_at"
+
+// snapshot is the audited state of an entity: its owner and its fields as
+// text, leaving out empty ones.
+type snapshot struct {
+	owner  string
+	fields map[string]string
+	// deleted is set for todos that are deleted but kept for undo.
+	deleted bool
+}
+
+type fieldChange struct {
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+// todoSnapshotSelect selects a todo together with what hangs off it, so that
+// tagging, sharing and attaching show up in its history too.
+const todoSnapshotSelect = "SELECT " + todoColumns + ", " +
+	"(SELECT GROUP_CONCAT(g.name ORDER BY g.name SEPARATOR ', ') FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.todo_id = todos.id), " +
+	"(SELECT GROUP_CONCAT(CONCAT(c.user_id, ':', c.role) ORDER BY c.user_id SEPARATOR ', ') FROM todo_collaborators c WHERE c.todo_id = todos.id), " +
+	"(SELECT GROUP_CONCAT(a.filename ORDER BY a.created_at, a.id SEPARATOR ', ') FROM attachments a WHERE a.todo_id = todos.id), " +
+	"deleted_at FROM todos "
+
+const todoSnapshotQuery = todoSnapshotSelect + "WHERE id = ? FOR UPDATE"
+
+const listSnapshotQuery = "SELECT user_id, name, archived, " +
+	"(SELECT GROUP_CONCAT(CONCAT(c.user_id, ':', c.role) ORDER BY c.user_id SEPARATOR ', ') FROM list_collaborators c WHERE c.list_id = todo_lists.id) " +
+	"FROM todo_lists WHERE id = ? FOR UPDATE"
+
+const tagSnapshotQuery = "SELECT user_id, name FROM tags WHERE id = ? FOR UPDATE"
+
+// WithActor returns a repository writing on behalf of actorId, who is named
+// as the actor of the history entries it records.
+func (r *mysqlRepository) WithActor(actorId string) repo.Repository {
+	derived := *r
+	derived.actor = actorId
+	return &derived
+}
+
+// WithOperation returns a repository whose history entries belong to the
+// operation operationId.
+func (r *mysqlRepository) WithOperation(operationId string) repo.Repository {
+	derived := *r
+	derived.operation = operationId
+	return &derived
+}
+
+// WithContext returns a repository making its queries with ctx, so that they
+// are traced and cancelled with the request.
+func (r *mysqlRepository) WithContext(ctx context.Context) repo.Repository {
+	derived := *r
+	derived.ctx = ctx
+	return &derived
+}
+
+// audited runs write on one entity in a transaction and records the change
+// it made. When write affects no row nothing is recorded and false is
+// returned. Errors of write are returned as they are.
+func (r *mysqlRepository) audited(op string, entity string, action string, id string, write func(tx *sql.Tx) (sql.Result, error)) (bool, error) {
+	tx, err := r.db.BeginTx(r.context(), nil)
+	if err != nil {
+		return false, fmt.Errorf("%s begin : %v", op, err)
+	}
+	defer tx.Rollback()
+
+	var before *snapshot
+	if action != repo.ActionCreate {
+		if before, err = takeSnapshot(tx, entity, id); err != nil {
+			return false, fmt.Errorf("%s snapshot before : %v", op, err)
+		}
+	}
+
+	result, err := write(tx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s fetch row after write : %v", op, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	var after *snapshot
+	if action != repo.ActionDelete {
+		if after, err = takeSnapshot(tx, entity, id); err != nil {
+			return false, fmt.Errorf("%s snapshot after : %v", op, err)
+		}
+	}
+	if err := r.record(tx, entity, id, action, before, after); err != nil {
+		return false, fmt.Errorf("%s record history : %v", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s commit : %v", op, err)
+	}
+	return true, nil
+}
+
+// auditedTodos is audited for a write on many todos. The todos are the ids
+// selected by with+pick, which write, sharing the same WITH clause and args,
+// then changes. It returns the number of todos write affected.
+func (r *mysqlRepository) auditedTodos(op string, action string, with string, pick string, write string, args ...interface{}) (int64, error) {
+	tx, err := r.db.BeginTx(r.context(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s begin : %v", op, err)
+	}
+	defer tx.Rollback()
+
+	updated, err := r.writeTodos(tx, op, action, with, pick, write, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s commit : %v", op, err)
+	}
+	return updated, nil
+}
+
+// writeTodos is auditedTodos within the transaction tx.
+func (r *mysqlRepository) writeTodos(tx *sql.Tx, op string, action string, with string, pick string, write string, args ...interface{}) (int64, error) {
+	rows, err := tx.Query(with+pick, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s query : %v", op, err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s scan row : %v", op, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s rows err : %v", op, err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	before := make([]*snapshot, len(ids))
+	for i, id := range ids {
+		if before[i], err = takeSnapshot(tx, entityTodo, id); err != nil {
+			return 0, fmt.Errorf("%s snapshot before : %v", op, err)
+		}
+	}
+
+	result, err := tx.Exec(with+write, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s exec : %v", op, err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s fetch row after write : %v", op, err)
+	}
+
+	for i, id := range ids {
+		var after *snapshot
+		if action != repo.ActionDelete {
+			if after, err = takeSnapshot(tx, entityTodo, id); err != nil {
+				return 0, fmt.Errorf("%s snapshot after : %v", op, err)
+			}
+		}
+		if err := r.record(tx, entityTodo, id, action, before[i], after); err != nil {
+			return 0, fmt.Errorf("%s record history : %v", op, err)
+		}
+	}
+	return updated, nil
+}
+
+// entityChange is a change of one entity from before to after. A nil
+// snapshot stands for an entity that does not exist (yet or any more).
+type entityChange struct {
+	id     string
+	before *snapshot
+	after  *snapshot
+}
+
+// fields returns the fields that differ between before and after.
+func (c entityChange) fields() map[string]fieldChange {
+	changes := map[string]fieldChange{}
+	if c.before != nil {
+		for field, value := range c.before.fields {
+			value := value
+			changes[field] = fieldChange{Before: &value}
+		}
+	}
+	if c.after != nil {
+		for field, value := range c.after.fields {
+			value := value
+			change := changes[field]
+			if change.Before != nil && *change.Before == value {
+				delete(changes, field)
+				continue
+			}
+			change.After = &value
+			changes[field] = change
+		}
+	}
+	return changes
+}
+
+// record appends a history entry for the change from before to after.
+func (r *mysqlRepository) record(tx *sql.Tx, entity string, id string, action string, before *snapshot, after *snapshot) error {
+	return r.recordAll(tx, entity, action, []entityChange{{id: id, before: before, after: after}})
+}
+
+// recordAll appends a history entry for each change in a single statement,
+// and an outbox event in another.
+func (r *mysqlRepository) recordAll(tx *sql.Tx, entity string, action string, changes []entityChange) error {
+	values := make([]string, 0, len(changes))
+	events := make([]string, 0, len(changes))
+	var args, eventArgs []interface{}
+	for _, c := range changes {
+		fields := c.fields()
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		var owner string
+		if c.before != nil {
+			owner = c.before.owner
+		}
+		if c.after != nil {
+			owner = c.after.owner
+		}
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, now(6))")
+		args = append(args, entity, c.id, owner, nullString(r.actor), nullString(r.operation), action, string(encoded))
+
+		payload, err := json.Marshal(event{EntityType: entity, EntityID: c.id, OwnerID: owner, ActorID: r.actor,
+			OperationID: r.operation, Action: action, Changes: fields})
+		if err != nil {
+			return err
+		}
+		events = append(events, "(?, ?, ?, ?, ?, now(6))")
+		eventArgs = append(eventArgs, entity+"."+action, entity, c.id, owner, string(payload))
+	}
+	if _, err := tx.Exec("INSERT INTO history(entity_type, entity_id, owner_id, actor_id, operation_id, action, changes, created_at) VALUES "+
+		strings.Join(values, ", "), args...); err != nil {
+		return err
+	}
+	// the outbox gets the same changes, for other services
+	_, err := tx.Exec("INSERT INTO outbox(event_type, entity_type, entity_id, owner_id, payload, created_at) VALUES "+
+		strings.Join(events, ", "), eventArgs...)
+	return err
+}
+
+// takeSnapshot reads the audited state of an entity, or nil when there is
+// no such entity or it is deleted.
+func takeSnapshot(tx *sql.Tx, entity string, id string) (*snapshot, error) {
+	var s *snapshot
+	var err error
+	switch entity {
+	case entityTodo:
+		s, err = todoSnapshot(tx, id)
+	case entityList:
+		s, err = listSnapshot(tx, id)
+	case entityTag:
+		s, err = tagSnapshot(tx, id)
+	default:
+		return nil, fmt.Errorf("unknown entity %q", entity)
+	}
+	if err == sql.ErrNoRows || (s != nil && s.deleted) {
+		return nil, nil
+	}
+	return s, err
+}
+
+func todoSnapshot(tx *sql.Tx, id string) (*snapshot, error) {
+	_, s, err := scanTodoSnapshot(tx.QueryRow(todoSnapshotQuery, id))
+	return s, err
+}
+
+// todoSnapshots reads the audited state of many todos, keyed by id, in one
+// query. Todos that do not exist are left out.
+func todoSnapshots(tx *sql.Tx, ids []string) (map[string]*snapshot, error) {
+	rows, err := tx.Query(todoSnapshotSelect+"WHERE id IN ("+placeholders(len(ids))+") FOR UPDATE", stringArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make(map[string]*snapshot, len(ids))
+	for rows.Next() {
+		id, s, err := scanTodoSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[id] = s
+	}
+	return snapshots, rows.Err()
+}
+
+func scanTodoSnapshot(s scanner) (string, *snapshot, error) {
+	var todo repo.TodoRow
+	var tags, collaborators, attachments sql.NullString
+	var deletedAt sql.NullTime
+	if err := scanTodo(s, &todo, &tags, &collaborators, &attachments, &deletedAt); err != nil {
+		return "", nil, err
+	}
+	fields := map[string]string{
+		"text":     todo.Text,
+		"done":     strconv.FormatBool(todo.Done),
+		"priority": strconv.Itoa(int(todo.Priority)),
+	}
+	setField(fields, "listId", todo.ListID)
+	setField(fields, "parentId", todo.ParentID)
+	if !todo.DueAt.IsZero() {
+		fields["dueAt"] = todo.DueAt.Format("2006-01-02 15:04:05")
+	}
+	setField(fields, "recurrence", todo.Recurrence)
+	setField(fields, "position", todo.Position)
+	setField(fields, "tags", tags.String)
+	setField(fields, "collaborators", collaborators.String)
+	setField(fields, "attachments", attachments.String)
+	return todo.ID, &snapshot{owner: todo.UserID, fields: fields, deleted: deletedAt.Valid}, nil
+}
+
+func listSnapshot(tx *sql.Tx, id string) (*snapshot, error) {
+	var owner, name string
+	var archived bool
+	var collaborators sql.NullString
+	if err := tx.QueryRow(listSnapshotQuery, id).Scan(&owner, &name, &archived, &collaborators); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{
+		"name":     name,
+		"archived": strconv.FormatBool(archived),
+	}
+	setField(fields, "collaborators", collaborators.String)
+	return &snapshot{owner: owner, fields: fields}, nil
+}
+
+func tagSnapshot(tx *sql.Tx, id string) (*snapshot, error) {
+	var owner, name string
+	if err := tx.QueryRow(tagSnapshotQuery, id).Scan(&owner, &name); err != nil {
+		return nil, err
+	}
+	return &snapshot{owner: owner, fields: map[string]string{"name": name}}, nil
+}
+
+func setField(fields map[string]string, field string, value string) {
+	if value != "" {
+		fields[field] = value
+	}
+}
+
+// TodoHistory returns a page of the history of a todo, newest first.
+func (r *mysqlRepository) TodoHistory(todoId string, limit int, offset int) ([]repo.HistoryRow, error) {
+	return r.history(r.db, "TodoHistory",
+		"SELECT "+historyColumns+" FROM history WHERE entity_type = ? AND entity_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		todoId, entityTodo, todoId, limit, offset)
+}
+
+// ActivityByUser returns a page of the changes made by a user or to what the
+// user owns, newest first.
+func (r *mysqlRepository) ActivityByUser(userId string, limit int, offset int) ([]repo.HistoryRow, error) {
+	return r.history(r.db, "ActivityByUser",
+		"SELECT "+historyColumns+" FROM history WHERE owner_id = ? OR actor_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userId, userId, userId, limit, offset)
+}
+
+// querier runs queries on the database or in a transaction.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (r *mysqlRepository) history(q querier, op string, query string, key string, args ...interface{}) ([]repo.HistoryRow, error) {
+	var entries []repo.HistoryRow
+
+	rows, err := q.QueryContext(r.context(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s query %q: %v", op, key, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry repo.HistoryRow
+		var actor, operation sql.NullString
+		var changes []byte
+		if err := rows.Scan(&entry.ID, &entry.EntityType, &entry.EntityID, &entry.OwnerID, &actor, &operation, &entry.Action, &changes, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s scan row %q: %v", op, key, err)
+		}
+		entry.ActorID = actor.String
+		entry.OperationID = operation.String
+		if entry.Changes, err = decodeChanges(changes); err != nil {
+			return nil, fmt.Errorf("%s decode changes of entry %d: %v", op, entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err %q: %v", op, key, err)
+	}
+
+	return entries, nil
+}
+
+// decodeChanges reads the changes column, ordering the changes by field.
+func decodeChanges(encoded []byte) ([]repo.FieldChange, error) {
+	var changes map[string]fieldChange
+	if err := json.Unmarshal(encoded, &changes); err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	decoded := make([]repo.FieldChange, 0, len(fields))
+	for _, field := range fields {
+		decoded = append(decoded, repo.FieldChange{Field: field, Before: changes[field].Before, After: changes[field].After})
+	}
+	return decoded, nil
+}
+
+// auditedExec is audited for writes made of a single statement.
+func (r *mysqlRepository) auditedExec(op string, entity string, action string, id string, query string, args ...interface{}) (bool, error) {
+	return r.audited(op, entity, action, id, func(tx *sql.Tx) (sql.Result, error) {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s exec : %v", op, err)
+		}
+		return result, nil
+	})
+}
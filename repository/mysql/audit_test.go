@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const todoSnapshotSQL = "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position, " +
+	"(SELECT GROUP_CONCAT(g.name ORDER BY g.name SEPARATOR ', ') FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.todo_id = todos.id), " +
+	"(SELECT GROUP_CONCAT(CONCAT(c.user_id, ':', c.role) ORDER BY c.user_id SEPARATOR ', ') FROM todo_collaborators c WHERE c.todo_id = todos.id), " +
+	"(SELECT GROUP_CONCAT(a.filename ORDER BY a.created_at, a.id SEPARATOR ', ') FROM attachments a WHERE a.todo_id = todos.id), " +
+	"deleted_at FROM todos WHERE id = ? FOR UPDATE"
+
+const listSnapshotSQL = "SELECT user_id, name, archived, " +
+	"(SELECT GROUP_CONCAT(CONCAT(c.user_id, ':', c.role) ORDER BY c.user_id SEPARATOR ', ') FROM list_collaborators c WHERE c.list_id = todo_lists.id) " +
+	"FROM todo_lists WHERE id = ? FOR UPDATE"
+
+const tagSnapshotSQL = "SELECT user_id, name FROM tags WHERE id = ? FOR UPDATE"
+
+const historyInsert = "INSERT INTO history(entity_type, entity_id, owner_id, actor_id, operation_id, action, changes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, now(6))"
+
+const historySelect = "SELECT id, entity_type, entity_id, owner_id, actor_id, operation_id, action, changes, created_at FROM history "
+
+const outboxInsert = "INSERT INTO outbox(event_type, entity_type, entity_id, owner_id, payload, created_at) VALUES (?, ?, ?, ?, ?, now(6))"
+
+var historyColumnNames = []string{"id", "entity_type", "entity_id", "owner_id", "actor_id", "operation_id", "action", "changes", "created_at"}
+
+var todoSnapshotColumns = []string{"id", "text", "done", "user_id", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position", "tags", "collaborators", "attachments", "deleted_at"}
+
+// expectAudited expects the transaction of an audited write: a snapshot of
+// the entity before it (except on create), the write itself, a snapshot after
+// it (except on delete) and the history entry.
+func expectAudited(mock sqlmock.Sqlmock, entity string, id string, action string, expectWrite func()) {
+	mock.ExpectBegin()
+	if action != repo.ActionCreate {
+		expectSnapshot(mock, entity, id)
+	}
+	expectWrite()
+	if action != repo.ActionDelete {
+		expectSnapshot(mock, entity, id)
+	}
+	mock.ExpectExec(historyInsert).WithArgs(entity, id, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, entity, id, action)
+	mock.ExpectCommit()
+}
+
+// expectUnchanged expects the transaction of an audited write that affects no
+// row, which records nothing.
+func expectUnchanged(mock sqlmock.Sqlmock, entity string, id string, action string, expectWrite func()) {
+	mock.ExpectBegin()
+	if action != repo.ActionCreate {
+		expectSnapshot(mock, entity, id)
+	}
+	expectWrite()
+	mock.ExpectRollback()
+}
+
+// expectEvent expects the outbox event written along with a history entry.
+func expectEvent(mock sqlmock.Sqlmock, entity string, id string, action string) {
+	mock.ExpectExec(outboxInsert).WithArgs(entity+"."+action, entity, id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func expectSnapshot(mock sqlmock.Sqlmock, entity string, id string) {
+	switch entity {
+	case entityTodo:
+		mock.ExpectQuery(todoSnapshotSQL).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+				AddRow(id, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil, nil, nil, nil, nil))
+	case entityList:
+		mock.ExpectQuery(listSnapshotSQL).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "archived", "collaborators"}).
+				AddRow(listGarden.UserID, listGarden.Name, false, nil))
+	case entityTag:
+		mock.ExpectQuery(tagSnapshotSQL).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "name"}).AddRow(todo.UserID, "garden"))
+	}
+}
+
+func TestAuditedRecordsChanges(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := (&mysqlRepository{db: db}).WithActor(collaboratorID)
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", "garden", nil, nil, nil))
+	mock.ExpectExec("UPDATE todos SET text = ?, done = ?, completed_at = curdate() where id = ?").
+		WithArgs("Water the roses", true, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, "Water the roses", true, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", "garden", nil, nil, nil))
+	mock.ExpectExec(historyInsert).
+		WithArgs(entityTodo, todo.ID, todo.UserID, collaboratorID, nil, repo.ActionUpdate,
+			`{"done":{"before":"false","after":"true"},"text":{"before":"Water roses and lilies","after":"Water the roses"}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(outboxInsert).
+		WithArgs("todo.update", entityTodo, todo.ID, todo.UserID,
+			`{"entityType":"todo","entityId":"`+todo.ID+`","ownerId":"`+todo.UserID+`","actorId":"`+collaboratorID+`","action":"update",`+
+				`"changes":{"done":{"before":"false","after":"true"},"text":{"before":"Water roses and lilies","after":"Water the roses"}}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.UpdateTodo(repo.TodoRow{ID: todo.ID, Text: "Water the roses", Done: true})
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.UpdateTodo() = %v, %v, want true, nil", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTodoHistory(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	changedAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(historySelect+
+		"WHERE entity_type = ? AND entity_id = ? ORDER BY id DESC LIMIT ? OFFSET ?").
+		WithArgs(entityTodo, todo.ID, 2, 0).
+		WillReturnRows(sqlmock.NewRows(historyColumnNames).
+			AddRow(2, entityTodo, todo.ID, todo.UserID, collaboratorID, nil, repo.ActionUpdate,
+				[]byte(`{"text":{"before":"Water roses and lilies","after":"Water the roses"},"done":{"before":"false","after":"true"}}`), changedAt).
+			AddRow(1, entityTodo, todo.ID, todo.UserID, nil, nil, repo.ActionCreate, []byte(`{"text":{"after":"Water roses and lilies"}}`), changedAt))
+
+	got, err := mysqlRepo.TodoHistory(todo.ID, 2, 0)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodoHistory() error = %v", err)
+	}
+	str := func(s string) *string { return &s }
+	want := []repo.HistoryRow{
+		{ID: 2, EntityType: entityTodo, EntityID: todo.ID, OwnerID: todo.UserID, ActorID: collaboratorID, Action: repo.ActionUpdate,
+			Changes: []repo.FieldChange{
+				{Field: "done", Before: str("false"), After: str("true")},
+				{Field: "text", Before: str("Water roses and lilies"), After: str("Water the roses")},
+			}, CreatedAt: changedAt},
+		{ID: 1, EntityType: entityTodo, EntityID: todo.ID, OwnerID: todo.UserID, Action: repo.ActionCreate,
+			Changes: []repo.FieldChange{{Field: "text", After: str("Water roses and lilies")}}, CreatedAt: changedAt},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.TodoHistory() = %+v, want %+v", got, want)
+	}
+}
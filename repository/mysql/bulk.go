@@ -0,0 +1,259 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	repo "github.com/chloexu/hackernews/repository"
+	"github.com/rs/xid"
+)
+
+// editableTodos is a condition, selected as a column, telling whether a user
+// owns a todo or may edit it as an editor of the todo or of its list. Its
+// placeholders are filled by visibleTodosArgs.
+const editableTodos = "(user_id = ? OR id IN (SELECT todo_id FROM todo_collaborators WHERE user_id = ? AND role = 'editor') " +
+	"OR list_id IN (SELECT list_id FROM list_collaborators WHERE user_id = ? AND role = 'editor'))"
+
+func (r *mysqlRepository) TodosByIDs(ids []string) ([]repo.TodoRow, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var todos []repo.TodoRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT "+todoColumns+" FROM todos WHERE id IN ("+placeholders(len(ids))+") AND deleted_at IS NULL ORDER BY position, id",
+		stringArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("TodosByIDs query %q: %v", ids, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo repo.TodoRow
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("TodosByIDs scan row %q: %v", ids, err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodosByIDs rows err %q: %v", ids, err)
+	}
+
+	return todos, nil
+}
+
+func (r *mysqlRepository) UpdateTodos(userId string, ids []string, patch repo.TodoPatch) ([]repo.BulkResult, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return r.bulkUpdate("UpdateTodos", repo.ActionUpdate, userId, ids,
+		"id IN ("+placeholders(len(ids))+") AND deleted_at IS NULL", stringArgs(ids), patch)
+}
+
+func (r *mysqlRepository) CompleteAll(userId string, filter repo.TodoFilter) ([]repo.BulkResult, error) {
+	where, args := visibleTodos+" AND done = FALSE", visibleTodosArgs(userId)
+	if filter.ListID != "" {
+		where += " AND list_id = ?"
+		args = append(args, filter.ListID)
+	}
+	if len(filter.Tags) > 0 {
+		tagged, tagArgs := taggedWithAll(filter.Tags)
+		where += " AND " + tagged
+		args = append(args, tagArgs...)
+	}
+	if !filter.DueBefore.IsZero() {
+		where += " AND due_at < ?"
+		args = append(args, filter.DueBefore)
+	}
+	done := true
+	return r.bulkUpdate("CompleteAll", repo.ActionComplete, userId, nil, where, args, repo.TodoPatch{Done: &done})
+}
+
+// bulkUpdate applies a patch to the todos matching where in a single
+// transaction and records a history entry with the given action for each
+// todo it changes. The todos userId may not edit are left out with an error,
+// as are the requested ids that match no todo. Without ids, there is a result
+// per matching todo.
+func (r *mysqlRepository) bulkUpdate(op string, action string, userId string, ids []string, where string, args []interface{}, patch repo.TodoPatch) ([]repo.BulkResult, error) {
+	tx, err := r.db.BeginTx(r.context(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s begin : %v", op, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, user_id, "+editableTodos+" FROM todos WHERE "+where+" ORDER BY position, id FOR UPDATE",
+		append(visibleTodosArgs(userId), args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s query %q: %v", op, userId, err)
+	}
+	var matched []string
+	owners := map[string]string{}
+	editable := map[string]bool{}
+	for rows.Next() {
+		var id, owner string
+		var canEdit bool
+		if err := rows.Scan(&id, &owner, &canEdit); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s scan row %q: %v", op, userId, err)
+		}
+		matched = append(matched, id)
+		owners[id] = owner
+		editable[id] = canEdit
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err %q: %v", op, userId, err)
+	}
+
+	if ids == nil {
+		ids = matched
+	}
+	results := make([]repo.BulkResult, len(ids))
+	var changing []string
+	for i, id := range ids {
+		results[i].TodoID = id
+		if _, ok := owners[id]; !ok {
+			results[i].Err = fmt.Errorf("no todo %q", id)
+		} else if !editable[id] {
+			results[i].Err = fmt.Errorf("user %q may not edit todo %q", userId, id)
+		}
+	}
+	changingOwners := map[string]string{}
+	for _, id := range matched {
+		if editable[id] {
+			changing = append(changing, id)
+			changingOwners[id] = owners[id]
+		}
+	}
+	if len(changing) == 0 {
+		return results, nil
+	}
+
+	before, err := todoSnapshots(tx, changing)
+	if err != nil {
+		return nil, fmt.Errorf("%s snapshot before : %v", op, err)
+	}
+	if err := r.applyPatch(tx, changing, changingOwners, patch); err != nil {
+		return nil, fmt.Errorf("%s exec : %v", op, err)
+	}
+	after, err := todoSnapshots(tx, changing)
+	if err != nil {
+		return nil, fmt.Errorf("%s snapshot after : %v", op, err)
+	}
+
+	var changes []entityChange
+	for _, id := range changing {
+		change := entityChange{id: id, before: before[id], after: after[id]}
+		if len(change.fields()) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) > 0 {
+		if err := r.recordAll(tx, entityTodo, action, changes); err != nil {
+			return nil, fmt.Errorf("%s record history : %v", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s commit : %v", op, err)
+	}
+	return results, nil
+}
+
+// applyPatch writes a patch to the todos with the given ids, whose owners are
+// given by id, with one statement per field.
+func (r *mysqlRepository) applyPatch(tx *sql.Tx, ids []string, owners map[string]string, patch repo.TodoPatch) error {
+	in := "(" + placeholders(len(ids)) + ")"
+	idArgs := stringArgs(ids)
+
+	if patch.Done != nil {
+		query := "UPDATE todos SET done = TRUE, completed_at = curdate() WHERE done = FALSE AND id IN " + in
+		if !*patch.Done {
+			query = "UPDATE todos SET done = FALSE, completed_at = NULL WHERE done = TRUE AND id IN " + in
+		}
+		if _, err := tx.Exec(query, idArgs...); err != nil {
+			return err
+		}
+	}
+	if patch.Priority != nil {
+		if _, err := tx.Exec("UPDATE todos SET priority = ? WHERE id IN "+in, append([]interface{}{*patch.Priority}, idArgs...)...); err != nil {
+			return err
+		}
+	}
+	if len(patch.AddTags) > 0 {
+		if err := r.createMissingTags(tx, owners, patch.AddTags); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT IGNORE INTO todo_tags(todo_id, tag_id) SELECT t.id, g.id FROM todos t JOIN tags g ON g.user_id = t.user_id "+
+			"WHERE t.id IN "+in+" AND g.name IN ("+placeholders(len(patch.AddTags))+")",
+			append(idArgs, stringArgs(patch.AddTags)...)...); err != nil {
+			return err
+		}
+	}
+	if len(patch.RemoveTags) > 0 {
+		if _, err := tx.Exec("DELETE tt FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id "+
+			"WHERE tt.todo_id IN "+in+" AND g.name IN ("+placeholders(len(patch.RemoveTags))+")",
+			append(idArgs, stringArgs(patch.RemoveTags)...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createMissingTags creates the tags with the given names that the owners of
+// the todos do not have yet, since tags belong to the owner of a todo.
+func (r *mysqlRepository) createMissingTags(tx *sql.Tx, owners map[string]string, names []string) error {
+	var users []string
+	seen := map[string]bool{}
+	for _, owner := range owners {
+		if !seen[owner] {
+			seen[owner] = true
+			users = append(users, owner)
+		}
+	}
+	sort.Strings(users)
+
+	rows, err := tx.Query("SELECT user_id, name FROM tags WHERE user_id IN ("+placeholders(len(users))+") AND name IN ("+placeholders(len(names))+") FOR UPDATE",
+		append(stringArgs(users), stringArgs(names)...)...)
+	if err != nil {
+		return err
+	}
+	existing := map[[2]string]bool{}
+	for rows.Next() {
+		var user, name string
+		if err := rows.Scan(&user, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[[2]string{user, name}] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var values []string
+	var args []interface{}
+	var created []entityChange
+	for _, user := range users {
+		for _, name := range names {
+			if existing[[2]string{user, name}] {
+				continue
+			}
+			existing[[2]string{user, name}] = true
+			id := xid.New().String()
+			values = append(values, "(?, ?, ?)")
+			args = append(args, id, user, name)
+			created = append(created, entityChange{id: id, after: &snapshot{owner: user, fields: map[string]string{"name": name}}})
+		}
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec("INSERT INTO tags(id, user_id, name) VALUES "+strings.Join(values, ", "), args...); err != nil {
+		return err
+	}
+	return r.recordAll(tx, entityTag, repo.ActionCreate, created)
+}
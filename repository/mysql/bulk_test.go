@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// editableBy is the column telling whether a user may edit a todo.
+const editableBy = "(user_id = ? OR id IN (SELECT todo_id FROM todo_collaborators WHERE user_id = ? AND role = 'editor') " +
+	"OR list_id IN (SELECT list_id FROM list_collaborators WHERE user_id = ? AND role = 'editor'))"
+
+var todoSnapshotsSQL = strings.TrimSuffix(todoSnapshotSQL, "WHERE id = ? FOR UPDATE") + "WHERE id IN (?) FOR UPDATE"
+
+func TestUpdateTodos(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := (&mysqlRepository{db: db}).WithActor(todo.UserID)
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	ids := []string{todo.ID, todoByDifferentUser.ID, "caajol287d5nsmissing"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, "+editableBy+" FROM todos WHERE id IN (?, ?, ?) AND deleted_at IS NULL ORDER BY position, id FOR UPDATE").
+		WithArgs(todo.UserID, todo.UserID, todo.UserID, todo.ID, todoByDifferentUser.ID, "caajol287d5nsmissing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "editable"}).
+			AddRow(todo.ID, todo.UserID, true).
+			AddRow(todoByDifferentUser.ID, todoByDifferentUser.UserID, false))
+	mock.ExpectQuery(todoSnapshotsSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, "V", "garden", nil, nil, nil))
+	mock.ExpectExec("UPDATE todos SET priority = ? WHERE id IN (?)").
+		WithArgs(repo.PriorityHigh, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE tt FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.todo_id IN (?) AND g.name IN (?)").
+		WithArgs(todo.ID, "garden").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(todoSnapshotsSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 2, nil, "V", nil, nil, nil, nil))
+	mock.ExpectExec(historyInsert).
+		WithArgs(entityTodo, todo.ID, todo.UserID, todo.UserID, nil, repo.ActionUpdate,
+			`{"priority":{"before":"0","after":"2"},"tags":{"before":"garden"}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, entityTodo, todo.ID, repo.ActionUpdate)
+	mock.ExpectCommit()
+
+	high := repo.PriorityHigh
+	got, err := mysqlRepo.UpdateTodos(todo.UserID, ids, repo.TodoPatch{Priority: &high, RemoveTags: []string{"garden"}})
+	if err != nil {
+		t.Fatalf("mysqlRepository.UpdateTodos() error = %v", err)
+	}
+	want := []repo.BulkResult{
+		{TodoID: todo.ID},
+		{TodoID: todoByDifferentUser.ID, Err: fmt.Errorf("user %q may not edit todo %q", todo.UserID, todoByDifferentUser.ID)},
+		{TodoID: "caajol287d5nsmissing", Err: fmt.Errorf("no todo %q", "caajol287d5nsmissing")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.UpdateTodos() = %v, want %v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompleteAll(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, "+editableBy+" FROM todos WHERE "+visibleTo+" AND done = FALSE AND list_id = ? "+
+		"ORDER BY position, id FOR UPDATE").
+		WithArgs(todo.UserID, todo.UserID, todo.UserID, todo.UserID, todo.UserID, todo.UserID, listGarden.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "editable"}).AddRow(todo.ID, todo.UserID, true))
+	mock.ExpectQuery(todoSnapshotsSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, listGarden.ID, nil, nil, 0, nil, "V", nil, nil, nil, nil))
+	mock.ExpectExec("UPDATE todos SET done = TRUE, completed_at = curdate() WHERE done = FALSE AND id IN (?)").
+		WithArgs(todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(todoSnapshotsSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, true, todo.UserID, todo.CreatedAt, todo.CompletedAt, listGarden.ID, nil, nil, 0, nil, "V", nil, nil, nil, nil))
+	mock.ExpectExec(historyInsert).
+		WithArgs(entityTodo, todo.ID, todo.UserID, nil, nil, repo.ActionComplete, `{"done":{"before":"false","after":"true"}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, entityTodo, todo.ID, repo.ActionComplete)
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.CompleteAll(todo.UserID, repo.TodoFilter{ListID: listGarden.ID})
+	if err != nil {
+		t.Fatalf("mysqlRepository.CompleteAll() error = %v", err)
+	}
+	if want := []repo.BulkResult{{TodoID: todo.ID}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.CompleteAll() = %v, want %v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+type mysqlCommentRepository struct {
+	db *sql.DB
+}
+
+// NewCommentRepository returns the comment repository of the database r is
+// connected to. r must have been created by NewRepository.
+func NewCommentRepository(r repo.Repository) (repo.CommentRepository, error) {
+	mr, ok := r.(*mysqlRepository)
+	if !ok {
+		return nil, fmt.Errorf("NewCommentRepository %T is not a MySQL repository", r)
+	}
+	return &mysqlCommentRepository{mr.db}, nil
+}
+
+func (r *mysqlCommentRepository) CommentByID(id string) (repo.CommentRow, error) {
+	var comment repo.CommentRow
+	row := r.db.QueryRow("SELECT id, todo_id, author_id, body, created_at, updated_at FROM comments WHERE id = ?", id)
+	if err := row.Scan(&comment.ID, &comment.TodoID, &comment.AuthorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return comment, fmt.Errorf("CommentByID row scan: no row. %q %v", id, err)
+		}
+		return comment, fmt.Errorf("CommentByID row scan: %q %v", id, err)
+	}
+	return comment, nil
+}
+
+// CommentsByTodo returns a page of the comments on a todo, oldest first.
+func (r *mysqlCommentRepository) CommentsByTodo(todoId string, limit int, offset int) ([]repo.CommentRow, error) {
+	var comments []repo.CommentRow
+
+	rows, err := r.db.Query("SELECT id, todo_id, author_id, body, created_at, updated_at FROM comments "+
+		"WHERE todo_id = ? ORDER BY created_at, id LIMIT ? OFFSET ?", todoId, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("CommentsByTodo query %q: %v", todoId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment repo.CommentRow
+		if err := rows.Scan(&comment.ID, &comment.TodoID, &comment.AuthorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("CommentsByTodo scan row %q: %v", todoId, err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("CommentsByTodo rows err %q: %v", todoId, err)
+	}
+
+	return comments, nil
+}
+
+func (r *mysqlCommentRepository) AddComment(row repo.CommentRow) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO comments(id, todo_id, author_id, body, created_at, updated_at) VALUES (?, ?, ?, ?, now(), now())",
+		row.ID, row.TodoID, row.AuthorID, row.Body)
+	if err != nil {
+		return false, fmt.Errorf("AddComment exec : %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("AddComment fetch row after insertion : %v", err)
+	}
+	if inserted > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+// UpdateComment replaces the body of a comment and bumps its updated_at.
+func (r *mysqlCommentRepository) UpdateComment(row repo.CommentRow) (bool, error) {
+	result, err := r.db.Exec("UPDATE comments SET body = ?, updated_at = now() WHERE id = ?", row.Body, row.ID)
+	if err != nil {
+		return false, fmt.Errorf("UpdateComment exec : %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("UpdateComment fetch row after update : %v", err)
+	}
+	if updated > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *mysqlCommentRepository) DeleteComment(id string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteComment exec : %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("DeleteComment fetch row after delete : %v", err)
+	}
+	if deleted > 0 {
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+var commentCreatedAt = time.Date(2022, 5, 20, 10, 15, 0, 0, time.UTC)
+var comment = &repo.CommentRow{
+	ID:        "caajol287d5nsercmt1",
+	TodoID:    "caajol287d5nser73bs0",
+	AuthorID:  "1124chloezhuqing",
+	Body:      "The lilies need less water than the roses",
+	CreatedAt: commentCreatedAt,
+	UpdatedAt: commentCreatedAt,
+}
+var commentByOwner = &repo.CommentRow{
+	ID:        "caajol287d5nsercmt2",
+	TodoID:    "caajol287d5nser73bs0",
+	AuthorID:  "chloexu1124",
+	Body:      "Noted, thanks!",
+	CreatedAt: commentCreatedAt.Add(time.Hour),
+	UpdatedAt: commentCreatedAt.Add(time.Hour),
+}
+
+var commentColumns = []string{"id", "todo_id", "author_id", "body", "created_at", "updated_at"}
+
+func TestCommentByID(t *testing.T) {
+	db, mock := NewMock()
+	commentRepo := &mysqlCommentRepository{db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	query := "SELECT id, todo_id, author_id, body, created_at, updated_at FROM comments WHERE id = ?"
+	mock.ExpectQuery(query).WithArgs(comment.ID).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(comment.ID, comment.TodoID, comment.AuthorID, comment.Body, comment.CreatedAt, comment.UpdatedAt))
+	mock.ExpectQuery(query).WithArgs("missing").WillReturnRows(sqlmock.NewRows(commentColumns))
+
+	tests := []struct {
+		name    string
+		id      string
+		want    repo.CommentRow
+		wantErr bool
+	}{
+		{"test comment by id should return the comment", comment.ID, *comment, false},
+		{"test comment by unknown id should fail", "missing", repo.CommentRow{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := commentRepo.CommentByID(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlCommentRepository.CommentByID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mysqlCommentRepository.CommentByID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentsByTodo(t *testing.T) {
+	db, mock := NewMock()
+	commentRepo := &mysqlCommentRepository{db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	query := "SELECT id, todo_id, author_id, body, created_at, updated_at FROM comments " +
+		"WHERE todo_id = ? ORDER BY created_at, id LIMIT ? OFFSET ?"
+	mock.ExpectQuery(query).WithArgs(todo.ID, 2, 0).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(comment.ID, comment.TodoID, comment.AuthorID, comment.Body, comment.CreatedAt, comment.UpdatedAt).
+			AddRow(commentByOwner.ID, commentByOwner.TodoID, commentByOwner.AuthorID, commentByOwner.Body, commentByOwner.CreatedAt, commentByOwner.UpdatedAt))
+
+	got, err := commentRepo.CommentsByTodo(todo.ID, 2, 0)
+	if err != nil {
+		t.Fatalf("mysqlCommentRepository.CommentsByTodo() error = %v", err)
+	}
+	want := []repo.CommentRow{*comment, *commentByOwner}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlCommentRepository.CommentsByTodo() = %v, want %v", got, want)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	db, mock := NewMock()
+	commentRepo := &mysqlCommentRepository{db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	statement := "INSERT INTO comments(id, todo_id, author_id, body, created_at, updated_at) VALUES (?, ?, ?, ?, now(), now())"
+	mock.ExpectExec(statement).WithArgs(comment.ID, comment.TodoID, comment.AuthorID, comment.Body).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	got, err := commentRepo.AddComment(*comment)
+	if err != nil || !got {
+		t.Errorf("mysqlCommentRepository.AddComment() = %v, %v, want true, nil", got, err)
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	db, mock := NewMock()
+	commentRepo := &mysqlCommentRepository{db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	statement := "UPDATE comments SET body = ?, updated_at = now() WHERE id = ?"
+	mock.ExpectExec(statement).WithArgs("Edited", comment.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(statement).WithArgs("Edited", "missing").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"test update comment should update", comment.ID, true},
+		{"test update unknown comment should not update", "missing", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := commentRepo.UpdateComment(repo.CommentRow{ID: tt.id, Body: "Edited"})
+			if err != nil {
+				t.Errorf("mysqlCommentRepository.UpdateComment() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlCommentRepository.UpdateComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	db, mock := NewMock()
+	commentRepo := &mysqlCommentRepository{db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	mock.ExpectExec("DELETE FROM comments WHERE id = ?").WithArgs(comment.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	got, err := commentRepo.DeleteComment(comment.ID)
+	if err != nil || !got {
+		t.Errorf("mysqlCommentRepository.DeleteComment() = %v, %v, want true, nil", got, err)
+	}
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// ReserveIdempotencyKey deletes a stale reservation along with the expired
+// keys, so that the insert claims the key again. Of two mutations claiming it
+// at once, the insert lets one through.
+func (r *mysqlRepository) ReserveIdempotencyKey(row repo.IdempotencyRow, lease time.Duration) (bool, error) {
+	if _, err := r.db.ExecContext(r.context(), "DELETE FROM idempotency_keys WHERE user_id = ? AND "+
+		"(expires_at <= ? OR idempotency_key = ? AND result IS NULL AND created_at <= ?)",
+		row.UserID, row.CreatedAt, row.Key, row.CreatedAt.Add(-lease)); err != nil {
+		return false, fmt.Errorf("ReserveIdempotencyKey exec : %v", err)
+	}
+	result, err := r.db.ExecContext(r.context(), "INSERT IGNORE INTO idempotency_keys(user_id, idempotency_key, operation, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		row.UserID, row.Key, row.Operation, row.CreatedAt, row.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("ReserveIdempotencyKey exec : %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ReserveIdempotencyKey fetch row after insertion : %v", err)
+	}
+	return inserted > 0, nil
+}
+
+func (r *mysqlRepository) IdempotencyKey(userId string, key string) (repo.IdempotencyRow, error) {
+	var row repo.IdempotencyRow
+	scanned := r.db.QueryRowContext(r.context(), "SELECT user_id, idempotency_key, operation, result, created_at, expires_at FROM idempotency_keys "+
+		"WHERE user_id = ? AND idempotency_key = ?", userId, key)
+	if err := scanned.Scan(&row.UserID, &row.Key, &row.Operation, &row.Result, &row.CreatedAt, &row.ExpiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			return row, fmt.Errorf("IdempotencyKey row scan: no row. %q %q %v", userId, key, err)
+		}
+		return row, fmt.Errorf("IdempotencyKey row scan: %q %q %v", userId, key, err)
+	}
+	return row, nil
+}
+
+func (r *mysqlRepository) SaveIdempotencyResult(userId string, key string, result []byte) (bool, error) {
+	updated, err := r.db.ExecContext(r.context(), "UPDATE idempotency_keys SET result = ? WHERE user_id = ? AND idempotency_key = ?", result, userId, key)
+	if err != nil {
+		return false, fmt.Errorf("SaveIdempotencyResult exec : %v", err)
+	}
+
+	affected, err := updated.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("SaveIdempotencyResult fetch row after update : %v", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *mysqlRepository) ReleaseIdempotencyKey(userId string, key string) (bool, error) {
+	result, err := r.db.ExecContext(r.context(), "DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? AND result IS NULL", userId, key)
+	if err != nil {
+		return false, fmt.Errorf("ReleaseIdempotencyKey exec : %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ReleaseIdempotencyKey fetch row after delete : %v", err)
+	}
+	return deleted > 0, nil
+}
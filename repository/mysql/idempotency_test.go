@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	row := repo.IdempotencyRow{UserID: todo.UserID, Key: "retry-1", Operation: "createTodo", CreatedAt: now, ExpiresAt: now.Add(24 * time.Hour)}
+	insert := "INSERT IGNORE INTO idempotency_keys(user_id, idempotency_key, operation, created_at, expires_at) VALUES (?, ?, ?, ?, ?)"
+	for _, inserted := range []int64{1, 0} {
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id = ? AND "+
+			"(expires_at <= ? OR idempotency_key = ? AND result IS NULL AND created_at <= ?)").
+			WithArgs(todo.UserID, now, "retry-1", now.Add(-5*time.Minute)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(insert).WithArgs(todo.UserID, "retry-1", "createTodo", now, row.ExpiresAt).
+			WillReturnResult(sqlmock.NewResult(0, inserted))
+	}
+
+	// the key is taken by the first call
+	for _, want := range []bool{true, false} {
+		got, err := mysqlRepo.ReserveIdempotencyKey(row, 5*time.Minute)
+		if err != nil || got != want {
+			t.Errorf("mysqlRepository.ReserveIdempotencyKey() = %v, %v, want %v, nil", got, err, want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	result := []byte(`{"operationId":"caajol287d5nseroper"}`)
+	mock.ExpectQuery("SELECT user_id, idempotency_key, operation, result, created_at, expires_at FROM idempotency_keys "+
+		"WHERE user_id = ? AND idempotency_key = ?").
+		WithArgs(todo.UserID, "retry-1").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "idempotency_key", "operation", "result", "created_at", "expires_at"}).
+			AddRow(todo.UserID, "retry-1", "createTodo", result, now, now.Add(24*time.Hour)))
+
+	got, err := mysqlRepo.IdempotencyKey(todo.UserID, "retry-1")
+	if err != nil {
+		t.Fatalf("mysqlRepository.IdempotencyKey() error = %v", err)
+	}
+	want := repo.IdempotencyRow{UserID: todo.UserID, Key: "retry-1", Operation: "createTodo", Result: result, CreatedAt: now, ExpiresAt: now.Add(24 * time.Hour)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.IdempotencyKey() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func (r *mysqlRepository) TodoListByID(id string) (repo.TodoListRow, error) {
+	var list repo.TodoListRow
+	row := r.db.QueryRowContext(r.context(), "SELECT id, user_id, name, archived, created_at FROM todo_lists WHERE id = ?", id)
+	if err := row.Scan(&list.ID, &list.UserID, &list.Name, &list.Archived, &list.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return list, fmt.Errorf("TodoListByID row scan: no row. %q %v", id, err)
+		}
+		return list, fmt.Errorf("TodoListByID row scan: %q %v", id, err)
+	}
+	return list, nil
+}
+
+// TodoListsByUser returns the lists a user owns or that are shared with them.
+func (r *mysqlRepository) TodoListsByUser(userId string, includeArchived bool) ([]repo.TodoListRow, error) {
+	var lists []repo.TodoListRow
+
+	query := "SELECT id, user_id, name, archived, created_at FROM todo_lists " +
+		"WHERE (user_id = ? OR id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?))"
+	if !includeArchived {
+		query += " AND archived = FALSE"
+	}
+	rows, err := r.db.QueryContext(r.context(), query+" ORDER BY created_at", userId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("TodoListsByUser query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var list repo.TodoListRow
+		if err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Archived, &list.CreatedAt); err != nil {
+			return nil, fmt.Errorf("TodoListsByUser scan row %q: %v", userId, err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodoListsByUser rows err %q: %v", userId, err)
+	}
+
+	return lists, nil
+}
+
+func (r *mysqlRepository) TodosByList(listId string) ([]repo.TodoRow, error) {
+	var todos []repo.TodoRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT "+todoColumns+" FROM todos WHERE list_id = ? AND deleted_at IS NULL ORDER BY position, id", listId)
+	if err != nil {
+		return nil, fmt.Errorf("TodosByList query %q: %v", listId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo repo.TodoRow
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("TodosByList scan row %q: %v", listId, err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodosByList rows err %q: %v", listId, err)
+	}
+
+	return todos, nil
+}
+
+func (r *mysqlRepository) AddTodoList(row repo.TodoListRow) (bool, error) {
+	return r.auditedExec("AddTodoList", entityList, repo.ActionCreate, row.ID,
+		"INSERT INTO todo_lists(id, user_id, name, archived, created_at) VALUES (?, ?, ?, ?, now())",
+		row.ID, row.UserID, row.Name, row.Archived)
+}
+
+func (r *mysqlRepository) UpdateTodoList(row repo.TodoListRow) (bool, error) {
+	return r.auditedExec("UpdateTodoList", entityList, repo.ActionUpdate, row.ID,
+		"UPDATE todo_lists SET name = ?, archived = ? WHERE id = ?", row.Name, row.Archived, row.ID)
+}
+
+// DeleteTodoList removes a list. Its todos are kept and fall back to having
+// no list through the ON DELETE SET NULL foreign key.
+func (r *mysqlRepository) DeleteTodoList(id string) (bool, error) {
+	return r.auditedExec("DeleteTodoList", entityList, repo.ActionDelete, id,
+		"DELETE FROM todo_lists WHERE id = ?", id)
+}
+
+// MoveTodoToList puts a todo into a list. An empty listId takes the todo out
+// of any list.
+func (r *mysqlRepository) MoveTodoToList(todoId string, listId string) (bool, error) {
+	return r.auditedExec("MoveTodoToList", entityTodo, repo.ActionMove, todoId,
+		"UPDATE todos SET list_id = ? WHERE id = ?", nullString(listId), todoId)
+}
@@ -0,0 +1,154 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+var listCreatedAt = time.Date(2022, 5, 20, 9, 30, 0, 0, time.UTC)
+var listGarden = &repo.TodoListRow{
+	ID:        "caajol287d5nserlist1",
+	UserID:    "chloexu1124",
+	Name:      "Garden",
+	CreatedAt: listCreatedAt,
+}
+var listArchived = &repo.TodoListRow{
+	ID:        "caajol287d5nserlist2",
+	UserID:    "chloexu1124",
+	Name:      "Moving house",
+	Archived:  true,
+	CreatedAt: listCreatedAt,
+}
+
+func TestTodoListsByUser(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	columns := []string{"id", "user_id", "name", "archived", "created_at"}
+
+	mock.ExpectQuery("SELECT id, user_id, name, archived, created_at FROM todo_lists "+
+		"WHERE (user_id = ? OR id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?)) AND archived = FALSE ORDER BY created_at").
+		WithArgs(listGarden.UserID, listGarden.UserID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(listGarden.ID, listGarden.UserID, listGarden.Name, listGarden.Archived, listGarden.CreatedAt))
+	mock.ExpectQuery("SELECT id, user_id, name, archived, created_at FROM todo_lists "+
+		"WHERE (user_id = ? OR id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?)) ORDER BY created_at").
+		WithArgs(listGarden.UserID, listGarden.UserID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(listGarden.ID, listGarden.UserID, listGarden.Name, listGarden.Archived, listGarden.CreatedAt).
+			AddRow(listArchived.ID, listArchived.UserID, listArchived.Name, listArchived.Archived, listArchived.CreatedAt))
+
+	tests := []struct {
+		name            string
+		includeArchived bool
+		want            []repo.TodoListRow
+	}{
+		{"test lists by user should skip archived lists", false, []repo.TodoListRow{*listGarden}},
+		{"test lists by user should include archived lists on request", true, []repo.TodoListRow{*listGarden, *listArchived}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.TodoListsByUser(listGarden.UserID, tt.includeArchived)
+			if err != nil {
+				t.Errorf("mysqlRepository.TodoListsByUser() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mysqlRepository.TodoListsByUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodosByList(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos WHERE list_id = ? AND deleted_at IS NULL ORDER BY position, id"
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, listGarden.ID, nil, nil, 0, nil, nil)
+	mock.ExpectQuery(query).WithArgs(listGarden.ID).WillReturnRows(rows)
+
+	got, err := mysqlRepo.TodosByList(listGarden.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodosByList() error = %v", err)
+	}
+	want := *todo
+	want.ListID = listGarden.ID
+	if !reflect.DeepEqual(got, []repo.TodoRow{want}) {
+		t.Errorf("mysqlRepository.TodosByList() = %v, want %v", got, []repo.TodoRow{want})
+	}
+}
+
+func TestAddTodoList(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "INSERT INTO todo_lists(id, user_id, name, archived, created_at) VALUES (?, ?, ?, ?, now())"
+	expectAudited(mock, entityList, listGarden.ID, repo.ActionCreate, func() {
+		mock.ExpectExec(statement).WithArgs(listGarden.ID, listGarden.UserID, listGarden.Name, false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
+
+	got, err := mysqlRepo.AddTodoList(*listGarden)
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.AddTodoList() = %v, %v, want true, nil", got, err)
+	}
+}
+
+func TestMoveTodoToList(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "UPDATE todos SET list_id = ? WHERE id = ?"
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionMove, func() {
+		mock.ExpectExec(statement).WithArgs(listGarden.ID, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionMove, func() {
+		mock.ExpectExec(statement).WithArgs(nil, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	tests := []struct {
+		name   string
+		listId string
+		want   bool
+	}{
+		{"test move todo into a list should update", listGarden.ID, true},
+		{"test move todo out of its list should set null", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.MoveTodoToList(todo.ID, tt.listId)
+			if err != nil {
+				t.Errorf("mysqlRepository.MoveTodoToList() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.MoveTodoToList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS todos (
+  id           VARCHAR(20)  NOT NULL,
+  text         VARCHAR(255) NOT NULL,
+  done         BOOLEAN      NOT NULL DEFAULT FALSE,
+  user_id      VARCHAR(64)  NOT NULL,
+  created_at   DATETIME     NOT NULL,
+  completed_at DATETIME     NULL,
+  PRIMARY KEY (id),
+  KEY idx_todos_user_id (user_id)
+);
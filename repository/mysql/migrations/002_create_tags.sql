@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS tags (
+  id      VARCHAR(20) NOT NULL,
+  user_id VARCHAR(64) NOT NULL,
+  name    VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_tags_user_id_name (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS todo_tags (
+  todo_id VARCHAR(20) NOT NULL,
+  tag_id  VARCHAR(20) NOT NULL,
+  PRIMARY KEY (todo_id, tag_id),
+  KEY idx_todo_tags_tag_id (tag_id),
+  CONSTRAINT fk_todo_tags_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE,
+  CONSTRAINT fk_todo_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS todo_lists (
+  id         VARCHAR(20)  NOT NULL,
+  user_id    VARCHAR(64)  NOT NULL,
+  name       VARCHAR(255) NOT NULL,
+  archived   BOOLEAN      NOT NULL DEFAULT FALSE,
+  created_at DATETIME     NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_todo_lists_user_id (user_id, archived)
+);
+
+ALTER TABLE todos
+  ADD COLUMN list_id VARCHAR(20) NULL,
+  ADD KEY idx_todos_list_id (list_id),
+  ADD CONSTRAINT fk_todos_list FOREIGN KEY (list_id) REFERENCES todo_lists (id) ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE todos
+  ADD COLUMN due_at   DATETIME NULL,
+  ADD COLUMN priority TINYINT  NOT NULL DEFAULT 1,
+  ADD KEY idx_todos_user_id_done_due_at (user_id, done, due_at),
+  ADD KEY idx_todos_user_id_due_at (user_id, due_at);
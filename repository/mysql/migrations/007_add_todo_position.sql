@@ -0,0 +1,7 @@
+ALTER TABLE todos
+  ADD COLUMN position VARCHAR(255) CHARACTER SET ascii COLLATE ascii_bin NULL,
+  ADD KEY idx_todos_user_id_position (user_id, position);
+
+-- Existing todos keep their creation order: xid ids sort by creation time
+-- and only use rank digits, and the suffix keeps keys from ending in '0'.
+UPDATE todos SET position = CONCAT(id, '1') WHERE position IS NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS comments (
+  id         VARCHAR(20) NOT NULL,
+  todo_id    VARCHAR(20) NOT NULL,
+  author_id  VARCHAR(64) NOT NULL,
+  body       TEXT        NOT NULL,
+  created_at DATETIME    NOT NULL,
+  updated_at DATETIME    NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_comments_todo_id (todo_id, created_at),
+  CONSTRAINT fk_comments_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
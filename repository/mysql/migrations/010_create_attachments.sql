@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS attachments (
+  id           VARCHAR(20)  NOT NULL,
+  todo_id      VARCHAR(20)  NOT NULL,
+  filename     VARCHAR(255) NOT NULL,
+  content_type VARCHAR(127) NOT NULL,
+  size         BIGINT       NOT NULL,
+  blob_key     VARCHAR(255) NOT NULL,
+  created_at   DATETIME     NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_attachments_todo_id (todo_id, created_at),
+  CONSTRAINT fk_attachments_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
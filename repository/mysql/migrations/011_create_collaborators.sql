@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS todo_collaborators (
+  todo_id    VARCHAR(20)              NOT NULL,
+  user_id    VARCHAR(64)              NOT NULL,
+  role       ENUM('viewer', 'editor') NOT NULL,
+  created_at DATETIME                 NOT NULL,
+  PRIMARY KEY (todo_id, user_id),
+  KEY idx_todo_collaborators_user_id (user_id),
+  CONSTRAINT fk_todo_collaborators_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS list_collaborators (
+  list_id    VARCHAR(20)              NOT NULL,
+  user_id    VARCHAR(64)              NOT NULL,
+  role       ENUM('viewer', 'editor') NOT NULL,
+  created_at DATETIME                 NOT NULL,
+  PRIMARY KEY (list_id, user_id),
+  KEY idx_list_collaborators_user_id (user_id),
+  CONSTRAINT fk_list_collaborators_list FOREIGN KEY (list_id) REFERENCES todo_lists (id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS history (
+  id          BIGINT      NOT NULL AUTO_INCREMENT,
+  entity_type VARCHAR(16) NOT NULL,
+  entity_id   VARCHAR(20) NOT NULL,
+  owner_id    VARCHAR(64) NOT NULL,
+  actor_id    VARCHAR(64) NULL,
+  action      VARCHAR(32) NOT NULL,
+  changes     JSON        NOT NULL,
+  created_at  DATETIME(6) NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_history_entity (entity_type, entity_id, id),
+  KEY idx_history_owner_id (owner_id, id),
+  KEY idx_history_actor_id (actor_id, id)
+);
+
+-- History is append-only: entries outlive what they describe and are never
+-- changed afterwards.
+CREATE TRIGGER history_no_update BEFORE UPDATE ON history
+  FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'history is append-only';
+
+CREATE TRIGGER history_no_delete BEFORE DELETE ON history
+  FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'history is append-only';
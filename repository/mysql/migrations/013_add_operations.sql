@@ -0,0 +1,9 @@
+-- Deleted todos are kept, hidden, so that their deletion can be undone.
+ALTER TABLE todos
+  ADD COLUMN deleted_at DATETIME NULL;
+
+-- History entries of one mutation share an operation id, to be undone
+-- together.
+ALTER TABLE history
+  ADD COLUMN operation_id VARCHAR(20) NULL AFTER actor_id,
+  ADD KEY idx_history_operation_id (operation_id, id);
@@ -0,0 +1,13 @@
+-- The result of a mutation made with an idempotency key, returned again when
+-- the client retries with the same key. result is NULL while the mutation is
+-- running.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  user_id         VARCHAR(64)  NOT NULL,
+  idempotency_key VARCHAR(255) NOT NULL,
+  operation       VARCHAR(64)  NOT NULL,
+  result          JSON         NULL,
+  created_at      DATETIME     NOT NULL,
+  expires_at      DATETIME     NOT NULL,
+  PRIMARY KEY (user_id, idempotency_key),
+  KEY idx_idempotency_keys_expires_at (user_id, expires_at)
+);
@@ -0,0 +1,14 @@
+-- Offline clients choose the ids of the todos they create, either xids or
+-- UUIDs, which are 36 characters long.
+SET FOREIGN_KEY_CHECKS = 0;
+
+ALTER TABLE todos
+  MODIFY COLUMN id VARCHAR(36) NOT NULL,
+  MODIFY COLUMN parent_id VARCHAR(36) NULL;
+ALTER TABLE todo_tags MODIFY COLUMN todo_id VARCHAR(36) NOT NULL;
+ALTER TABLE comments MODIFY COLUMN todo_id VARCHAR(36) NOT NULL;
+ALTER TABLE attachments MODIFY COLUMN todo_id VARCHAR(36) NOT NULL;
+ALTER TABLE todo_collaborators MODIFY COLUMN todo_id VARCHAR(36) NOT NULL;
+ALTER TABLE history MODIFY COLUMN entity_id VARCHAR(36) NOT NULL;
+
+SET FOREIGN_KEY_CHECKS = 1;
@@ -0,0 +1,14 @@
+-- Events written along with every change, in the same transaction, for the
+-- relay to deliver to other services. sent_at is set once a sink accepted the
+-- event.
+CREATE TABLE IF NOT EXISTS outbox (
+  id          BIGINT      NOT NULL AUTO_INCREMENT,
+  event_type  VARCHAR(48) NOT NULL,
+  entity_type VARCHAR(16) NOT NULL,
+  entity_id   VARCHAR(36) NOT NULL,
+  payload     JSON        NOT NULL,
+  created_at  DATETIME(6) NOT NULL,
+  sent_at     DATETIME(6) NULL,
+  PRIMARY KEY (id),
+  KEY idx_outbox_sent_at (sent_at, id)
+);
@@ -0,0 +1,36 @@
+-- Events are matched to the webhooks of the owner of their entity.
+ALTER TABLE outbox
+  ADD COLUMN owner_id VARCHAR(64) NOT NULL DEFAULT '' AFTER entity_id;
+
+-- event_types is a comma separated list such as "todo.complete,todo.create".
+CREATE TABLE IF NOT EXISTS webhooks (
+  id          VARCHAR(20)   NOT NULL,
+  user_id     VARCHAR(64)   NOT NULL,
+  url         VARCHAR(2048) NOT NULL,
+  event_types VARCHAR(1024) NOT NULL,
+  secret      VARCHAR(255)  NOT NULL,
+  created_at  DATETIME      NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_webhooks_user_id (user_id)
+);
+
+-- A delivery of an event to a webhook, which doubles as its log. status is
+-- pending until the receiver accepts it, or dead once it ran out of attempts.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id               BIGINT        NOT NULL AUTO_INCREMENT,
+  webhook_id       VARCHAR(20)   NOT NULL,
+  event_id         BIGINT        NOT NULL,
+  event_type       VARCHAR(48)   NOT NULL,
+  payload          JSON          NOT NULL,
+  status           VARCHAR(16)   NOT NULL,
+  attempts         INT           NOT NULL DEFAULT 0,
+  next_attempt_at  DATETIME(6)   NULL,
+  last_status_code INT           NULL,
+  last_error       VARCHAR(1024) NULL,
+  created_at       DATETIME(6)   NOT NULL,
+  delivered_at     DATETIME(6)   NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_webhook_deliveries_event (webhook_id, event_id),
+  KEY idx_webhook_deliveries_due (status, next_attempt_at),
+  CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
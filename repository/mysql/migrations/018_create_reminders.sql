@@ -0,0 +1,21 @@
+-- A reminder of a todo, sent to its user through channel at fire_at. While
+-- it is pending, next_attempt_at is when a scheduler may next claim it: at
+-- first fire_at, then the end of a claim or of the wait before a retry.
+CREATE TABLE IF NOT EXISTS reminders (
+  id              VARCHAR(20)   NOT NULL,
+  todo_id         VARCHAR(36)   NOT NULL,
+  user_id         VARCHAR(64)   NOT NULL,
+  fire_at         DATETIME      NOT NULL,
+  channel         VARCHAR(16)   NOT NULL,
+  address         VARCHAR(320)  NULL,
+  status          VARCHAR(16)   NOT NULL,
+  attempts        INT           NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME      NULL,
+  last_error      VARCHAR(1024) NULL,
+  created_at      DATETIME      NOT NULL,
+  sent_at         DATETIME      NULL,
+  PRIMARY KEY (id),
+  KEY idx_reminders_due (status, next_attempt_at),
+  KEY idx_reminders_user_id (user_id, fire_at),
+  CONSTRAINT fk_reminders_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- Todos are versioned by their history, which todos written before history
+-- was kept have none of, so they would never be synced. Each gets a creation
+-- entry as its baseline version.
+INSERT INTO history (entity_type, entity_id, owner_id, action, changes, created_at)
+SELECT 'todo', t.id, t.user_id, 'create', JSON_OBJECT(), now(6)
+FROM todos t
+WHERE NOT EXISTS (SELECT 1 FROM history h WHERE h.entity_type = 'todo' AND h.entity_id = t.id)
+ORDER BY t.id;
@@ -1,19 +1,110 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
+	"strings"
+	"time"
 
 	repo "github.com/chloexu/hackernews/repository"
 	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog/log"
 )
 
 type mysqlRepository struct {
+	// db is the primary database, which takes all writes.
 	db *sql.DB
+	// replicas take the reads that may lag behind writes, when there are
+	// any, unless onPrimary is set.
+	replicas  *replicaSet
+	onPrimary bool
+	// actor is the user named in the history entries of writes.
+	actor string
+	// operation is the operation the history entries of writes belong to.
+	operation string
+	// ctx is the context of the request queries are made for, if any.
+	ctx context.Context
 }
 
+// context returns the context queries are made with.
+func (r *mysqlRepository) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// DriverName is the database/sql driver repositories connect with. It may
+// name a driver wrapping the MySQL one registered as "mysql".
+var DriverName = "mysql"
+
+// todoColumns lists the todos columns in the order scanTodo reads them.
+const todoColumns = "id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position"
+
+// accessibleTodos restricts a todos query to the todos a user owns or that
+// are shared with them, directly or through their list, deleted or not. Its
+// placeholders are filled by visibleTodosArgs.
+const accessibleTodos = "(user_id = ? OR id IN (SELECT todo_id FROM todo_collaborators WHERE user_id = ?) " +
+	"OR list_id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?))"
+
+// visibleTodos is accessibleTodos without the deleted todos.
+const visibleTodos = "deleted_at IS NULL AND " + accessibleTodos
+
+func visibleTodosArgs(userId string) []interface{} {
+	return []interface{}{userId, userId, userId}
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo reads the todoColumns of a row, followed by any extra columns
+// selected after them.
+func scanTodo(s scanner, todo *repo.TodoRow, extra ...interface{}) error {
+	var listID, parentID, recurrence, position sql.NullString
+	var dueAt sql.NullTime
+	dest := []interface{}{&todo.ID, &todo.Text, &todo.Done, &todo.UserID, &todo.CreatedAt, &todo.CompletedAt,
+		&listID, &parentID, &dueAt, &todo.Priority, &recurrence, &position}
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	todo.ListID = listID.String
+	todo.ParentID = parentID.String
+	todo.DueAt = dueAt.Time
+	todo.Recurrence = recurrence.String
+	todo.Position = position.String
+	return nil
+}
+
+// placeholders returns n comma separated placeholders, for IN lists.
+func placeholders(n int) string {
+	return strings.TrimPrefix(strings.Repeat(", ?", n), ", ")
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime stores zero times as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// NewRepository connects to the todos_db database on the local server as
+// DBUSER with DBPASS, and to the replicas in DB_REPLICA_DSNS, a comma
+// separated list of DSNs.
 func NewRepository() (repo.Repository, error) {
 
 	// Capture connection properties
@@ -26,31 +117,78 @@ func NewRepository() (repo.Repository, error) {
 		ParseTime: true,
 	}
 
-	// Get a database handle.
-	var err error
-	var db *sql.DB
+	var replicaDSNs []string
+	for _, dsn := range strings.Split(os.Getenv("DB_REPLICA_DSNS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicaDSNs = append(replicaDSNs, dsn)
+		}
+	}
+	return Open(cfg.FormatDSN(), replicaDSNs...)
+}
 
-	db, err = sql.Open("mysql", cfg.FormatDSN())
+// Open connects to the primary database at primaryDSN, which takes writes,
+// and to its replicas, which take the reads of TodoByID and TodosByUser. The
+// DSNs must set parseTime. Replicas are health checked in the background and
+// skipped while they fail.
+func Open(primaryDSN string, replicaDSNs ...string) (repo.Repository, error) {
+	db, err := sql.Open(DriverName, primaryDSN)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Open primary : %v", err)
 	}
 
 	pingErr := db.Ping()
 	if pingErr != nil {
-		log.Fatal(pingErr)
+		db.Close()
+		return nil, fmt.Errorf("Open ping primary : %v", pingErr)
 	}
-	log.Println("DB connection established.")
-	return &mysqlRepository{db}, nil
+	log.Info().Msg("DB connection established.")
+
+	r := &mysqlRepository{db: db}
+	if len(replicaDSNs) > 0 {
+		if r.replicas, err = openReplicas(replicaDSNs); err != nil {
+			db.Close()
+			return nil, err
+		}
+		go r.replicas.monitor(healthCheckInterval)
+		log.Info().Int("replicas", len(replicaDSNs)).Msg("Reading from replicas.")
+	}
+	return r, nil
+}
+
+// Pools returns the connection pools of a MySQL repository by name: the
+// primary as "primary", and replicas by their address.
+func Pools(r repo.Repository) (map[string]*sql.DB, error) {
+	mr, ok := r.(*mysqlRepository)
+	if !ok {
+		return nil, fmt.Errorf("Pools %T is not a MySQL repository", r)
+	}
+	pools := map[string]*sql.DB{"primary": mr.db}
+	if mr.replicas != nil {
+		for _, replica := range mr.replicas.replicas {
+			pools[replica.name] = replica.db
+		}
+	}
+	return pools, nil
+}
+
+// Primary returns a repository reading from the primary database only.
+func (r *mysqlRepository) Primary() repo.Repository {
+	primary := *r
+	primary.onPrimary = true
+	return &primary
 }
 
 func (r *mysqlRepository) Close() {
+	if r.replicas != nil {
+		r.replicas.close()
+	}
 	r.db.Close()
 }
 
 func (r *mysqlRepository) TodoByID(id string) (repo.TodoRow, error) {
 	var todo repo.TodoRow
-	row := r.db.QueryRow("SELECT id, text, done, user_id, created_at, completed_at FROM todos WHERE id = ?", id)
-	if err := row.Scan(&todo.ID, &todo.Text, &todo.Done, &todo.UserID, &todo.CreatedAt, &todo.CompletedAt); err != nil {
+	row := r.reader().QueryRowContext(r.context(), "SELECT "+todoColumns+" FROM todos WHERE id = ? AND deleted_at IS NULL", id)
+	if err := scanTodo(row, &todo); err != nil {
 		if err == sql.ErrNoRows {
 			return todo, fmt.Errorf("TodoByID row scan: no row. %q %v", id, err)
 		}
@@ -59,12 +197,13 @@ func (r *mysqlRepository) TodoByID(id string) (repo.TodoRow, error) {
 	return todo, nil
 }
 
+// TodosByUser returns the todos a user owns or that are shared with them.
 func (r *mysqlRepository) TodosByUser(userId string) ([]repo.TodoRow, error) {
 	// define todos slice to hold data from returned rows
 	var todos []repo.TodoRow
 
 	/// read data from db
-	rows, err := r.db.Query("SELECT id, text, done, user_id, created_at, completed_at FROM todos WHERE user_id = ?", userId)
+	rows, err := r.reader().QueryContext(r.context(), "SELECT "+todoColumns+" FROM todos WHERE "+visibleTodos+" ORDER BY position, id", visibleTodosArgs(userId)...)
 	if err != nil {
 		return nil, fmt.Errorf("TodosByUsers query %q: %v", userId, err)
 	}
@@ -74,7 +213,7 @@ func (r *mysqlRepository) TodosByUser(userId string) ([]repo.TodoRow, error) {
 	// loop through rows, using Scan to assign column data to struct fields
 	for rows.Next() {
 		var todo repo.TodoRow
-		if err := rows.Scan(&todo.ID, &todo.Text, &todo.Done, &todo.UserID, &todo.CreatedAt, &todo.CompletedAt); err != nil {
+		if err := scanTodo(rows, &todo); err != nil {
 			return nil, fmt.Errorf("TodosByUsers scan row %q: %v", userId, err)
 		}
 		todos = append(todos, todo)
@@ -91,60 +230,70 @@ func (r *mysqlRepository) TodosByUser(userId string) ([]repo.TodoRow, error) {
 }
 
 func (r *mysqlRepository) AddTodo(row repo.TodoRow) (bool, error) {
-	result, err := r.db.Exec("INSERT INTO todos(id, text, done, user_id, created_at, completed_at) VALUES (?, ?, ?, ?, curdate(), curdate())",
-		row.ID, row.Text, row.Done, row.UserID)
-	if err != nil {
-		return false, fmt.Errorf("AddTodo exec : %v", err)
-	}
+	return r.audited("AddTodo", entityTodo, repo.ActionCreate, row.ID, func(tx *sql.Tx) (sql.Result, error) {
+		result, err := insertTodo(tx, row)
+		if err != nil {
+			return nil, fmt.Errorf("AddTodo exec : %v", err)
+		}
+		return result, nil
+	})
+}
 
-	inserted, err := result.RowsAffected()
-	if err != nil {
-		return false, fmt.Errorf("AddTodo fetch row after insertion : %v", err)
-	}
-	if inserted > 0 {
-		return true, nil
-	}
-	return false, nil
+func insertTodo(tx *sql.Tx, row repo.TodoRow) (sql.Result, error) {
+	return tx.Exec("INSERT INTO todos(id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position) VALUES (?, ?, ?, ?, curdate(), curdate(), ?, ?, ?, ?, ?, ?)",
+		row.ID, row.Text, row.Done, row.UserID, nullString(row.ListID), nullString(row.ParentID), nullTime(row.DueAt), row.Priority,
+		nullString(row.Recurrence), nullString(row.Position))
 }
 
 func (r *mysqlRepository) UpdateTodo(row repo.TodoRow) (bool, error) {
-	var result sql.Result
-	if row.Text != "" {
-		if row.Done {
-			r1, err := r.db.Exec("UPDATE todos SET text = ?, done = ?, completed_at = curdate() where id = ?", row.Text, row.Done, row.ID)
-			if err != nil {
-				return false, fmt.Errorf("UpdateTodo exec : %v", err)
+	return r.audited("UpdateTodo", entityTodo, repo.ActionUpdate, row.ID, func(tx *sql.Tx) (sql.Result, error) {
+		var result sql.Result
+		if row.Text != "" {
+			if row.Done {
+				r1, err := tx.Exec("UPDATE todos SET text = ?, done = ?, completed_at = curdate() where id = ?", row.Text, row.Done, row.ID)
+				if err != nil {
+					return nil, fmt.Errorf("UpdateTodo exec : %v", err)
+				}
+				result = r1
+			} else {
+				r2, err := tx.Exec("UPDATE todos SET text = ?, done = ?, completed_at = null where id = ?", row.Text, row.Done, row.ID)
+				if err != nil {
+					return nil, fmt.Errorf("UpdateTodo exec : %v", err)
+				}
+				result = r2
 			}
-			result = r1
 		} else {
-			r2, err := r.db.Exec("UPDATE todos SET text = ?, done = ?, completed_at = null where id = ?", row.Text, row.Done, row.ID)
-			if err != nil {
-				return false, fmt.Errorf("UpdateTodo exec : %v", err)
+			if row.Done {
+				r1, err := tx.Exec("UPDATE todos SET done = ?, completed_at = curdate() where id = ?", row.Done, row.ID)
+				if err != nil {
+					return nil, fmt.Errorf("UpdateTodo exec : %v", err)
+				}
+				result = r1
+			} else {
+				r2, err := tx.Exec("UPDATE todos SET done = ?, completed_at = null where id = ?", row.Done, row.ID)
+				if err != nil {
+					return nil, fmt.Errorf("UpdateTodo exec : %v", err)
+				}
+				result = r2
 			}
-			result = r2
 		}
-	} else {
-		if row.Done {
-			r1, err := r.db.Exec("UPDATE todos SET done = ?, completed_at = curdate() where id = ?", row.Done, row.ID)
-			if err != nil {
-				return false, fmt.Errorf("UpdateTodo exec : %v", err)
-			}
-			result = r1
-		} else {
-			r2, err := r.db.Exec("UPDATE todos SET done = ?, completed_at = null where id = ?", row.Done, row.ID)
-			if err != nil {
-				return false, fmt.Errorf("UpdateTodo exec : %v", err)
-			}
-			result = r2
-		}
-	}
+		return result, nil
+	})
+}
 
-	updated, err := result.RowsAffected()
-	if err != nil {
-		return false, fmt.Errorf("UpdateTodo fetch row after update : %v", err)
-	}
-	if updated > 0 {
-		return true, nil
-	}
-	return false, nil
+// DeleteTodo hides a todo and its subtasks. The rows stay, with deleted_at
+// set, so that comments and attachments survive an undo of the deletion. The
+// todo itself is recorded first, before its subtasks.
+func (r *mysqlRepository) DeleteTodo(id string) (bool, error) {
+	deleted, err := r.auditedTodos("DeleteTodo", repo.ActionDelete, subtreeWith, deleteSubtreePick, deleteSubtreeWrite, id)
+	return deleted > 0, err
 }
+
+// The statements of DeleteTodo, for writeTodos.
+const (
+	subtreeWith = "WITH RECURSIVE subtree AS (" +
+		"SELECT id, 0 AS depth FROM todos WHERE id = ? " +
+		"UNION ALL SELECT t.id, s.depth + 1 FROM todos t JOIN subtree s ON t.parent_id = s.id) "
+	deleteSubtreePick  = "SELECT t.id FROM todos t JOIN subtree s ON s.id = t.id WHERE t.deleted_at IS NULL ORDER BY s.depth, t.id"
+	deleteSubtreeWrite = "UPDATE todos t JOIN subtree s ON s.id = t.id SET t.deleted_at = now() WHERE t.deleted_at IS NULL"
+)
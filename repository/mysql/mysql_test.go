@@ -1,10 +1,12 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -74,15 +76,15 @@ func TestTodoByID(t *testing.T) {
 		id string
 	}
 	db, mock := NewMock()
-	mysqlRepo := &mysqlRepository{db}
+	mysqlRepo := &mysqlRepository{db: db}
 
 	defer func() {
 		mysqlRepo.Close()
 	}()
 
-	query := "SELECT id, text, done, user_id, created_at, completed_at FROM todos WHERE id = ?"
-	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at"}).
-		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt)
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos WHERE id = ? AND deleted_at IS NULL"
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil)
 	mock.ExpectQuery(query).WithArgs(todo.ID).WillReturnRows(rows)
 
 	tests := []struct {
@@ -120,24 +122,24 @@ func TestTodosByUser(t *testing.T) {
 	}
 
 	db, mock := NewMock()
-	mysqlRepo := &mysqlRepository{db}
+	mysqlRepo := &mysqlRepository{db: db}
 
 	defer func() {
 		mysqlRepo.Close()
 	}()
 
-	query := "SELECT  id, text, done, user_id, created_at, completed_at FROM todos WHERE user_id = ?"
+	query := "SELECT  id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos WHERE " + visibleTo + " ORDER BY position, id"
 
-	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at"}).
-		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt).
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil).
 		AddRow(todoBySameUser.ID, todoBySameUser.Text, todoBySameUser.Done, todoBySameUser.UserID,
-			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt)
-	mock.ExpectQuery(query).WithArgs(todo.UserID).WillReturnRows(rows)
+			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt, nil, nil, nil, 0, nil, nil)
+	mock.ExpectQuery(query).WithArgs(todo.UserID, todo.UserID, todo.UserID).WillReturnRows(rows)
 
-	rowsOfDiffUser := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at"}).
+	rowsOfDiffUser := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
 		AddRow(todoByDifferentUser.ID, todoByDifferentUser.Text, todoByDifferentUser.Done, todoByDifferentUser.UserID,
-			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt)
-	mock.ExpectQuery(query).WithArgs(todoByDifferentUser.UserID).WillReturnRows(rowsOfDiffUser)
+			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt, nil, nil, nil, 0, nil, nil)
+	mock.ExpectQuery(query).WithArgs(todoByDifferentUser.UserID, todoByDifferentUser.UserID, todoByDifferentUser.UserID).WillReturnRows(rowsOfDiffUser)
 
 	tests := []struct {
 		name    string
@@ -186,17 +188,19 @@ func TestAddTodo(t *testing.T) {
 	}
 
 	db, mock := NewMock()
-	mysqlRepo := &mysqlRepository{db}
+	mysqlRepo := &mysqlRepository{db: db}
 
 	defer func() {
 		mysqlRepo.Close()
 	}()
 
-	statement := "INSERT INTO todos(id, text, done, user_id, created_at, completed_at) VALUES (?, ?, ?, ?, curdate(), curdate())"
+	statement := "INSERT INTO todos(id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position) VALUES (?, ?, ?, ?, curdate(), curdate(), ?, ?, ?, ?, ?, ?)"
 
-	mock.ExpectExec(statement).WithArgs(
-		todo.ID, todo.Text, todo.Done, todo.UserID,
-	).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionCreate, func() {
+		mock.ExpectExec(statement).WithArgs(
+			todo.ID, todo.Text, todo.Done, todo.UserID, nil, nil, nil, todo.Priority, nil, nil,
+		).WillReturnResult(sqlmock.NewResult(1, 1))
+	})
 
 	tests := []struct {
 		name    string
@@ -239,27 +243,35 @@ func TestUpdateTodo(t *testing.T) {
 	}
 
 	db, mock := NewMock()
-	mysqlRepo := &mysqlRepository{db}
+	mysqlRepo := &mysqlRepository{db: db}
 
 	defer func() {
 		mysqlRepo.Close()
 	}()
 
 	statement1 := "UPDATE todos SET text = ?, done = ?, completed_at = curdate() where id = ?"
-	mock.ExpectExec(statement1).WithArgs(todoUpdateTextDone.Text, todoUpdateTextDone.Done, todoUpdateTextDone.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudited(mock, entityTodo, todoUpdateTextDone.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement1).WithArgs(todoUpdateTextDone.Text, todoUpdateTextDone.Done, todoUpdateTextDone.ID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
 
 	statement2 := "UPDATE todos SET text = ?, done = ?, completed_at = null where id = ?"
-	mock.ExpectExec(statement2).WithArgs(todoUpdateTextNotDone.Text, todoUpdateTextNotDone.Done, todoUpdateTextNotDone.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudited(mock, entityTodo, todoUpdateTextNotDone.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement2).WithArgs(todoUpdateTextNotDone.Text, todoUpdateTextNotDone.Done, todoUpdateTextNotDone.ID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
 
 	statement3 := "UPDATE todos SET done = ?, completed_at = curdate() where id = ?"
-	mock.ExpectExec(statement3).WithArgs(todoUpdateDone.Done, todoUpdateDone.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudited(mock, entityTodo, todoUpdateDone.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement3).WithArgs(todoUpdateDone.Done, todoUpdateDone.ID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
 
 	statement4 := "UPDATE todos SET done = ?, completed_at = null where id = ?"
-	mock.ExpectExec(statement4).WithArgs(todoUpdateNotDone.Done, todoUpdateNotDone.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudited(mock, entityTodo, todoUpdateNotDone.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement4).WithArgs(todoUpdateNotDone.Done, todoUpdateNotDone.ID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
 
 	tests := []struct {
 		name    string
@@ -313,3 +325,18 @@ func TestUpdateTodo(t *testing.T) {
 		})
 	}
 }
+
+func TestWithContextCancelsQueries(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+	defer mysqlRepo.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mysqlRepo.WithContext(ctx).TodoByID(todo.ID); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("TodoByID() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,278 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// An operation is the group of writes made by one mutation, whose history
+// entries share an operation id. Undoing it brings every todo it changed back
+// to the state before it, and redoing it to the state after. Both are recorded
+// as entries of the same operation, so its last entry tells whether it is
+// undone.
+
+// todoFieldColumns maps the fields of a todo snapshot to the columns undo and
+// redo restore. Tags are restored by restoreTags, and collaborators and
+// attachments are not restored.
+var todoFieldColumns = map[string]string{
+	"text":       "text",
+	"priority":   "priority",
+	"listId":     "list_id",
+	"parentId":   "parent_id",
+	"dueAt":      "due_at",
+	"recurrence": "recurrence",
+	"position":   "position",
+}
+
+// effect is what an operation did to one todo.
+type effect struct {
+	id string
+	// since is the first history entry of the operation on the todo.
+	since         int64
+	existedBefore bool
+	existsAfter   bool
+	before        map[string]*string
+	after         map[string]*string
+}
+
+func (r *mysqlRepository) OperationHistory(operationId string) ([]repo.HistoryRow, error) {
+	return r.history(r.db, "OperationHistory",
+		"SELECT "+historyColumns+" FROM history WHERE operation_id = ? ORDER BY id",
+		operationId, operationId)
+}
+
+func (r *mysqlRepository) UndoOperation(operationId string) (bool, error) {
+	return r.replay("UndoOperation", repo.ActionUndo, operationId)
+}
+
+func (r *mysqlRepository) RedoOperation(operationId string) (bool, error) {
+	return r.replay("RedoOperation", repo.ActionRedo, operationId)
+}
+
+// replay undoes or redoes an operation, depending on action, in a single
+// transaction.
+func (r *mysqlRepository) replay(op string, action string, operationId string) (bool, error) {
+	tx, err := r.db.BeginTx(r.context(), nil)
+	if err != nil {
+		return false, fmt.Errorf("%s begin : %v", op, err)
+	}
+	defer tx.Rollback()
+
+	entries, err := r.history(tx, op,
+		"SELECT "+historyColumns+" FROM history WHERE operation_id = ? ORDER BY id FOR UPDATE",
+		operationId, operationId)
+	if err != nil {
+		return false, err
+	}
+	if len(entries) == 0 {
+		return false, nil
+	}
+	undone := entries[len(entries)-1].Action == repo.ActionUndo
+	if action == repo.ActionUndo && undone {
+		return false, fmt.Errorf("%s operation %q is undone already", op, operationId)
+	}
+	if action == repo.ActionRedo && !undone {
+		return false, fmt.Errorf("%s operation %q is not undone", op, operationId)
+	}
+	effects, err := operationEffects(entries)
+	if err != nil {
+		return false, fmt.Errorf("%s operation %q: %v", op, operationId, err)
+	}
+	if action == repo.ActionUndo {
+		for i, j := 0, len(effects)-1; i < j; i, j = i+1, j-1 {
+			effects[i], effects[j] = effects[j], effects[i]
+		}
+	}
+
+	// all todos are locked before looking for later changes, so that none can
+	// be changed in between
+	current := make([]*snapshot, len(effects))
+	for i, e := range effects {
+		if current[i], err = todoSnapshot(tx, e.id); err != nil {
+			return false, fmt.Errorf("%s snapshot before %q: %v", op, e.id, err)
+		}
+	}
+	for _, e := range effects {
+		var later int
+		row := tx.QueryRow("SELECT COUNT(*) FROM history WHERE entity_type = ? AND entity_id = ? AND id > ? "+
+			"AND (operation_id IS NULL OR operation_id <> ?)", entityTodo, e.id, e.since, operationId)
+		if err := row.Scan(&later); err != nil {
+			return false, fmt.Errorf("%s row scan: %q %v", op, e.id, err)
+		}
+		if later > 0 {
+			return false, fmt.Errorf("%s todo %q was changed after operation %q", op, e.id, operationId)
+		}
+	}
+
+	replayer := &mysqlRepository{db: r.db, actor: r.actor, operation: operationId}
+	for i, e := range effects {
+		exists, fields := e.existsAfter, e.after
+		if action == repo.ActionUndo {
+			exists, fields = e.existedBefore, e.before
+		}
+		if err := restoreTodo(tx, e.id, current[i], exists, fields); err != nil {
+			return false, fmt.Errorf("%s todo %q: %v", op, e.id, err)
+		}
+		after, err := takeSnapshot(tx, entityTodo, e.id)
+		if err != nil {
+			return false, fmt.Errorf("%s snapshot after %q: %v", op, e.id, err)
+		}
+		before := current[i]
+		if before.deleted {
+			before = nil
+		}
+		if err := replayer.record(tx, entityTodo, e.id, action, before, after); err != nil {
+			return false, fmt.Errorf("%s record history : %v", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s commit : %v", op, err)
+	}
+	return true, nil
+}
+
+// operationEffects sums up the entries of an operation, leaving out undos and
+// redos, per todo in the order the todos were first changed.
+func operationEffects(entries []repo.HistoryRow) ([]*effect, error) {
+	var effects []*effect
+	byID := map[string]*effect{}
+	for _, entry := range entries {
+		if entry.Action == repo.ActionUndo || entry.Action == repo.ActionRedo {
+			continue
+		}
+		// tags created to tag todos are kept, for the redo and for the todos
+		// tagged with them since
+		if entry.EntityType == entityTag && entry.Action == repo.ActionCreate {
+			continue
+		}
+		if entry.EntityType != entityTodo {
+			return nil, fmt.Errorf("cannot replay changes to %s %q", entry.EntityType, entry.EntityID)
+		}
+		e, ok := byID[entry.EntityID]
+		if !ok {
+			e = &effect{
+				id:            entry.EntityID,
+				since:         entry.ID,
+				existedBefore: entry.Action != repo.ActionCreate,
+				before:        map[string]*string{},
+				after:         map[string]*string{},
+			}
+			byID[entry.EntityID] = e
+			effects = append(effects, e)
+		}
+		e.existsAfter = entry.Action != repo.ActionDelete
+		for _, change := range entry.Changes {
+			if _, seen := e.before[change.Field]; !seen {
+				e.before[change.Field] = change.Before
+			}
+			e.after[change.Field] = change.After
+		}
+	}
+	return effects, nil
+}
+
+// restoreTodo brings a todo from its current state to the given one: deleted
+// when exists is false, and otherwise live with the given field values. A
+// restored completion is dated today.
+func restoreTodo(tx *sql.Tx, id string, current *snapshot, exists bool, fields map[string]*string) error {
+	if !exists {
+		if current.deleted {
+			return nil
+		}
+		_, err := tx.Exec("UPDATE todos SET deleted_at = now() WHERE id = ?", id)
+		return err
+	}
+
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	sets := []string{"deleted_at = NULL"}
+	var args []interface{}
+	for _, field := range names {
+		value := fields[field]
+		if field == "done" {
+			if value != nil && *value == "true" {
+				sets = append(sets, "done = TRUE", "completed_at = curdate()")
+			} else {
+				sets = append(sets, "done = FALSE", "completed_at = NULL")
+			}
+			continue
+		}
+		if field == "tags" {
+			if err := restoreTags(tx, id, current, value); err != nil {
+				return err
+			}
+			continue
+		}
+		column, ok := todoFieldColumns[field]
+		if !ok {
+			if value == nil && current.fields[field] != "" || value != nil && current.fields[field] != *value {
+				return fmt.Errorf("cannot restore the %s of the todo", field)
+			}
+			continue
+		}
+		sets = append(sets, column+" = ?")
+		if value == nil {
+			args = append(args, nil)
+		} else {
+			args = append(args, *value)
+		}
+	}
+	args = append(args, id)
+	_, err := tx.Exec("UPDATE todos SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+// restoreTags tags a todo with exactly the given tags of its owner, as listed
+// by its snapshots. Tags that no longer exist cannot be restored.
+func restoreTags(tx *sql.Tx, id string, current *snapshot, tags *string) error {
+	want := map[string]bool{}
+	if tags != nil {
+		for _, name := range strings.Split(*tags, ", ") {
+			want[name] = true
+		}
+	}
+	var untag, tag []string
+	if current.fields["tags"] != "" {
+		for _, name := range strings.Split(current.fields["tags"], ", ") {
+			if !want[name] {
+				untag = append(untag, name)
+			}
+			delete(want, name)
+		}
+	}
+	for name := range want {
+		tag = append(tag, name)
+	}
+	sort.Strings(tag)
+
+	if len(untag) > 0 {
+		if _, err := tx.Exec("DELETE tt FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.todo_id = ? AND g.name IN ("+placeholders(len(untag))+")",
+			append([]interface{}{id}, stringArgs(untag)...)...); err != nil {
+			return err
+		}
+	}
+	if len(tag) > 0 {
+		result, err := tx.Exec("INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, id FROM tags WHERE user_id = ? AND name IN ("+placeholders(len(tag))+")",
+			append([]interface{}{id, current.owner}, stringArgs(tag)...)...)
+		if err != nil {
+			return err
+		}
+		tagged, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if tagged != int64(len(tag)) {
+			return fmt.Errorf("cannot restore the tags %s of the todo, some were deleted", strings.Join(tag, ", "))
+		}
+	}
+	return nil
+}
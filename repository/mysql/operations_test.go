@@ -0,0 +1,229 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const operationID = "caajol287d5nseroper"
+
+const laterChangesQuery = "SELECT COUNT(*) FROM history WHERE entity_type = ? AND entity_id = ? AND id > ? " +
+	"AND (operation_id IS NULL OR operation_id <> ?)"
+
+// expectOperation expects the entries of operationID to be read for a replay,
+// ending with an entry of the given action.
+func expectOperation(mock sqlmock.Sqlmock, last string) {
+	changedAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(historyColumnNames).
+		AddRow(5, entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, repo.ActionUpdate,
+			[]byte(`{"done":{"before":"false","after":"true"},"text":{"before":"Water roses and lilies","after":"Water the roses"}}`), changedAt)
+	if last != repo.ActionUpdate {
+		rows.AddRow(6, entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, last,
+			[]byte(`{"done":{"before":"true","after":"false"},"text":{"before":"Water the roses","after":"Water roses and lilies"}}`), changedAt)
+	}
+	mock.ExpectQuery(historySelect + "WHERE operation_id = ? ORDER BY id FOR UPDATE").WithArgs(operationID).WillReturnRows(rows)
+}
+
+func TestDeleteTodo(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	subtree := "WITH RECURSIVE subtree AS (" +
+		"SELECT id, 0 AS depth FROM todos WHERE id = ? " +
+		"UNION ALL SELECT t.id, s.depth + 1 FROM todos t JOIN subtree s ON t.parent_id = s.id) "
+	mock.ExpectBegin()
+	mock.ExpectQuery(subtree + "SELECT t.id FROM todos t JOIN subtree s ON s.id = t.id WHERE t.deleted_at IS NULL ORDER BY s.depth, t.id").
+		WithArgs(todo.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(todo.ID).AddRow(todoBySameUser.ID))
+	expectSnapshot(mock, entityTodo, todo.ID)
+	expectSnapshot(mock, entityTodo, todoBySameUser.ID)
+	mock.ExpectExec(subtree + "UPDATE todos t JOIN subtree s ON s.id = t.id SET t.deleted_at = now() WHERE t.deleted_at IS NULL").
+		WithArgs(todo.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, id := range []string{todo.ID, todoBySameUser.ID} {
+		mock.ExpectExec(historyInsert).WithArgs(entityTodo, id, todo.UserID, nil, nil, repo.ActionDelete, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectEvent(mock, entityTodo, id, repo.ActionDelete)
+	}
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.DeleteTodo(todo.ID)
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.DeleteTodo() = %v, %v, want true, nil", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUndoOperation(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := (&mysqlRepository{db: db}).WithActor(todo.UserID)
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectBegin()
+	expectOperation(mock, repo.ActionUpdate)
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, "Water the roses", true, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", nil, nil, nil, nil))
+	mock.ExpectQuery(laterChangesQuery).WithArgs(entityTodo, todo.ID, 5, operationID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("UPDATE todos SET deleted_at = NULL, done = FALSE, completed_at = NULL, text = ? WHERE id = ?").
+		WithArgs("Water roses and lilies", todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", nil, nil, nil, nil))
+	mock.ExpectExec(historyInsert).
+		WithArgs(entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, repo.ActionUndo,
+			`{"done":{"before":"true","after":"false"},"text":{"before":"Water the roses","after":"Water roses and lilies"}}`).
+		WillReturnResult(sqlmock.NewResult(6, 1))
+	expectEvent(mock, entityTodo, todo.ID, repo.ActionUndo)
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.UndoOperation(operationID)
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.UndoOperation() = %v, %v, want true, nil", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// expectRetag expects the entries of operationID to be read for a replay: a
+// bulk tagging that created the garden tag and tagged the todo with it,
+// ending with an entry of the given action.
+func expectRetag(mock sqlmock.Sqlmock, last string) {
+	changedAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(historyColumnNames).
+		AddRow(4, entityTag, "caajol287d5nstag0001", todo.UserID, todo.UserID, operationID, repo.ActionCreate,
+			[]byte(`{"name":{"after":"garden"}}`), changedAt).
+		AddRow(5, entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, repo.ActionUpdate,
+			[]byte(`{"tags":{"before":"errands","after":"errands, garden"}}`), changedAt)
+	if last != repo.ActionUpdate {
+		rows.AddRow(6, entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, last,
+			[]byte(`{"tags":{"before":"errands, garden","after":"errands"}}`), changedAt)
+	}
+	mock.ExpectQuery(historySelect + "WHERE operation_id = ? ORDER BY id FOR UPDATE").WithArgs(operationID).WillReturnRows(rows)
+}
+
+func TestUndoRetag(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := (&mysqlRepository{db: db}).WithActor(todo.UserID)
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectBegin()
+	expectRetag(mock, repo.ActionUpdate)
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", "errands, garden", nil, nil, nil))
+	mock.ExpectQuery(laterChangesQuery).WithArgs(entityTodo, todo.ID, 5, operationID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("DELETE tt FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.todo_id = ? AND g.name IN (?)").
+		WithArgs(todo.ID, "garden").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE todos SET deleted_at = NULL WHERE id = ?").
+		WithArgs(todo.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", "errands", nil, nil, nil))
+	mock.ExpectExec(historyInsert).
+		WithArgs(entityTodo, todo.ID, todo.UserID, todo.UserID, operationID, repo.ActionUndo,
+			`{"tags":{"before":"errands, garden","after":"errands"}}`).
+		WillReturnResult(sqlmock.NewResult(6, 1))
+	expectEvent(mock, entityTodo, todo.ID, repo.ActionUndo)
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.UndoOperation(operationID)
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.UndoOperation() = %v, %v, want true, nil", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRedoRetagOfDeletedTag(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := (&mysqlRepository{db: db}).WithActor(todo.UserID)
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectBegin()
+	expectRetag(mock, repo.ActionUndo)
+	mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+			AddRow(todo.ID, todo.Text, false, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", "errands", nil, nil, nil))
+	mock.ExpectQuery(laterChangesQuery).WithArgs(entityTodo, todo.ID, 5, operationID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	// the garden tag was deleted since
+	mock.ExpectExec("INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, id FROM tags WHERE user_id = ? AND name IN (?)").
+		WithArgs(todo.ID, todo.UserID, "garden").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	got, err := mysqlRepo.RedoOperation(operationID)
+	if err == nil || !strings.Contains(err.Error(), "cannot restore the tags garden") {
+		t.Errorf("mysqlRepository.RedoOperation() = %v, %v, want an error restoring the garden tag", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestReplayRefused(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	// the todo was changed by another write after the operation
+	mock.ExpectBegin()
+	expectOperation(mock, repo.ActionUndo)
+	expectSnapshot(mock, entityTodo, todo.ID)
+	mock.ExpectQuery(laterChangesQuery).WithArgs(entityTodo, todo.ID, 5, operationID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+	// an operation cannot be undone twice
+	mock.ExpectBegin()
+	expectOperation(mock, repo.ActionUndo)
+	mock.ExpectRollback()
+	// nor redone before it is undone
+	mock.ExpectBegin()
+	expectOperation(mock, repo.ActionRedo)
+	mock.ExpectRollback()
+
+	tests := []struct {
+		name    string
+		replay  func(operationId string) (bool, error)
+		wantErr string
+	}{
+		{"test redo after a later change should fail", mysqlRepo.RedoOperation, "was changed after operation"},
+		{"test undo of an undone operation should fail", mysqlRepo.UndoOperation, "is undone already"},
+		{"test redo of an operation that is not undone should fail", mysqlRepo.RedoOperation, "is not undone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.replay(operationID)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("replay() = %v, %v, want error containing %q", got, err, tt.wantErr)
+			}
+		})
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
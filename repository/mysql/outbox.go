@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// event is the payload of an outbox event: a history entry as other
+// services see it.
+type event struct {
+	EntityType  string                 `json:"entityType"`
+	EntityID    string                 `json:"entityId"`
+	OwnerID     string                 `json:"ownerId"`
+	ActorID     string                 `json:"actorId,omitempty"`
+	OperationID string                 `json:"operationId,omitempty"`
+	Action      string                 `json:"action"`
+	Changes     map[string]fieldChange `json:"changes"`
+}
+
+type mysqlOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository returns the outbox of the database r is connected to.
+// r must have been created by NewRepository.
+func NewOutboxRepository(r repo.Repository) (repo.OutboxRepository, error) {
+	mr, ok := r.(*mysqlRepository)
+	if !ok {
+		return nil, fmt.Errorf("NewOutboxRepository %T is not a MySQL repository", r)
+	}
+	return &mysqlOutboxRepository{mr.db}, nil
+}
+
+// outboxGapTimeout is how long UnsentEvents waits for a missing event id to
+// be committed before taking it for the id of a rolled back transaction.
+const outboxGapTimeout = 30 * time.Second
+
+// UnsentEvents returns the unsent events in id order, up to the first gap in
+// the ids. Ids are taken when events are written, not when they commit, so a
+// missing id may belong to a transaction still in progress whose event must
+// go before the ones after it. The gap is skipped once the event after it is
+// older than outboxGapTimeout, as such ids are left by rollbacks.
+func (r *mysqlOutboxRepository) UnsentEvents(limit int) ([]repo.EventRow, error) {
+	var events []repo.EventRow
+
+	var lastSent int64
+	err := r.db.QueryRow("SELECT id FROM outbox WHERE sent_at IS NOT NULL ORDER BY id DESC LIMIT 1").Scan(&lastSent)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("UnsentEvents last sent row scan : %v", err)
+	}
+
+	rows, err := r.db.Query("SELECT id, event_type, entity_type, entity_id, payload, created_at, created_at < now(6) - INTERVAL ? SECOND FROM outbox "+
+		"WHERE sent_at IS NULL ORDER BY id LIMIT ?", int(outboxGapTimeout/time.Second), limit)
+	if err != nil {
+		return nil, fmt.Errorf("UnsentEvents query : %v", err)
+	}
+
+	defer rows.Close()
+
+	// next is the id expected next, unknown until an event was sent
+	var next int64
+	if lastSent > 0 {
+		next = lastSent + 1
+	}
+	for rows.Next() {
+		var event repo.EventRow
+		var settled bool
+		if err := rows.Scan(&event.ID, &event.Type, &event.EntityType, &event.EntityID, &event.Payload, &event.CreatedAt, &settled); err != nil {
+			return nil, fmt.Errorf("UnsentEvents scan row : %v", err)
+		}
+		if next != 0 && event.ID != next && !settled {
+			break
+		}
+		events = append(events, event)
+		next = event.ID + 1
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UnsentEvents rows err : %v", err)
+	}
+
+	return events, nil
+}
+
+func (r *mysqlOutboxRepository) MarkEventsSent(ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	result, err := r.db.Exec("UPDATE outbox SET sent_at = now(6) WHERE sent_at IS NULL AND id IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return 0, fmt.Errorf("MarkEventsSent exec : %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("MarkEventsSent fetch row after update : %v", err)
+	}
+	return updated, nil
+}
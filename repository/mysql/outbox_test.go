@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const unsentEventsQuery = "SELECT id, event_type, entity_type, entity_id, payload, created_at, created_at < now(6) - INTERVAL ? SECOND FROM outbox WHERE sent_at IS NULL ORDER BY id LIMIT ?"
+
+var unsentEventsColumns = []string{"id", "event_type", "entity_type", "entity_id", "payload", "created_at", "settled"}
+
+func TestOutbox(t *testing.T) {
+	db, mock := NewMock()
+	outbox := &mysqlOutboxRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	createdAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	payload := []byte(`{"entityType":"todo","entityId":"` + todo.ID + `","ownerId":"` + todo.UserID + `","action":"complete","changes":{}}`)
+	mock.ExpectQuery("SELECT id FROM outbox WHERE sent_at IS NOT NULL ORDER BY id DESC LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectQuery(unsentEventsQuery).
+		WithArgs(30, 10).
+		WillReturnRows(sqlmock.NewRows(unsentEventsColumns).
+			AddRow(7, "todo.complete", entityTodo, todo.ID, payload, createdAt, false))
+	mock.ExpectExec("UPDATE outbox SET sent_at = now(6) WHERE sent_at IS NULL AND id IN (?)").
+		WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	got, err := outbox.UnsentEvents(10)
+	if err != nil {
+		t.Fatalf("mysqlOutboxRepository.UnsentEvents() error = %v", err)
+	}
+	want := []repo.EventRow{{ID: 7, Type: "todo.complete", EntityType: entityTodo, EntityID: todo.ID, Payload: payload, CreatedAt: createdAt}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlOutboxRepository.UnsentEvents() = %+v, want %+v", got, want)
+	}
+	if sent, err := outbox.MarkEventsSent([]int64{7}); err != nil || sent != 1 {
+		t.Errorf("mysqlOutboxRepository.MarkEventsSent() = %v, %v, want 1, nil", sent, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUnsentEventsStopAtGaps(t *testing.T) {
+	createdAt := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	payload := []byte(`{}`)
+	tests := []struct {
+		name     string
+		lastSent []int64
+		ids      []int64
+		settled  []bool
+		want     []int64
+	}{
+		{"test events after the last sent one should be returned", []int64{6}, []int64{7, 8}, []bool{false, false}, []int64{7, 8}},
+		{"test events after a recent gap should wait", []int64{6}, []int64{7, 9, 10}, []bool{false, false, false}, []int64{7}},
+		{"test events after a recent gap before the first should wait", []int64{6}, []int64{8}, []bool{false}, nil},
+		{"test events after an old gap should be returned", []int64{6}, []int64{8, 9}, []bool{true, true}, []int64{8, 9}},
+		{"test first events of an outbox should be returned", nil, []int64{3, 4}, []bool{false, false}, []int64{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			defer db.Close()
+			outbox := &mysqlOutboxRepository{db: db}
+
+			lastSent := sqlmock.NewRows([]string{"id"})
+			for _, id := range tt.lastSent {
+				lastSent.AddRow(id)
+			}
+			mock.ExpectQuery("SELECT id FROM outbox WHERE sent_at IS NOT NULL ORDER BY id DESC LIMIT 1").WillReturnRows(lastSent)
+			rows := sqlmock.NewRows(unsentEventsColumns)
+			for i, id := range tt.ids {
+				rows.AddRow(id, "todo.complete", entityTodo, todo.ID, payload, createdAt, tt.settled[i])
+			}
+			mock.ExpectQuery(unsentEventsQuery).WithArgs(30, 10).WillReturnRows(rows)
+
+			events, err := outbox.UnsentEvents(10)
+			if err != nil {
+				t.Fatalf("mysqlOutboxRepository.UnsentEvents() error = %v", err)
+			}
+			var got []int64
+			for _, event := range events {
+				got = append(got, event.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mysqlOutboxRepository.UnsentEvents() ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// LastTodoPosition returns the greatest position among the user's todos, or
+// an empty string when the user has none.
+func (r *mysqlRepository) LastTodoPosition(userId string) (string, error) {
+	return r.todoPosition("LastTodoPosition",
+		"SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position IS NOT NULL ORDER BY position DESC LIMIT 1", userId)
+}
+
+// TodoPositionBefore returns the greatest of the user's positions below the
+// given one, or an empty string when there is none.
+func (r *mysqlRepository) TodoPositionBefore(userId string, position string) (string, error) {
+	return r.todoPosition("TodoPositionBefore",
+		"SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position < ? ORDER BY position DESC LIMIT 1", userId, position)
+}
+
+// TodoPositionAfter returns the least of the user's positions above the given
+// one, or an empty string when there is none.
+func (r *mysqlRepository) TodoPositionAfter(userId string, position string) (string, error) {
+	return r.todoPosition("TodoPositionAfter",
+		"SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position > ? ORDER BY position LIMIT 1", userId, position)
+}
+
+func (r *mysqlRepository) todoPosition(op string, query string, args ...interface{}) (string, error) {
+	var position string
+	row := r.db.QueryRowContext(r.context(), query, args...)
+	if err := row.Scan(&position); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s row scan: %v", op, err)
+	}
+	return position, nil
+}
+
+func (r *mysqlRepository) SetTodoPosition(todoId string, position string) (bool, error) {
+	return r.auditedExec("SetTodoPosition", entityTodo, repo.ActionMove, todoId,
+		"UPDATE todos SET position = ? WHERE id = ?", nullString(position), todoId)
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func TestTodoPositions(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectQuery("SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position IS NOT NULL ORDER BY position DESC LIMIT 1").
+		WithArgs(todo.UserID).WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("k"))
+	mock.ExpectQuery("SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position IS NOT NULL ORDER BY position DESC LIMIT 1").
+		WithArgs(todoByDifferentUser.UserID).WillReturnRows(sqlmock.NewRows([]string{"position"}))
+	mock.ExpectQuery("SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position < ? ORDER BY position DESC LIMIT 1").
+		WithArgs(todo.UserID, "k").WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("V"))
+	mock.ExpectQuery("SELECT position FROM todos WHERE user_id = ? AND deleted_at IS NULL AND position > ? ORDER BY position LIMIT 1").
+		WithArgs(todo.UserID, "k").WillReturnRows(sqlmock.NewRows([]string{"position"}))
+
+	tests := []struct {
+		name  string
+		query func() (string, error)
+		want  string
+	}{
+		{"test last position", func() (string, error) { return mysqlRepo.LastTodoPosition(todo.UserID) }, "k"},
+		{"test last position without todos should be empty",
+			func() (string, error) { return mysqlRepo.LastTodoPosition(todoByDifferentUser.UserID) }, ""},
+		{"test position before", func() (string, error) { return mysqlRepo.TodoPositionBefore(todo.UserID, "k") }, "V"},
+		{"test position after the last todo should be empty",
+			func() (string, error) { return mysqlRepo.TodoPositionAfter(todo.UserID, "k") }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query()
+			if err != nil {
+				t.Errorf("position query error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("position query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTodoPosition(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionMove, func() {
+		mock.ExpectExec("UPDATE todos SET position = ? WHERE id = ?").WithArgs("kV", todo.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	got, err := mysqlRepo.SetTodoPosition(todo.ID, "kV")
+	if err != nil || !got {
+		t.Errorf("mysqlRepository.SetTodoPosition() = %v, %v, want true, nil", got, err)
+	}
+}
@@ -0,0 +1,159 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+type mysqlReminderRepository struct {
+	db *sql.DB
+}
+
+// NewReminderRepository returns the reminder repository of the database r is
+// connected to. r must have been created by NewRepository.
+func NewReminderRepository(r repo.Repository) (repo.ReminderRepository, error) {
+	mr, ok := r.(*mysqlRepository)
+	if !ok {
+		return nil, fmt.Errorf("NewReminderRepository %T is not a MySQL repository", r)
+	}
+	return &mysqlReminderRepository{mr.db}, nil
+}
+
+const reminderColumns = "r.id, r.todo_id, r.user_id, r.fire_at, r.channel, r.address, r.status, r.attempts, r.next_attempt_at, " +
+	"r.last_error, r.created_at, r.sent_at, t.text, t.due_at, t.done = FALSE AND t.deleted_at IS NULL"
+
+func scanReminder(s scanner, reminder *repo.ReminderRow) error {
+	var address, lastError sql.NullString
+	var nextAttemptAt, sentAt, dueAt sql.NullTime
+	if err := s.Scan(&reminder.ID, &reminder.TodoID, &reminder.UserID, &reminder.FireAt, &reminder.Channel, &address, &reminder.Status,
+		&reminder.Attempts, &nextAttemptAt, &lastError, &reminder.CreatedAt, &sentAt,
+		&reminder.TodoText, &dueAt, &reminder.TodoOpen); err != nil {
+		return err
+	}
+	reminder.Address = address.String
+	reminder.NextAttemptAt = nextAttemptAt.Time
+	reminder.LastError = lastError.String
+	reminder.SentAt = sentAt.Time
+	reminder.TodoDueAt = dueAt.Time
+	return nil
+}
+
+func (r *mysqlReminderRepository) ReminderByID(id string) (repo.ReminderRow, error) {
+	var reminder repo.ReminderRow
+	row := r.db.QueryRow("SELECT "+reminderColumns+" FROM reminders r JOIN todos t ON t.id = r.todo_id WHERE r.id = ?", id)
+	if err := scanReminder(row, &reminder); err != nil {
+		if err == sql.ErrNoRows {
+			return reminder, fmt.Errorf("ReminderByID row scan: no row. %q %v", id, err)
+		}
+		return reminder, fmt.Errorf("ReminderByID row scan: %q %v", id, err)
+	}
+	return reminder, nil
+}
+
+func (r *mysqlReminderRepository) RemindersByUser(userId string) ([]repo.ReminderRow, error) {
+	return r.reminders(r.db, "RemindersByUser", "WHERE r.user_id = ? ORDER BY r.fire_at, r.id", userId)
+}
+
+func (r *mysqlReminderRepository) reminders(q querier, op string, where string, args ...interface{}) ([]repo.ReminderRow, error) {
+	var reminders []repo.ReminderRow
+
+	rows, err := q.Query("SELECT "+reminderColumns+" FROM reminders r JOIN todos t ON t.id = r.todo_id "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s query : %v", op, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reminder repo.ReminderRow
+		if err := scanReminder(rows, &reminder); err != nil {
+			return nil, fmt.Errorf("%s scan row : %v", op, err)
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err : %v", op, err)
+	}
+
+	return reminders, nil
+}
+
+func (r *mysqlReminderRepository) AddReminder(row repo.ReminderRow) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO reminders(id, todo_id, user_id, fire_at, channel, address, status, next_attempt_at, created_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, now())",
+		row.ID, row.TodoID, row.UserID, row.FireAt, row.Channel, nullString(row.Address), repo.ReminderPending, row.FireAt)
+	if err != nil {
+		return false, fmt.Errorf("AddReminder exec : %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("AddReminder fetch row after insertion : %v", err)
+	}
+	return inserted > 0, nil
+}
+
+func (r *mysqlReminderRepository) DeleteReminder(id string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM reminders WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteReminder exec : %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("DeleteReminder fetch row after delete : %v", err)
+	}
+	return deleted > 0, nil
+}
+
+// ClaimDueReminders locks the due reminders with SKIP LOCKED, so that
+// schedulers claiming at the same time get different reminders, and moves
+// their next attempt to the end of the lease before committing.
+func (r *mysqlReminderRepository) ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]repo.ReminderRow, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ClaimDueReminders begin : %v", err)
+	}
+	defer tx.Rollback()
+
+	due, err := r.reminders(tx, "ClaimDueReminders", "WHERE r.status = ? AND r.next_attempt_at <= ? "+
+		"ORDER BY r.next_attempt_at, r.id LIMIT ? FOR UPDATE OF r SKIP LOCKED", repo.ReminderPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	claimedUntil := now.Add(lease)
+	args := make([]interface{}, 0, len(due)+1)
+	args = append(args, claimedUntil)
+	for i := range due {
+		due[i].NextAttemptAt = claimedUntil
+		args = append(args, due[i].ID)
+	}
+	if _, err := tx.Exec("UPDATE reminders SET next_attempt_at = ? WHERE id IN ("+placeholders(len(due))+")", args...); err != nil {
+		return nil, fmt.Errorf("ClaimDueReminders exec : %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ClaimDueReminders commit : %v", err)
+	}
+	return due, nil
+}
+
+func (r *mysqlReminderRepository) RecordReminderAttempt(row repo.ReminderRow) (bool, error) {
+	result, err := r.db.Exec("UPDATE reminders SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, sent_at = ? WHERE id = ?",
+		row.Status, row.Attempts, nullTime(row.NextAttemptAt), nullString(row.LastError), nullTime(row.SentAt), row.ID)
+	if err != nil {
+		return false, fmt.Errorf("RecordReminderAttempt exec : %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("RecordReminderAttempt fetch row after update : %v", err)
+	}
+	return updated > 0, nil
+}
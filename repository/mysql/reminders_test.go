@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const reminderSelect = "SELECT r.id, r.todo_id, r.user_id, r.fire_at, r.channel, r.address, r.status, r.attempts, r.next_attempt_at, " +
+	"r.last_error, r.created_at, r.sent_at, t.text, t.due_at, t.done = FALSE AND t.deleted_at IS NULL " +
+	"FROM reminders r JOIN todos t ON t.id = r.todo_id "
+
+var reminderColumnNames = []string{"id", "todo_id", "user_id", "fire_at", "channel", "address", "status", "attempts", "next_attempt_at",
+	"last_error", "created_at", "sent_at", "text", "due_at", "open"}
+
+func TestClaimDueReminders(t *testing.T) {
+	db, mock := NewMock()
+	reminders := &mysqlReminderRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(reminderSelect+"WHERE r.status = ? AND r.next_attempt_at <= ? "+
+		"ORDER BY r.next_attempt_at, r.id LIMIT ? FOR UPDATE OF r SKIP LOCKED").
+		WithArgs(repo.ReminderPending, now, 10).
+		WillReturnRows(sqlmock.NewRows(reminderColumnNames).
+			AddRow("caajol287d5nsremd001", todo.ID, todo.UserID, now, "email", "gopher@example.com", "pending", 0, now,
+				nil, now, nil, todo.Text, now.Add(time.Hour), true))
+	mock.ExpectExec("UPDATE reminders SET next_attempt_at = ? WHERE id IN (?)").
+		WithArgs(now.Add(5*time.Minute), "caajol287d5nsremd001").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	got, err := reminders.ClaimDueReminders(now, 5*time.Minute, 10)
+	if err != nil {
+		t.Fatalf("mysqlReminderRepository.ClaimDueReminders() error = %v", err)
+	}
+	want := []repo.ReminderRow{{ID: "caajol287d5nsremd001", TodoID: todo.ID, UserID: todo.UserID, FireAt: now, Channel: repo.ChannelEmail,
+		Address: "gopher@example.com", Status: repo.ReminderPending, NextAttemptAt: now.Add(5 * time.Minute), CreatedAt: now,
+		TodoText: todo.Text, TodoDueAt: now.Add(time.Hour), TodoOpen: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlReminderRepository.ClaimDueReminders() = %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestClaimDueRemindersNoneDue(t *testing.T) {
+	db, mock := NewMock()
+	reminders := &mysqlReminderRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(reminderSelect+"WHERE r.status = ? AND r.next_attempt_at <= ? "+
+		"ORDER BY r.next_attempt_at, r.id LIMIT ? FOR UPDATE OF r SKIP LOCKED").
+		WithArgs(repo.ReminderPending, now, 10).
+		WillReturnRows(sqlmock.NewRows(reminderColumnNames))
+	mock.ExpectRollback()
+
+	if got, err := reminders.ClaimDueReminders(now, 5*time.Minute, 10); err != nil || len(got) != 0 {
+		t.Errorf("mysqlReminderRepository.ClaimDueReminders() = %v, %v, want none", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRecordReminderAttempt(t *testing.T) {
+	db, mock := NewMock()
+	reminders := &mysqlReminderRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE reminders SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, sent_at = ? WHERE id = ?").
+		WithArgs(repo.ReminderSent, 1, nil, nil, now, "caajol287d5nsremd001").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	updated, err := reminders.RecordReminderAttempt(repo.ReminderRow{ID: "caajol287d5nsremd001", Status: repo.ReminderSent, Attempts: 1, SentAt: now})
+	if err != nil || !updated {
+		t.Errorf("mysqlReminderRepository.RecordReminderAttempt() = %v, %v, want true, nil", updated, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
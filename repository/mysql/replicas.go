@@ -0,0 +1,152 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog/log"
+)
+
+// healthCheckInterval is how often replicas are pinged.
+const healthCheckInterval = 5 * time.Second
+
+// healthCheckTimeout is how long a replica has to answer a ping.
+const healthCheckTimeout = 2 * time.Second
+
+// replica is a read-only copy of the primary database.
+type replica struct {
+	db *sql.DB
+	// name tells the replica apart in logs, without its credentials.
+	name    string
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// setHealthy records the outcome of a health check, logging changes.
+func (r *replica) setHealthy(healthy bool, err error) {
+	value := int32(0)
+	if healthy {
+		value = 1
+	}
+	if atomic.SwapInt32(&r.healthy, value) == value {
+		return
+	}
+	if healthy {
+		log.Info().Str("replica", r.name).Msg("replica is healthy again")
+	} else {
+		log.Warn().Err(err).Str("replica", r.name).Msg("replica failed its health check, reading from the others")
+	}
+}
+
+// replicaSet spreads reads over its healthy replicas in turn. Replicas
+// failing their health check are skipped until they pass it again.
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newReplicaSet(replicas []*replica) *replicaSet {
+	return &replicaSet{replicas: replicas, stop: make(chan struct{})}
+}
+
+// pick returns the next healthy replica, or nil when none is healthy.
+func (s *replicaSet) pick() *replica {
+	n := uint32(len(s.replicas))
+	for i := uint32(0); i < n; i++ {
+		r := s.replicas[(atomic.AddUint32(&s.next, 1)-1)%n]
+		if r.isHealthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+// check pings every replica and updates its health.
+func (s *replicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			err := r.db.PingContext(pingCtx)
+			r.setHealthy(err == nil, err)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// monitor checks the replicas every interval until close.
+func (s *replicaSet) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.check(context.Background())
+		}
+	}
+}
+
+func (s *replicaSet) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		for _, r := range s.replicas {
+			r.db.Close()
+		}
+	})
+}
+
+// reader returns the database for reads that may lag behind writes: a
+// healthy replica, or the primary when there is none or r reads from the
+// primary.
+func (r *mysqlRepository) reader() *sql.DB {
+	if r.replicas == nil || r.onPrimary {
+		return r.db
+	}
+	logger := logging.FromContext(r.context())
+	if replica := r.replicas.pick(); replica != nil {
+		logger.Debug().Str("replica", replica.name).Msg("reading from replica")
+		return replica.db
+	}
+	logger.Warn().Msg("no healthy replica, reading from the primary")
+	return r.db
+}
+
+// openReplicas connects to the replicas and checks them once. Replicas that
+// cannot be reached yet fail that check rather than the opening, and join
+// once they pass a later one.
+func openReplicas(dsns []string) (*replicaSet, error) {
+	replicas := make([]*replica, 0, len(dsns))
+	for i, dsn := range dsns {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err == nil {
+			var db *sql.DB
+			if db, err = sql.Open(DriverName, dsn); err == nil {
+				replicas = append(replicas, &replica{db: db, name: cfg.Addr, healthy: 1})
+				continue
+			}
+		}
+		for _, r := range replicas {
+			r.db.Close()
+		}
+		return nil, fmt.Errorf("openReplicas open replica %d: %v", i, err)
+	}
+	set := newReplicaSet(replicas)
+	set.check(context.Background())
+	return set, nil
+}
@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const todoByIDSQL = "SELECT " + todoColumns + " FROM todos WHERE id = ? AND deleted_at IS NULL"
+
+// newReplicaMock returns a healthy replica backed by a mock that also
+// expects pings.
+func newReplicaMock(t *testing.T, name string) (*replica, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual), sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error %s was not expected when opening a stub database", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &replica{db: db, name: name, healthy: 1}, mock
+}
+
+func expectTodoByID(mock sqlmock.Sqlmock, text string) {
+	mock.ExpectQuery(todoByIDSQL).WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "done", "user_id", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+			AddRow(todo.ID, text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, nil))
+}
+
+func TestReadsGoToReplicasInTurn(t *testing.T) {
+	primary, primaryMock := NewMock()
+	defer primary.Close()
+	first, firstMock := newReplicaMock(t, "first")
+	second, secondMock := newReplicaMock(t, "second")
+	r := &mysqlRepository{db: primary, replicas: newReplicaSet([]*replica{first, second})}
+
+	expectTodoByID(firstMock, "from first")
+	expectTodoByID(secondMock, "from second")
+	expectTodoByID(firstMock, "from first")
+	for _, want := range []string{"from first", "from second", "from first"} {
+		if got, err := r.TodoByID(todo.ID); err != nil || got.Text != want {
+			t.Errorf("mysqlRepository.TodoByID() = %q, %v, want %q", got.Text, err, want)
+		}
+	}
+
+	// read-your-writes lookups go to the primary
+	expectTodoByID(primaryMock, "from primary")
+	if got, err := r.Primary().TodoByID(todo.ID); err != nil || got.Text != "from primary" {
+		t.Errorf("mysqlRepository.Primary().TodoByID() = %q, %v, want it from the primary", got.Text, err)
+	}
+	// and so does a repository derived from one reading from the primary
+	expectTodoByID(primaryMock, "from primary")
+	if got, err := r.Primary().WithActor(todo.UserID).TodoByID(todo.ID); err != nil || got.Text != "from primary" {
+		t.Errorf("mysqlRepository.Primary().WithActor().TodoByID() = %q, %v, want it from the primary", got.Text, err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, firstMock, secondMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	}
+}
+
+func TestUnhealthyReplicasAreSkipped(t *testing.T) {
+	primary, primaryMock := NewMock()
+	defer primary.Close()
+	first, firstMock := newReplicaMock(t, "first")
+	second, secondMock := newReplicaMock(t, "second")
+	replicas := newReplicaSet([]*replica{first, second})
+	r := &mysqlRepository{db: primary, replicas: replicas}
+
+	firstMock.ExpectPing().WillReturnError(fmt.Errorf("connection refused"))
+	secondMock.ExpectPing()
+	replicas.check(context.Background())
+	if first.isHealthy() || !second.isHealthy() {
+		t.Fatalf("after a failed ping, first healthy = %v, second healthy = %v", first.isHealthy(), second.isHealthy())
+	}
+	expectTodoByID(secondMock, "from second")
+	expectTodoByID(secondMock, "from second")
+	for i := 0; i < 2; i++ {
+		if got, err := r.TodoByID(todo.ID); err != nil || got.Text != "from second" {
+			t.Errorf("mysqlRepository.TodoByID() = %q, %v, want it from the healthy replica", got.Text, err)
+		}
+	}
+
+	// without healthy replicas, reads fall back to the primary
+	firstMock.ExpectPing().WillReturnError(fmt.Errorf("connection refused"))
+	secondMock.ExpectPing().WillReturnError(sql.ErrConnDone)
+	replicas.check(context.Background())
+	expectTodoByID(primaryMock, "from primary")
+	if got, err := r.TodoByID(todo.ID); err != nil || got.Text != "from primary" {
+		t.Errorf("mysqlRepository.TodoByID() = %q, %v, want it from the primary", got.Text, err)
+	}
+
+	// a replica passing its health check again takes reads again
+	firstMock.ExpectPing()
+	secondMock.ExpectPing().WillReturnError(sql.ErrConnDone)
+	replicas.check(context.Background())
+	expectTodoByID(firstMock, "from first")
+	if got, err := r.TodoByID(todo.ID); err != nil || got.Text != "from first" {
+		t.Errorf("mysqlRepository.TodoByID() = %q, %v, want it from the recovered replica", got.Text, err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, firstMock, secondMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// SetTodoDueAt sets the due date of a todo. A zero dueAt clears it.
+func (r *mysqlRepository) SetTodoDueAt(todoId string, dueAt time.Time) (bool, error) {
+	return r.auditedExec("SetTodoDueAt", entityTodo, repo.ActionUpdate, todoId,
+		"UPDATE todos SET due_at = ? WHERE id = ?", nullTime(dueAt), todoId)
+}
+
+func (r *mysqlRepository) SetTodoPriority(todoId string, priority repo.Priority) (bool, error) {
+	return r.auditedExec("SetTodoPriority", entityTodo, repo.ActionUpdate, todoId,
+		"UPDATE todos SET priority = ? WHERE id = ?", priority, todoId)
+}
+
+// SetTodoRecurrence sets the recurrence rule of a todo. An empty rule makes
+// it a one-off todo again.
+func (r *mysqlRepository) SetTodoRecurrence(todoId string, recurrence string) (bool, error) {
+	return r.auditedExec("SetTodoRecurrence", entityTodo, repo.ActionUpdate, todoId,
+		"UPDATE todos SET recurrence = ? WHERE id = ?", nullString(recurrence), todoId)
+}
+
+// OverdueTodos returns the open todos visible to the user that were due before now, the
+// longest overdue first.
+func (r *mysqlRepository) OverdueTodos(userId string, now time.Time) ([]repo.TodoRow, error) {
+	return r.scheduledTodos("OverdueTodos",
+		"SELECT "+todoColumns+" FROM todos WHERE "+visibleTodos+" AND done = FALSE AND due_at < ? ORDER BY due_at, priority DESC",
+		userId, now)
+}
+
+// TodosDueBetween returns the todos visible to the user due in [from, to), soonest first.
+func (r *mysqlRepository) TodosDueBetween(userId string, from time.Time, to time.Time) ([]repo.TodoRow, error) {
+	return r.scheduledTodos("TodosDueBetween",
+		"SELECT "+todoColumns+" FROM todos WHERE "+visibleTodos+" AND due_at >= ? AND due_at < ? ORDER BY due_at, priority DESC",
+		userId, from, to)
+}
+
+func (r *mysqlRepository) scheduledTodos(op string, query string, userId string, args ...interface{}) ([]repo.TodoRow, error) {
+	var todos []repo.TodoRow
+
+	rows, err := r.db.QueryContext(r.context(), query, append(visibleTodosArgs(userId), args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s query %q: %v", op, userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo repo.TodoRow
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("%s scan row %q: %v", op, userId, err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err %q: %v", op, userId, err)
+	}
+
+	return todos, nil
+}
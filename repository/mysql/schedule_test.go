@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+var dueYesterday = time.Date(2022, 5, 19, 18, 0, 0, 0, time.UTC)
+var dueTomorrow = time.Date(2022, 5, 21, 9, 0, 0, 0, time.UTC)
+
+func TestOverdueTodos(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 12, 0, 0, 0, time.UTC)
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos " +
+		"WHERE " + visibleTo + " AND done = FALSE AND due_at < ? ORDER BY due_at, priority DESC"
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, dueYesterday, repo.PriorityHigh, nil, nil)
+	mock.ExpectQuery(query).WithArgs(todo.UserID, todo.UserID, todo.UserID, now).WillReturnRows(rows)
+
+	got, err := mysqlRepo.OverdueTodos(todo.UserID, now)
+	if err != nil {
+		t.Fatalf("mysqlRepository.OverdueTodos() error = %v", err)
+	}
+	want := *todo
+	want.DueAt = dueYesterday
+	want.Priority = repo.PriorityHigh
+	if !reflect.DeepEqual(got, []repo.TodoRow{want}) {
+		t.Errorf("mysqlRepository.OverdueTodos() = %v, want %v", got, []repo.TodoRow{want})
+	}
+}
+
+func TestTodosDueBetween(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	from := time.Date(2022, 5, 21, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos " +
+		"WHERE " + visibleTo + " AND due_at >= ? AND due_at < ? ORDER BY due_at, priority DESC"
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}).
+		AddRow(todoBySameUser.ID, todoBySameUser.Text, todoBySameUser.Done, todoBySameUser.UserID,
+			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt, nil, nil, dueTomorrow, repo.PriorityLow, nil, nil)
+	mock.ExpectQuery(query).WithArgs(todo.UserID, todo.UserID, todo.UserID, from, to).WillReturnRows(rows)
+
+	got, err := mysqlRepo.TodosDueBetween(todo.UserID, from, to)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodosDueBetween() error = %v", err)
+	}
+	want := *todoBySameUser
+	want.DueAt = dueTomorrow
+	want.Priority = repo.PriorityLow
+	if !reflect.DeepEqual(got, []repo.TodoRow{want}) {
+		t.Errorf("mysqlRepository.TodosDueBetween() = %v, want %v", got, []repo.TodoRow{want})
+	}
+}
+
+func TestSetTodoDueAt(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "UPDATE todos SET due_at = ? WHERE id = ?"
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement).WithArgs(dueTomorrow, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement).WithArgs(nil, todo.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	tests := []struct {
+		name  string
+		dueAt time.Time
+	}{
+		{"test set due date should update", dueTomorrow},
+		{"test clear due date should set null", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.SetTodoDueAt(todo.ID, tt.dueAt)
+			if err != nil || !got {
+				t.Errorf("mysqlRepository.SetTodoDueAt() = %v, %v, want true, nil", got, err)
+			}
+		})
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// SearchTodos returns the todos visible to the user whose text matches query in natural
+// language mode, most relevant first.
+func (r *mysqlRepository) SearchTodos(userId string, query string, limit int, offset int) ([]repo.TodoSearchRow, error) {
+	var results []repo.TodoSearchRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT "+todoColumns+", MATCH(text) AGAINST (? IN NATURAL LANGUAGE MODE) AS score "+
+		"FROM todos WHERE "+visibleTodos+" AND MATCH(text) AGAINST (? IN NATURAL LANGUAGE MODE) "+
+		"ORDER BY score DESC, id LIMIT ? OFFSET ?",
+		append(append([]interface{}{query}, visibleTodosArgs(userId)...), query, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTodos query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var result repo.TodoSearchRow
+		if err := scanTodo(rows, &result.Todo, &result.Score); err != nil {
+			return nil, fmt.Errorf("SearchTodos scan row %q: %v", userId, err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SearchTodos rows err %q: %v", userId, err)
+	}
+
+	return results, nil
+}
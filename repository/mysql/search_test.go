@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func TestSearchTodos(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position, " +
+		"MATCH(text) AGAINST (? IN NATURAL LANGUAGE MODE) AS score " +
+		"FROM todos WHERE " + visibleTo + " AND MATCH(text) AGAINST (? IN NATURAL LANGUAGE MODE) " +
+		"ORDER BY score DESC, id LIMIT ? OFFSET ?"
+	rows := sqlmock.NewRows([]string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position", "score"}).
+		AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil, 1.5).
+		AddRow(todoBySameUser.ID, todoBySameUser.Text, todoBySameUser.Done, todoBySameUser.UserID,
+			todoBySameUser.CreatedAt, todoBySameUser.CompletedAt, nil, nil, nil, 0, nil, nil, 0.25)
+	mock.ExpectQuery(query).WithArgs("dog", todo.UserID, todo.UserID, todo.UserID, "dog", 3, 0).WillReturnRows(rows)
+
+	got, err := mysqlRepo.SearchTodos(todo.UserID, "dog", 3, 0)
+	if err != nil {
+		t.Fatalf("mysqlRepository.SearchTodos() error = %v", err)
+	}
+	want := []repo.TodoSearchRow{{Todo: *todo, Score: 1.5}, {Todo: *todoBySameUser, Score: 0.25}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.SearchTodos() = %v, want %v", got, want)
+	}
+}
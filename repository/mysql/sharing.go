@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// Todos and lists are shared the same way, through a collaborators table
+// keyed by the shared item and the user. The helpers below take the table
+// and its item column, which are never user input.
+
+func (r *mysqlRepository) ShareTodo(todoId string, userId string, role repo.Role) (bool, error) {
+	return r.share("ShareTodo", entityTodo, "todo_collaborators", "todo_id", todoId, userId, role)
+}
+
+func (r *mysqlRepository) UnshareTodo(todoId string, userId string) (bool, error) {
+	return r.unshare("UnshareTodo", entityTodo, "todo_collaborators", "todo_id", todoId, userId)
+}
+
+func (r *mysqlRepository) TodoCollaborators(todoId string) ([]repo.CollaboratorRow, error) {
+	return r.collaborators("TodoCollaborators", "todo_collaborators", "todo_id", todoId)
+}
+
+func (r *mysqlRepository) TodoRole(todoId string, userId string) (repo.Role, error) {
+	return r.strongestRole("TodoRole",
+		"SELECT role FROM todo_collaborators WHERE todo_id = ? AND user_id = ? "+
+			"UNION ALL SELECT lc.role FROM list_collaborators lc JOIN todos t ON t.list_id = lc.list_id WHERE t.id = ? AND lc.user_id = ?",
+		todoId, userId, todoId, userId)
+}
+
+func (r *mysqlRepository) ShareTodoList(listId string, userId string, role repo.Role) (bool, error) {
+	return r.share("ShareTodoList", entityList, "list_collaborators", "list_id", listId, userId, role)
+}
+
+func (r *mysqlRepository) UnshareTodoList(listId string, userId string) (bool, error) {
+	return r.unshare("UnshareTodoList", entityList, "list_collaborators", "list_id", listId, userId)
+}
+
+func (r *mysqlRepository) TodoListCollaborators(listId string) ([]repo.CollaboratorRow, error) {
+	return r.collaborators("TodoListCollaborators", "list_collaborators", "list_id", listId)
+}
+
+func (r *mysqlRepository) TodoListRole(listId string, userId string) (repo.Role, error) {
+	return r.strongestRole("TodoListRole", "SELECT role FROM list_collaborators WHERE list_id = ? AND user_id = ?", listId, userId)
+}
+
+// share grants a role to a user, replacing the role they had before. It
+// returns false when the user already had the role.
+func (r *mysqlRepository) share(op string, entity string, table string, column string, id string, userId string, role repo.Role) (bool, error) {
+	return r.auditedExec(op, entity, repo.ActionShare, id,
+		"INSERT INTO "+table+"("+column+", user_id, role, created_at) VALUES (?, ?, ?, now()) "+
+			"ON DUPLICATE KEY UPDATE role = VALUES(role)", id, userId, role)
+}
+
+func (r *mysqlRepository) unshare(op string, entity string, table string, column string, id string, userId string) (bool, error) {
+	return r.auditedExec(op, entity, repo.ActionUnshare, id,
+		"DELETE FROM "+table+" WHERE "+column+" = ? AND user_id = ?", id, userId)
+}
+
+func (r *mysqlRepository) collaborators(op string, table string, column string, id string) ([]repo.CollaboratorRow, error) {
+	var collaborators []repo.CollaboratorRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT user_id, role, created_at FROM "+table+" WHERE "+column+" = ? ORDER BY created_at, user_id", id)
+	if err != nil {
+		return nil, fmt.Errorf("%s query %q: %v", op, id, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c repo.CollaboratorRow
+		if err := rows.Scan(&c.UserID, &c.Role, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s scan row %q: %v", op, id, err)
+		}
+		collaborators = append(collaborators, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err %q: %v", op, id, err)
+	}
+
+	return collaborators, nil
+}
+
+// strongestRole returns the strongest of the roles selected by query, or ""
+// when there is none.
+func (r *mysqlRepository) strongestRole(op string, query string, args ...interface{}) (repo.Role, error) {
+	rows, err := r.db.QueryContext(r.context(), query, args...)
+	if err != nil {
+		return "", fmt.Errorf("%s query %q: %v", op, args[0], err)
+	}
+
+	defer rows.Close()
+
+	var strongest repo.Role
+	for rows.Next() {
+		var role repo.Role
+		if err := rows.Scan(&role); err != nil {
+			return "", fmt.Errorf("%s scan row %q: %v", op, args[0], err)
+		}
+		if role == repo.RoleEditor || strongest == "" {
+			strongest = role
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("%s rows err %q: %v", op, args[0], err)
+	}
+
+	return strongest, nil
+}
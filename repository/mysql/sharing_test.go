@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// visibleTo is the condition selecting the todos a user owns or that are
+// shared with them.
+const visibleTo = "deleted_at IS NULL AND (user_id = ? OR id IN (SELECT todo_id FROM todo_collaborators WHERE user_id = ?) " +
+	"OR list_id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?))"
+
+const collaboratorID = "1124chloezhuqing"
+
+func TestShareTodo(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "INSERT INTO todo_collaborators(todo_id, user_id, role, created_at) VALUES (?, ?, ?, now()) " +
+		"ON DUPLICATE KEY UPDATE role = VALUES(role)"
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionShare, func() {
+		mock.ExpectExec(statement).WithArgs(todo.ID, collaboratorID, repo.RoleViewer).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	// sharing again with the same role changes no row
+	expectUnchanged(mock, entityTodo, todo.ID, repo.ActionShare, func() {
+		mock.ExpectExec(statement).WithArgs(todo.ID, collaboratorID, repo.RoleViewer).WillReturnResult(sqlmock.NewResult(0, 0))
+	})
+
+	for _, want := range []bool{true, false} {
+		got, err := mysqlRepo.ShareTodo(todo.ID, collaboratorID, repo.RoleViewer)
+		if err != nil || got != want {
+			t.Errorf("mysqlRepository.ShareTodo() = %v, %v, want %v, nil", got, err, want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUnshareTodoList(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "DELETE FROM list_collaborators WHERE list_id = ? AND user_id = ?"
+	expectAudited(mock, entityList, listGarden.ID, repo.ActionUnshare, func() {
+		mock.ExpectExec(statement).WithArgs(listGarden.ID, collaboratorID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	expectUnchanged(mock, entityList, listGarden.ID, repo.ActionUnshare, func() {
+		mock.ExpectExec(statement).WithArgs(listGarden.ID, "stranger").WillReturnResult(sqlmock.NewResult(0, 0))
+	})
+
+	tests := []struct {
+		name   string
+		userId string
+		want   bool
+	}{
+		{"test unshare collaborator should delete", collaboratorID, true},
+		{"test unshare non collaborator should not delete", "stranger", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.UnshareTodoList(listGarden.ID, tt.userId)
+			if err != nil {
+				t.Errorf("mysqlRepository.UnshareTodoList() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.UnshareTodoList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodoCollaborators(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	sharedAt := time.Date(2022, 5, 20, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT user_id, role, created_at FROM todo_collaborators WHERE todo_id = ? ORDER BY created_at, user_id").
+		WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "role", "created_at"}).
+			AddRow(collaboratorID, "editor", sharedAt))
+
+	got, err := mysqlRepo.TodoCollaborators(todo.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodoCollaborators() error = %v", err)
+	}
+	want := []repo.CollaboratorRow{{UserID: collaboratorID, Role: repo.RoleEditor, CreatedAt: sharedAt}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.TodoCollaborators() = %v, want %v", got, want)
+	}
+}
+
+func TestTodoRole(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	query := "SELECT role FROM todo_collaborators WHERE todo_id = ? AND user_id = ? " +
+		"UNION ALL SELECT lc.role FROM list_collaborators lc JOIN todos t ON t.list_id = lc.list_id WHERE t.id = ? AND lc.user_id = ?"
+	columns := []string{"role"}
+	mock.ExpectQuery(query).WithArgs(todo.ID, collaboratorID, todo.ID, collaboratorID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("viewer"))
+	mock.ExpectQuery(query).WithArgs(todo.ID, collaboratorID, todo.ID, collaboratorID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("editor").AddRow("viewer"))
+	mock.ExpectQuery(query).WithArgs(todo.ID, collaboratorID, todo.ID, collaboratorID).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	tests := []struct {
+		name string
+		want repo.Role
+	}{
+		{"test role of viewer should be viewer", repo.RoleViewer},
+		{"test todo and list roles should resolve to the strongest", repo.RoleEditor},
+		{"test role of stranger should be empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.TodoRole(todo.ID, collaboratorID)
+			if err != nil {
+				t.Errorf("mysqlRepository.TodoRole() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.TodoRole() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+// completedBetween restricts a todos query to the todos completed in a
+// window. completed_at is also set on open todos, so done is checked too.
+const completedBetween = "done = TRUE AND completed_at >= ? AND completed_at < ?"
+
+// TodoStats counts in SQL rather than loading the todos, which keeps it cheap
+// for users with many of them.
+func (r *mysqlRepository) TodoStats(userId string, from time.Time, to time.Time) (repo.TodoStatsRow, error) {
+	var stats repo.TodoStatsRow
+	window := append(visibleTodosArgs(userId), from, to)
+
+	row := r.db.QueryRowContext(r.context(), "SELECT COUNT(*), COALESCE(SUM(done), 0) FROM todos WHERE "+visibleTodos+" AND created_at >= ? AND created_at < ?", window...)
+	if err := row.Scan(&stats.Created, &stats.Done); err != nil {
+		return stats, fmt.Errorf("TodoStats row scan: %q %v", userId, err)
+	}
+
+	var seconds float64
+	row = r.db.QueryRowContext(r.context(), "SELECT COALESCE(AVG(TIMESTAMPDIFF(SECOND, created_at, completed_at)), 0) FROM todos WHERE "+visibleTodos+" AND "+completedBetween, window...)
+	if err := row.Scan(&seconds); err != nil {
+		return stats, fmt.Errorf("TodoStats row scan: %q %v", userId, err)
+	}
+	stats.AvgCompletion = time.Duration(seconds * float64(time.Second))
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT DATE(completed_at) AS day, COUNT(*) FROM todos WHERE "+visibleTodos+" AND "+completedBetween+" GROUP BY day ORDER BY day", window...)
+	if err != nil {
+		return stats, fmt.Errorf("TodoStats query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket repo.DayCount
+		if err := rows.Scan(&bucket.Day, &bucket.Count); err != nil {
+			return stats, fmt.Errorf("TodoStats scan row %q: %v", userId, err)
+		}
+		stats.Completions = append(stats.Completions, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("TodoStats rows err %q: %v", userId, err)
+	}
+
+	return stats, nil
+}
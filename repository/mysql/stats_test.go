@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func TestTodoStats(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	from := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	args := []driver.Value{todo.UserID, todo.UserID, todo.UserID, from, to}
+
+	mock.ExpectQuery("SELECT COUNT(*), COALESCE(SUM(done), 0) FROM todos WHERE " + visibleTo + " AND created_at >= ? AND created_at < ?").
+		WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"total", "done"}).AddRow(7, 3))
+	mock.ExpectQuery("SELECT COALESCE(AVG(TIMESTAMPDIFF(SECOND, created_at, completed_at)), 0) FROM todos WHERE " + visibleTo +
+		" AND done = TRUE AND completed_at >= ? AND completed_at < ?").
+		WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow([]byte("129600.0000")))
+	mock.ExpectQuery("SELECT DATE(completed_at) AS day, COUNT(*) FROM todos WHERE " + visibleTo +
+		" AND done = TRUE AND completed_at >= ? AND completed_at < ? GROUP BY day ORDER BY day").
+		WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).
+		AddRow(time.Date(2022, 5, 3, 0, 0, 0, 0, time.UTC), 1).
+		AddRow(time.Date(2022, 5, 20, 0, 0, 0, 0, time.UTC), 3))
+
+	got, err := mysqlRepo.TodoStats(todo.UserID, from, to)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodoStats() error = %v", err)
+	}
+	want := repo.TodoStatsRow{
+		Created:       7,
+		Done:          3,
+		AvgCompletion: 36 * time.Hour,
+		Completions: []repo.DayCount{
+			{Day: time.Date(2022, 5, 3, 0, 0, 0, 0, time.UTC), Count: 1},
+			{Day: time.Date(2022, 5, 20, 0, 0, 0, 0, time.UTC), Count: 3},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.TodoStats() = %+v, want %+v", got, want)
+	}
+}
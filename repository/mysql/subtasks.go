@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func (r *mysqlRepository) TodosByParent(parentId string) ([]repo.TodoRow, error) {
+	var todos []repo.TodoRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT "+todoColumns+" FROM todos WHERE parent_id = ? AND deleted_at IS NULL ORDER BY position, id", parentId)
+	if err != nil {
+		return nil, fmt.Errorf("TodosByParent query %q: %v", parentId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo repo.TodoRow
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("TodosByParent scan row %q: %v", parentId, err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodosByParent rows err %q: %v", parentId, err)
+	}
+
+	return todos, nil
+}
+
+// ChildProgress counts the direct children of a todo and how many of them
+// are done.
+func (r *mysqlRepository) ChildProgress(parentId string) (int, int, error) {
+	var completed, total int
+	row := r.db.QueryRowContext(r.context(), "SELECT COALESCE(SUM(done), 0), COUNT(*) FROM todos WHERE parent_id = ? AND deleted_at IS NULL", parentId)
+	if err := row.Scan(&completed, &total); err != nil {
+		return 0, 0, fmt.Errorf("ChildProgress row scan: %q %v", parentId, err)
+	}
+	return completed, total, nil
+}
+
+// SetTodoParent moves a todo under a new parent, or to the top level when
+// parentId is empty. It refuses parents that are the todo itself or one of
+// its descendants, since that would turn the hierarchy into a cycle. Of two
+// moves that would close a cycle together, one waits for the locks of the
+// other and then sees its move, or fails on a deadlock.
+func (r *mysqlRepository) SetTodoParent(todoId string, parentId string) (bool, error) {
+	if parentId == todoId {
+		return false, fmt.Errorf("SetTodoParent todo %q cannot be its own parent", todoId)
+	}
+
+	return r.audited("SetTodoParent", entityTodo, repo.ActionMove, todoId, func(tx *sql.Tx) (sql.Result, error) {
+		// walk up from the new parent, locking each ancestor so that no other
+		// move changes the chain before this one commits; meeting the todo on
+		// the way means the new parent is one of its descendants
+		seen := map[string]bool{}
+		for id := parentId; id != ""; {
+			if id == todoId {
+				return nil, fmt.Errorf("SetTodoParent todo %q is an ancestor of %q", todoId, parentId)
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("SetTodoParent ancestors of %q form a cycle", parentId)
+			}
+			seen[id] = true
+			var parent sql.NullString
+			if err := tx.QueryRow("SELECT parent_id FROM todos WHERE id = ? FOR UPDATE", id).Scan(&parent); err != nil {
+				return nil, fmt.Errorf("SetTodoParent ancestors scan: %q %v", id, err)
+			}
+			id = parent.String
+		}
+
+		result, err := tx.Exec("UPDATE todos SET parent_id = ? WHERE id = ?", nullString(parentId), todoId)
+		if err != nil {
+			return nil, fmt.Errorf("SetTodoParent exec : %v", err)
+		}
+		return result, nil
+	})
+}
+
+// CompleteDescendants marks every open todo below the given one as done and
+// returns how many were completed. Each completed todo gets its own history
+// entry.
+func (r *mysqlRepository) CompleteDescendants(todoId string) (int64, error) {
+	return r.auditedTodos("CompleteDescendants", repo.ActionComplete, "WITH RECURSIVE descendants AS ("+
+		"SELECT id FROM todos WHERE parent_id = ? "+
+		"UNION ALL SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id) ",
+		"SELECT t.id FROM todos t JOIN descendants d ON d.id = t.id WHERE t.done = FALSE AND t.deleted_at IS NULL",
+		"UPDATE todos t JOIN descendants d ON d.id = t.id SET t.done = TRUE, t.completed_at = curdate() WHERE t.done = FALSE AND t.deleted_at IS NULL",
+		todoId)
+}
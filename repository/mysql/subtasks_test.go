@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const ancestorQuery = "SELECT parent_id FROM todos WHERE id = ? FOR UPDATE"
+
+func TestChildProgress(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	mock.ExpectQuery("SELECT COALESCE(SUM(done), 0), COUNT(*) FROM todos WHERE parent_id = ? AND deleted_at IS NULL").WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"completed", "total"}).AddRow(1, 3))
+
+	completed, total, err := mysqlRepo.ChildProgress(todo.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.ChildProgress() error = %v", err)
+	}
+	if completed != 1 || total != 3 {
+		t.Errorf("mysqlRepository.ChildProgress() = %d/%d, want 1/3", completed, total)
+	}
+}
+
+func TestSetTodoParent(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "UPDATE todos SET parent_id = ? WHERE id = ?"
+
+	// todoBySameUser becomes a child of todo
+	expectAudited(mock, entityTodo, todoBySameUser.ID, repo.ActionMove, func() {
+		mock.ExpectQuery(ancestorQuery).WithArgs(todo.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+		mock.ExpectExec(statement).WithArgs(todo.ID, todoBySameUser.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	// todo under its own child would close a cycle
+	expectUnchanged(mock, entityTodo, todo.ID, repo.ActionMove, func() {
+		mock.ExpectQuery(ancestorQuery).WithArgs(todoBySameUser.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(todo.ID))
+	})
+
+	// moving back to the top level needs no cycle check
+	expectAudited(mock, entityTodo, todoBySameUser.ID, repo.ActionMove, func() {
+		mock.ExpectExec(statement).WithArgs(nil, todoBySameUser.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	tests := []struct {
+		name     string
+		todoId   string
+		parentId string
+		want     bool
+		wantErr  bool
+	}{
+		{"test set parent should update", todoBySameUser.ID, todo.ID, true, false},
+		{"test set parent to a descendant should fail", todo.ID, todoBySameUser.ID, false, true},
+		{"test set parent to itself should fail", todo.ID, todo.ID, false, true},
+		{"test clear parent should update", todoBySameUser.ID, "", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.SetTodoParent(tt.todoId, tt.parentId)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlRepository.SetTodoParent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.SetTodoParent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompleteDescendants(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	descendants := "WITH RECURSIVE descendants AS (" +
+		"SELECT id FROM todos WHERE parent_id = ? " +
+		"UNION ALL SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id) "
+	statement := descendants +
+		"UPDATE todos t JOIN descendants d ON d.id = t.id SET t.done = TRUE, t.completed_at = curdate() WHERE t.done = FALSE AND t.deleted_at IS NULL"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(descendants + "SELECT t.id FROM todos t JOIN descendants d ON d.id = t.id WHERE t.done = FALSE AND t.deleted_at IS NULL").WithArgs(todo.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(todoBySameUser.ID).AddRow(todoByDifferentUser.ID))
+	expectSnapshot(mock, entityTodo, todoBySameUser.ID)
+	expectSnapshot(mock, entityTodo, todoByDifferentUser.ID)
+	mock.ExpectExec(statement).WithArgs(todo.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, id := range []string{todoBySameUser.ID, todoByDifferentUser.ID} {
+		expectSnapshot(mock, entityTodo, id)
+		mock.ExpectExec(historyInsert).WithArgs(entityTodo, id, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), repo.ActionComplete, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectEvent(mock, entityTodo, id, repo.ActionComplete)
+	}
+	mock.ExpectCommit()
+
+	got, err := mysqlRepo.CompleteDescendants(todo.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.CompleteDescendants() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("mysqlRepository.CompleteDescendants() = %d, want 2", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const todoVersionQuery = "SELECT COALESCE(MAX(id), 0) FROM history WHERE entity_type = ? AND entity_id = ?"
+
+func (r *mysqlRepository) TodoVersion(todoId string) (int64, error) {
+	var version int64
+	row := r.db.QueryRowContext(r.context(), todoVersionQuery, entityTodo, todoId)
+	if err := row.Scan(&version); err != nil {
+		return 0, fmt.Errorf("TodoVersion row scan: %q %v", todoId, err)
+	}
+	return version, nil
+}
+
+// TodoChangesSince versions todos by their history: the history entries
+// after since, grouped by todo, give the todos changed since and their latest
+// versions. Every todo has an entry, those older than history through the
+// baseline of migration 019, so a sync from 0 gets all of them.
+func (r *mysqlRepository) TodoChangesSince(userId string, since int64, limit int) ([]repo.TodoChangeRow, error) {
+	var changes []repo.TodoChangeRow
+
+	args := append([]interface{}{entityTodo, since}, visibleTodosArgs(userId)...)
+	rows, err := r.db.QueryContext(r.context(), "SELECT "+todoColumns+", deleted_at, v.version FROM todos "+
+		"JOIN (SELECT entity_id, MAX(id) AS version FROM history WHERE entity_type = ? AND id > ? GROUP BY entity_id) v ON v.entity_id = todos.id "+
+		"WHERE "+accessibleTodos+" ORDER BY v.version LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("TodoChangesSince query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var change repo.TodoChangeRow
+		var deletedAt sql.NullTime
+		if err := scanTodo(rows, &change.Todo, &deletedAt, &change.Version); err != nil {
+			return nil, fmt.Errorf("TodoChangesSince scan row %q: %v", userId, err)
+		}
+		change.Deleted = deletedAt.Valid
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodoChangesSince rows err %q: %v", userId, err)
+	}
+
+	return changes, nil
+}
+
+// SyncTodo locks the todo, with the snapshot taken before the change, before
+// it reads the version, so that no other write to the todo comes between the
+// check and the change. The lock on a todo that does not exist yet covers the
+// id, for a create.
+func (r *mysqlRepository) SyncTodo(change repo.TodoSync) (bool, int64, error) {
+	tx, err := r.db.BeginTx(r.context(), nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("SyncTodo begin : %v", err)
+	}
+	defer tx.Rollback()
+
+	before, err := takeSnapshot(tx, entityTodo, change.ID)
+	if err != nil {
+		return false, 0, fmt.Errorf("SyncTodo snapshot before : %v", err)
+	}
+	// the first plain read of the transaction, made after the lock, sees
+	// every write committed before
+	var version int64
+	if err := tx.QueryRow(todoVersionQuery, entityTodo, change.ID).Scan(&version); err != nil {
+		return false, 0, fmt.Errorf("SyncTodo row scan: %q %v", change.ID, err)
+	}
+
+	switch {
+	case change.Create != nil:
+		if before != nil || version > 0 {
+			return false, version, nil
+		}
+		err = r.addTodoIn(tx, *change.Create, "")
+	case before == nil || version != change.BaseVersion:
+		return false, version, nil
+	case change.Delete:
+		_, err = r.writeTodos(tx, "SyncTodo", repo.ActionDelete, subtreeWith, deleteSubtreePick, deleteSubtreeWrite, change.ID)
+	default:
+		err = r.updateSynced(tx, change, before)
+	}
+	if err != nil {
+		return false, version, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, version, fmt.Errorf("SyncTodo commit : %v", err)
+	}
+	return true, version, nil
+}
+
+// updateSynced writes the fields of a change to the todo, recorded as one
+// update, and adds its next occurrence.
+func (r *mysqlRepository) updateSynced(tx *sql.Tx, change repo.TodoSync, before *snapshot) error {
+	var sets []string
+	var args []interface{}
+	if change.Text != nil {
+		sets, args = append(sets, "text = ?"), append(args, *change.Text)
+	}
+	if change.Done != nil {
+		if *change.Done {
+			sets = append(sets, "done = TRUE, completed_at = curdate()")
+		} else {
+			sets = append(sets, "done = FALSE, completed_at = NULL")
+		}
+	}
+	if change.Priority != nil {
+		sets, args = append(sets, "priority = ?"), append(args, *change.Priority)
+	}
+	if change.DueAt != nil {
+		sets, args = append(sets, "due_at = ?"), append(args, nullTime(*change.DueAt))
+	}
+	if change.ListID != nil {
+		sets, args = append(sets, "list_id = ?"), append(args, nullString(*change.ListID))
+	}
+	if change.Recurrence != nil {
+		sets, args = append(sets, "recurrence = ?"), append(args, nullString(*change.Recurrence))
+	}
+
+	if len(sets) > 0 {
+		if _, err := tx.Exec("UPDATE todos SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, change.ID)...); err != nil {
+			return fmt.Errorf("SyncTodo exec : %v", err)
+		}
+		after, err := takeSnapshot(tx, entityTodo, change.ID)
+		if err != nil {
+			return fmt.Errorf("SyncTodo snapshot after : %v", err)
+		}
+		if c := (entityChange{id: change.ID, before: before, after: after}); len(c.fields()) > 0 {
+			if err := r.record(tx, entityTodo, change.ID, repo.ActionUpdate, before, after); err != nil {
+				return fmt.Errorf("SyncTodo record history : %v", err)
+			}
+		}
+	}
+	if change.Next != nil {
+		return r.addTodoIn(tx, *change.Next, change.ID)
+	}
+	return nil
+}
+
+// addTodoIn adds a todo within tx, with the tags of the todo tagsOf unless
+// it is empty.
+func (r *mysqlRepository) addTodoIn(tx *sql.Tx, row repo.TodoRow, tagsOf string) error {
+	if _, err := insertTodo(tx, row); err != nil {
+		return fmt.Errorf("SyncTodo exec : %v", err)
+	}
+	if tagsOf != "" {
+		if _, err := tx.Exec("INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, tag_id FROM todo_tags WHERE todo_id = ?", row.ID, tagsOf); err != nil {
+			return fmt.Errorf("SyncTodo exec : %v", err)
+		}
+	}
+	after, err := takeSnapshot(tx, entityTodo, row.ID)
+	if err != nil {
+		return fmt.Errorf("SyncTodo snapshot after : %v", err)
+	}
+	if err := r.record(tx, entityTodo, row.ID, repo.ActionCreate, nil, after); err != nil {
+		return fmt.Errorf("SyncTodo record history : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+func TestTodoChangesSince(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	accessibleTo := "(user_id = ? OR id IN (SELECT todo_id FROM todo_collaborators WHERE user_id = ?) " +
+		"OR list_id IN (SELECT list_id FROM list_collaborators WHERE user_id = ?))"
+	mock.ExpectQuery("SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position, "+
+		"deleted_at, v.version FROM todos "+
+		"JOIN (SELECT entity_id, MAX(id) AS version FROM history WHERE entity_type = ? AND id > ? GROUP BY entity_id) v ON v.entity_id = todos.id "+
+		"WHERE "+accessibleTo+" ORDER BY v.version LIMIT ?").
+		WithArgs(entityTodo, 40, todo.UserID, todo.UserID, todo.UserID, 3).
+		WillReturnRows(sqlmock.NewRows(append(todoSnapshotColumns[:12:12], "deleted_at", "version")).
+			AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 1, nil, "V", nil, 41).
+			AddRow(todoBySameUser.ID, todoBySameUser.Text, todoBySameUser.Done, todoBySameUser.UserID, todoBySameUser.CreatedAt,
+				todoBySameUser.CompletedAt, nil, nil, nil, 1, nil, "W", todoBySameUser.CreatedAt, 44))
+
+	got, err := mysqlRepo.TodoChangesSince(todo.UserID, 40, 3)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TodoChangesSince() error = %v", err)
+	}
+	want := []repo.TodoChangeRow{
+		{Todo: repo.TodoRow{ID: todo.ID, Text: todo.Text, Done: todo.Done, UserID: todo.UserID, CreatedAt: todo.CreatedAt,
+			CompletedAt: todo.CompletedAt, Priority: repo.PriorityMedium, Position: "V"}, Version: 41},
+		{Todo: repo.TodoRow{ID: todoBySameUser.ID, Text: todoBySameUser.Text, Done: todoBySameUser.Done, UserID: todoBySameUser.UserID,
+			CreatedAt: todoBySameUser.CreatedAt, CompletedAt: todoBySameUser.CompletedAt, Priority: repo.PriorityMedium, Position: "W"},
+			Deleted: true, Version: 44},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.TodoChangesSince() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncTodo(t *testing.T) {
+	text := "Water the roses"
+	versionSQL := "SELECT COALESCE(MAX(id), 0) FROM history WHERE entity_type = ? AND entity_id = ?"
+
+	tests := []struct {
+		name        string
+		change      repo.TodoSync
+		expect      func(mock sqlmock.Sqlmock)
+		wantApplied bool
+		wantVersion int64
+	}{
+		{
+			name:   "test sync of a todo at its base version should check it and write in one transaction",
+			change: repo.TodoSync{ID: todo.ID, BaseVersion: 41, Text: &text},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectSnapshot(mock, entityTodo, todo.ID)
+				mock.ExpectQuery(versionSQL).WithArgs(entityTodo, todo.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(41))
+				mock.ExpectExec("UPDATE todos SET text = ? WHERE id = ?").WithArgs(text, todo.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(todoSnapshotSQL).WithArgs(todo.ID).
+					WillReturnRows(sqlmock.NewRows(todoSnapshotColumns).
+						AddRow(todo.ID, text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil, nil, nil, nil, nil))
+				mock.ExpectExec(historyInsert).
+					WithArgs(entityTodo, todo.ID, todo.UserID, nil, nil, repo.ActionUpdate,
+						`{"text":{"before":"Water roses and lilies","after":"Water the roses"}}`).
+					WillReturnResult(sqlmock.NewResult(42, 1))
+				expectEvent(mock, entityTodo, todo.ID, repo.ActionUpdate)
+				mock.ExpectCommit()
+			},
+			wantApplied: true,
+			wantVersion: 41,
+		},
+		{
+			name:   "test sync of a todo changed after its base version should write nothing",
+			change: repo.TodoSync{ID: todo.ID, BaseVersion: 41, Text: &text},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectSnapshot(mock, entityTodo, todo.ID)
+				mock.ExpectQuery(versionSQL).WithArgs(entityTodo, todo.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(43))
+				mock.ExpectRollback()
+			},
+			wantApplied: false,
+			wantVersion: 43,
+		},
+		{
+			name:   "test sync creating a todo that exists should write nothing",
+			change: repo.TodoSync{ID: todo.ID, Create: &repo.TodoRow{ID: todo.ID, Text: text, UserID: todo.UserID}},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectSnapshot(mock, entityTodo, todo.ID)
+				mock.ExpectQuery(versionSQL).WithArgs(entityTodo, todo.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(41))
+				mock.ExpectRollback()
+			},
+			wantApplied: false,
+			wantVersion: 41,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			mysqlRepo := &mysqlRepository{db: db}
+
+			defer func() {
+				mysqlRepo.Close()
+			}()
+
+			tt.expect(mock)
+			applied, version, err := mysqlRepo.SyncTodo(tt.change)
+			if err != nil || applied != tt.wantApplied || version != tt.wantVersion {
+				t.Errorf("mysqlRepository.SyncTodo() = %v, %v, %v, want %v, %v, nil", applied, version, err, tt.wantApplied, tt.wantVersion)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	repo "github.com/chloexu/hackernews/repository"
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDuplicateEntry is the MySQL error number for a unique key violation.
+const errDuplicateEntry = 1062
+
+func (r *mysqlRepository) TagByID(id string) (repo.TagRow, error) {
+	var tag repo.TagRow
+	row := r.db.QueryRowContext(r.context(), "SELECT id, user_id, name FROM tags WHERE id = ?", id)
+	if err := row.Scan(&tag.ID, &tag.UserID, &tag.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return tag, fmt.Errorf("TagByID row scan: no row. %q %v", id, err)
+		}
+		return tag, fmt.Errorf("TagByID row scan: %q %v", id, err)
+	}
+	return tag, nil
+}
+
+func (r *mysqlRepository) TagByName(userId string, name string) (repo.TagRow, error) {
+	var tag repo.TagRow
+	row := r.db.QueryRowContext(r.context(), "SELECT id, user_id, name FROM tags WHERE user_id = ? AND name = ?", userId, name)
+	if err := row.Scan(&tag.ID, &tag.UserID, &tag.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return tag, fmt.Errorf("TagByName row scan: no row. %q %q %v", userId, name, err)
+		}
+		return tag, fmt.Errorf("TagByName row scan: %q %q %v", userId, name, err)
+	}
+	return tag, nil
+}
+
+func (r *mysqlRepository) TagsByTodo(todoId string) ([]repo.TagRow, error) {
+	var tags []repo.TagRow
+
+	rows, err := r.db.QueryContext(r.context(), "SELECT g.id, g.user_id, g.name FROM tags g JOIN todo_tags tt ON tt.tag_id = g.id WHERE tt.todo_id = ? ORDER BY g.name", todoId)
+	if err != nil {
+		return nil, fmt.Errorf("TagsByTodo query %q: %v", todoId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag repo.TagRow
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name); err != nil {
+			return nil, fmt.Errorf("TagsByTodo scan row %q: %v", todoId, err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TagsByTodo rows err %q: %v", todoId, err)
+	}
+
+	return tags, nil
+}
+
+// AddTag inserts a tag unless the user already has one with the same name,
+// in which case nothing is inserted and false is returned.
+func (r *mysqlRepository) AddTag(row repo.TagRow) (bool, error) {
+	return r.auditedExec("AddTag", entityTag, repo.ActionCreate, row.ID,
+		"INSERT IGNORE INTO tags(id, user_id, name) VALUES (?, ?, ?)", row.ID, row.UserID, row.Name)
+}
+
+func (r *mysqlRepository) RenameTag(id string, name string) (bool, error) {
+	return r.audited("RenameTag", entityTag, repo.ActionUpdate, id, func(tx *sql.Tx) (sql.Result, error) {
+		result, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", name, id)
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry {
+				return nil, fmt.Errorf("RenameTag tag %q already exists", name)
+			}
+			return nil, fmt.Errorf("RenameTag exec : %v", err)
+		}
+		return result, nil
+	})
+}
+
+// AddTagToTodo links a tag to a todo. Linking a tag that is already attached
+// is not an error and returns false.
+func (r *mysqlRepository) AddTagToTodo(todoId string, tagId string) (bool, error) {
+	return r.auditedExec("AddTagToTodo", entityTodo, repo.ActionTag, todoId,
+		"INSERT IGNORE INTO todo_tags(todo_id, tag_id) VALUES (?, ?)", todoId, tagId)
+}
+
+func (r *mysqlRepository) RemoveTagFromTodo(todoId string, tagId string) (bool, error) {
+	return r.auditedExec("RemoveTagFromTodo", entityTodo, repo.ActionUntag, todoId,
+		"DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?", todoId, tagId)
+}
+
+// taggedWithAll is the condition selecting the todos that carry every one of
+// the given tag names, with its args.
+func taggedWithAll(tags []string) (string, []interface{}) {
+	return "id IN (SELECT tt.todo_id FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id " +
+			"WHERE g.name IN (" + placeholders(len(tags)) + ") " +
+			"GROUP BY tt.todo_id HAVING COUNT(DISTINCT g.id) = ?)",
+		append(stringArgs(tags), len(tags))
+}
+
+// TodosByUserAndTags returns the todos visible to the user carrying every one of the given
+// tag names. An empty tag list behaves like TodosByUser.
+func (r *mysqlRepository) TodosByUserAndTags(userId string, tags []string) ([]repo.TodoRow, error) {
+	if len(tags) == 0 {
+		return r.TodosByUser(userId)
+	}
+
+	var todos []repo.TodoRow
+
+	tagged, tagArgs := taggedWithAll(tags)
+	query := "SELECT " + todoColumns + " FROM todos WHERE " + visibleTodos + " AND " + tagged + " ORDER BY position, id"
+	args := append(visibleTodosArgs(userId), tagArgs...)
+
+	rows, err := r.db.QueryContext(r.context(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("TodosByUserAndTags query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo repo.TodoRow
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("TodosByUserAndTags scan row %q: %v", userId, err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TodosByUserAndTags rows err %q: %v", userId, err)
+	}
+
+	return todos, nil
+}
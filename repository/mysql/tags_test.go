@@ -0,0 +1,226 @@
+package mysql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+	"github.com/go-sql-driver/mysql"
+)
+
+var tagGarden = &repo.TagRow{
+	ID:     "caajol287d5nser7tag1",
+	UserID: "chloexu1124",
+	Name:   "garden",
+}
+var tagErrands = &repo.TagRow{
+	ID:     "caajol287d5nser7tag2",
+	UserID: "chloexu1124",
+	Name:   "errands",
+}
+
+func TestTagsByTodo(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	query := "SELECT g.id, g.user_id, g.name FROM tags g JOIN todo_tags tt ON tt.tag_id = g.id WHERE tt.todo_id = ? ORDER BY g.name"
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name"}).
+		AddRow(tagErrands.ID, tagErrands.UserID, tagErrands.Name).
+		AddRow(tagGarden.ID, tagGarden.UserID, tagGarden.Name)
+	mock.ExpectQuery(query).WithArgs(todo.ID).WillReturnRows(rows)
+
+	got, err := mysqlRepo.TagsByTodo(todo.ID)
+	if err != nil {
+		t.Fatalf("mysqlRepository.TagsByTodo() error = %v", err)
+	}
+	want := []repo.TagRow{*tagErrands, *tagGarden}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlRepository.TagsByTodo() = %v, want %v", got, want)
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "INSERT IGNORE INTO tags(id, user_id, name) VALUES (?, ?, ?)"
+	expectAudited(mock, entityTag, tagGarden.ID, repo.ActionCreate, func() {
+		mock.ExpectExec(statement).WithArgs(tagGarden.ID, tagGarden.UserID, tagGarden.Name).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	})
+	expectUnchanged(mock, entityTag, tagGarden.ID, repo.ActionCreate, func() {
+		mock.ExpectExec(statement).WithArgs(tagGarden.ID, tagGarden.UserID, tagGarden.Name).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	})
+
+	tests := []struct {
+		name    string
+		row     repo.TagRow
+		want    bool
+		wantErr bool
+	}{
+		{"test add new tag should insert", *tagGarden, true, false},
+		{"test add existing tag name should not insert", *tagGarden, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.AddTag(tt.row)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlRepository.AddTag() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.AddTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	statement := "UPDATE tags SET name = ? WHERE id = ?"
+	expectAudited(mock, entityTag, tagGarden.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement).WithArgs("yard", tagGarden.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	expectUnchanged(mock, entityTag, tagGarden.ID, repo.ActionUpdate, func() {
+		mock.ExpectExec(statement).WithArgs(tagErrands.Name, tagGarden.ID).
+			WillReturnError(&mysql.MySQLError{Number: errDuplicateEntry, Message: "Duplicate entry"})
+	})
+
+	tests := []struct {
+		name    string
+		id      string
+		newName string
+		want    bool
+		wantErr bool
+	}{
+		{"test rename tag should update", tagGarden.ID, "yard", true, false},
+		{"test rename tag to an existing name should fail", tagGarden.ID, tagErrands.Name, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlRepo.RenameTag(tt.id, tt.newName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlRepository.RenameTag() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("mysqlRepository.RenameTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddAndRemoveTagFromTodo(t *testing.T) {
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionTag, func() {
+		mock.ExpectExec("INSERT IGNORE INTO todo_tags(todo_id, tag_id) VALUES (?, ?)").WithArgs(todo.ID, tagGarden.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+	expectAudited(mock, entityTodo, todo.ID, repo.ActionUntag, func() {
+		mock.ExpectExec("DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?").WithArgs(todo.ID, tagGarden.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	})
+
+	added, err := mysqlRepo.AddTagToTodo(todo.ID, tagGarden.ID)
+	if err != nil || !added {
+		t.Errorf("mysqlRepository.AddTagToTodo() = %v, %v, want true, nil", added, err)
+	}
+	removed, err := mysqlRepo.RemoveTagFromTodo(todo.ID, tagGarden.ID)
+	if err != nil || !removed {
+		t.Errorf("mysqlRepository.RemoveTagFromTodo() = %v, %v, want true, nil", removed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTodosByUserAndTags(t *testing.T) {
+	type args struct {
+		userId string
+		tags   []string
+	}
+
+	db, mock := NewMock()
+	mysqlRepo := &mysqlRepository{db: db}
+
+	defer func() {
+		mysqlRepo.Close()
+	}()
+
+	columns := []string{"id", "text", "done", "userId", "created_at", "completed_at", "list_id", "parent_id", "due_at", "priority", "recurrence", "position"}
+
+	query := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos WHERE " + visibleTo + " AND id IN (" +
+		"SELECT tt.todo_id FROM todo_tags tt JOIN tags g ON g.id = tt.tag_id " +
+		"WHERE g.name IN (?, ?) " +
+		"GROUP BY tt.todo_id HAVING COUNT(DISTINCT g.id) = ?) ORDER BY position, id"
+	mock.ExpectQuery(query).WithArgs(todo.UserID, todo.UserID, todo.UserID, tagGarden.Name, tagErrands.Name, 2).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil))
+
+	untagged := "SELECT id, text, done, user_id, created_at, completed_at, list_id, parent_id, due_at, priority, recurrence, position FROM todos WHERE " + visibleTo + " ORDER BY position, id"
+	mock.ExpectQuery(untagged).WithArgs(todo.UserID, todo.UserID, todo.UserID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(todo.ID, todo.Text, todo.Done, todo.UserID, todo.CreatedAt, todo.CompletedAt, nil, nil, nil, 0, nil, nil).
+			AddRow(todoBySameUser.ID, todoBySameUser.Text, todoBySameUser.Done, todoBySameUser.UserID,
+				todoBySameUser.CreatedAt, todoBySameUser.CompletedAt, nil, nil, nil, 0, nil, nil))
+
+	tests := []struct {
+		name    string
+		db      *sql.DB
+		args    args
+		want    []repo.TodoRow
+		wantErr bool
+	}{
+		{"test todos filtered by tags should return tagged rows",
+			db,
+			args{todo.UserID, []string{tagGarden.Name, tagErrands.Name}},
+			[]repo.TodoRow{*todo},
+			false,
+		},
+		{"test todos without tag filter should return all rows",
+			db,
+			args{todo.UserID, nil},
+			[]repo.TodoRow{*todo, *todoBySameUser},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &mysqlRepository{
+				db: tt.db,
+			}
+			got, err := r.TodosByUserAndTags(tt.args.userId, tt.args.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlRepository.TodosByUserAndTags() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mysqlRepository.TodosByUserAndTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+type mysqlWebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository returns the webhook repository of the database r is
+// connected to. r must have been created by NewRepository.
+func NewWebhookRepository(r repo.Repository) (repo.WebhookRepository, error) {
+	mr, ok := r.(*mysqlRepository)
+	if !ok {
+		return nil, fmt.Errorf("NewWebhookRepository %T is not a MySQL repository", r)
+	}
+	return &mysqlWebhookRepository{mr.db}, nil
+}
+
+const webhookColumns = "id, user_id, url, event_types, secret, created_at"
+
+func scanWebhook(s scanner, webhook *repo.WebhookRow) error {
+	var eventTypes string
+	if err := s.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.CreatedAt); err != nil {
+		return err
+	}
+	webhook.EventTypes = strings.Split(eventTypes, ",")
+	return nil
+}
+
+func (r *mysqlWebhookRepository) WebhookByID(id string) (repo.WebhookRow, error) {
+	var webhook repo.WebhookRow
+	if err := scanWebhook(r.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id), &webhook); err != nil {
+		if err == sql.ErrNoRows {
+			return webhook, fmt.Errorf("WebhookByID row scan: no row. %q %v", id, err)
+		}
+		return webhook, fmt.Errorf("WebhookByID row scan: %q %v", id, err)
+	}
+	return webhook, nil
+}
+
+func (r *mysqlWebhookRepository) WebhooksByUser(userId string) ([]repo.WebhookRow, error) {
+	var webhooks []repo.WebhookRow
+
+	rows, err := r.db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? ORDER BY created_at, id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("WebhooksByUser query %q: %v", userId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook repo.WebhookRow
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("WebhooksByUser scan row %q: %v", userId, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("WebhooksByUser rows err %q: %v", userId, err)
+	}
+
+	return webhooks, nil
+}
+
+func (r *mysqlWebhookRepository) AddWebhook(row repo.WebhookRow) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO webhooks(id, user_id, url, event_types, secret, created_at) VALUES (?, ?, ?, ?, ?, now())",
+		row.ID, row.UserID, row.URL, strings.Join(row.EventTypes, ","), row.Secret)
+	if err != nil {
+		return false, fmt.Errorf("AddWebhook exec : %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("AddWebhook fetch row after insertion : %v", err)
+	}
+	return inserted > 0, nil
+}
+
+// DeleteWebhook deletes a webhook along with its deliveries.
+func (r *mysqlWebhookRepository) DeleteWebhook(id string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook exec : %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("DeleteWebhook fetch row after delete : %v", err)
+	}
+	return deleted > 0, nil
+}
+
+func (r *mysqlWebhookRepository) QueueWebhookDeliveries(eventIds []int64) (int64, error) {
+	if len(eventIds) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, 0, len(eventIds)+1)
+	args = append(args, repo.DeliveryPending)
+	for _, id := range eventIds {
+		args = append(args, id)
+	}
+	result, err := r.db.Exec("INSERT IGNORE INTO webhook_deliveries(webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at) "+
+		"SELECT w.id, o.id, o.event_type, o.payload, ?, now(6), now(6) FROM outbox o "+
+		"JOIN webhooks w ON w.user_id = o.owner_id AND FIND_IN_SET(o.event_type, w.event_types) "+
+		"WHERE o.id IN ("+placeholders(len(eventIds))+") ORDER BY o.id, w.id", args...)
+	if err != nil {
+		return 0, fmt.Errorf("QueueWebhookDeliveries exec : %v", err)
+	}
+
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("QueueWebhookDeliveries fetch row after insertion : %v", err)
+	}
+	return queued, nil
+}
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, " +
+	"d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret"
+
+func scanDelivery(s scanner, delivery *repo.WebhookDeliveryRow) error {
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var lastStatusCode sql.NullInt64
+	var lastError sql.NullString
+	if err := s.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &nextAttemptAt, &lastStatusCode, &lastError, &delivery.CreatedAt, &deliveredAt,
+		&delivery.URL, &delivery.Secret); err != nil {
+		return err
+	}
+	delivery.NextAttemptAt = nextAttemptAt.Time
+	delivery.LastStatusCode = int(lastStatusCode.Int64)
+	delivery.LastError = lastError.String
+	delivery.DeliveredAt = deliveredAt.Time
+	return nil
+}
+
+func (r *mysqlWebhookRepository) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]repo.WebhookDeliveryRow, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ClaimDueWebhookDeliveries begin : %v", err)
+	}
+	defer tx.Rollback()
+
+	due, err := r.deliveries(tx, "ClaimDueWebhookDeliveries", "WHERE d.status = ? AND d.next_attempt_at <= ? "+
+		"ORDER BY d.next_attempt_at, d.id LIMIT ? FOR UPDATE OF d SKIP LOCKED", repo.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	claimedUntil := now.Add(lease)
+	args := make([]interface{}, 0, len(due)+1)
+	args = append(args, claimedUntil)
+	for i := range due {
+		due[i].NextAttemptAt = claimedUntil
+		args = append(args, due[i].ID)
+	}
+	if _, err := tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN ("+placeholders(len(due))+")", args...); err != nil {
+		return nil, fmt.Errorf("ClaimDueWebhookDeliveries exec : %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ClaimDueWebhookDeliveries commit : %v", err)
+	}
+	return due, nil
+}
+
+func (r *mysqlWebhookRepository) WebhookDeliveries(webhookId string, status repo.DeliveryStatus, limit int, offset int) ([]repo.WebhookDeliveryRow, error) {
+	if status == "" {
+		return r.deliveries(r.db, "WebhookDeliveries", "WHERE d.webhook_id = ? ORDER BY d.id DESC LIMIT ? OFFSET ?", webhookId, limit, offset)
+	}
+	return r.deliveries(r.db, "WebhookDeliveries", "WHERE d.webhook_id = ? AND d.status = ? ORDER BY d.id DESC LIMIT ? OFFSET ?",
+		webhookId, status, limit, offset)
+}
+
+func (r *mysqlWebhookRepository) deliveries(q querier, op string, where string, args ...interface{}) ([]repo.WebhookDeliveryRow, error) {
+	var deliveries []repo.WebhookDeliveryRow
+
+	rows, err := q.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s query : %v", op, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery repo.WebhookDeliveryRow
+		if err := scanDelivery(rows, &delivery); err != nil {
+			return nil, fmt.Errorf("%s scan row : %v", op, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s rows err : %v", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *mysqlWebhookRepository) RecordWebhookAttempt(row repo.WebhookDeliveryRow) (bool, error) {
+	var lastStatusCode sql.NullInt64
+	if row.LastStatusCode != 0 {
+		lastStatusCode = sql.NullInt64{Int64: int64(row.LastStatusCode), Valid: true}
+	}
+	result, err := r.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, "+
+		"delivered_at = ? WHERE id = ?",
+		row.Status, row.Attempts, nullTime(row.NextAttemptAt), lastStatusCode, nullString(row.LastError), nullTime(row.DeliveredAt), row.ID)
+	if err != nil {
+		return false, fmt.Errorf("RecordWebhookAttempt exec : %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("RecordWebhookAttempt fetch row after update : %v", err)
+	}
+	return updated > 0, nil
+}
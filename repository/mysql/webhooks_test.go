@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	repo "github.com/chloexu/hackernews/repository"
+)
+
+const webhookID = "caajol287d5nswebhk00"
+
+func TestQueueWebhookDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	webhooks := &mysqlWebhookRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	mock.ExpectExec("INSERT IGNORE INTO webhook_deliveries(webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at) "+
+		"SELECT w.id, o.id, o.event_type, o.payload, ?, now(6), now(6) FROM outbox o "+
+		"JOIN webhooks w ON w.user_id = o.owner_id AND FIND_IN_SET(o.event_type, w.event_types) "+
+		"WHERE o.id IN (?, ?) ORDER BY o.id, w.id").
+		WithArgs(repo.DeliveryPending, 7, 8).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	queued, err := webhooks.QueueWebhookDeliveries([]int64{7, 8})
+	if err != nil || queued != 1 {
+		t.Errorf("mysqlWebhookRepository.QueueWebhookDeliveries() = %v, %v, want 1, nil", queued, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestClaimDueWebhookDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	webhooks := &mysqlWebhookRepository{db: db}
+
+	defer func() {
+		db.Close()
+	}()
+
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	payload := []byte(`{"action":"complete"}`)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, "+
+		"d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret "+
+		"FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id "+
+		"WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ? FOR UPDATE OF d SKIP LOCKED").
+		WithArgs(repo.DeliveryPending, now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts", "next_attempt_at",
+			"last_status_code", "last_error", "created_at", "delivered_at", "url", "secret"}).
+			AddRow(3, webhookID, 7, "todo.complete", payload, "pending", 1, now, 503, "status 503 Service Unavailable", now, nil,
+				"https://hooks.example.com/todos", "s3cret"))
+	mock.ExpectExec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (?)").
+		WithArgs(now.Add(15*time.Minute), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	got, err := webhooks.ClaimDueWebhookDeliveries(now, 15*time.Minute, 10)
+	if err != nil {
+		t.Fatalf("mysqlWebhookRepository.ClaimDueWebhookDeliveries() error = %v", err)
+	}
+	want := []repo.WebhookDeliveryRow{{ID: 3, WebhookID: webhookID, EventID: 7, EventType: "todo.complete", Payload: payload,
+		Status: repo.DeliveryPending, Attempts: 1, NextAttemptAt: now.Add(15 * time.Minute), LastStatusCode: 503, LastError: "status 503 Service Unavailable",
+		CreatedAt: now, URL: "https://hooks.example.com/todos", Secret: "s3cret"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysqlWebhookRepository.ClaimDueWebhookDeliveries() = %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package repository
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type TodoRow struct {
 	ID          string
@@ -9,12 +12,483 @@ type TodoRow struct {
 	UserID      string
 	CreatedAt   time.Time
 	CompletedAt time.Time
+	ListID      string
+	ParentID    string
+	DueAt       time.Time
+	Priority    Priority
+	// Recurrence is the RFC 5545 rule, with its DTSTART, of a repeating todo.
+	Recurrence string
+	// Position is the fractional ordering key of the todo among the todos of
+	// its user, see package rank.
+	Position string
+}
+
+// Priority is stored as a small integer so that todos can be sorted by it.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+type TagRow struct {
+	ID     string
+	UserID string
+	Name   string
+}
+
+type TodoListRow struct {
+	ID        string
+	UserID    string
+	Name      string
+	Archived  bool
+	CreatedAt time.Time
+}
+
+// Role is the access a collaborator has to a todo or list shared with them.
+// Owners have full access without a role.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+)
+
+type CollaboratorRow struct {
+	UserID    string
+	Role      Role
+	CreatedAt time.Time
+}
+
+// AttachmentRow describes a file attached to a todo. Its contents live in a
+// blob store under BlobKey.
+type AttachmentRow struct {
+	ID          string
+	TodoID      string
+	Filename    string
+	ContentType string
+	Size        int64
+	BlobKey     string
+	CreatedAt   time.Time
+}
+
+// Actions of history entries.
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionMove     = "move"
+	ActionComplete = "complete"
+	ActionTag      = "tag"
+	ActionUntag    = "untag"
+	ActionShare    = "share"
+	ActionUnshare  = "unshare"
+	ActionAttach   = "attach"
+	// ActionUndo and ActionRedo revert and reapply the changes of an
+	// operation. Their entries belong to the operation they replay.
+	ActionUndo = "undo"
+	ActionRedo = "redo"
+)
+
+// HistoryRow is an entry of the audit log: one change to a todo, list or tag.
+type HistoryRow struct {
+	ID         int64
+	EntityType string
+	EntityID   string
+	OwnerID    string
+	// ActorID is the user who made the change, or empty when unknown.
+	ActorID string
+	// OperationID groups the changes made by one mutation, or is empty when
+	// the change is not part of an operation that can be undone.
+	OperationID string
+	Action      string
+	Changes     []FieldChange
+	CreatedAt   time.Time
+}
+
+// FieldChange is the value of a field before and after a change. A nil value
+// means the field was not set.
+type FieldChange struct {
+	Field  string
+	Before *string
+	After  *string
+}
+
+type CommentRow struct {
+	ID        string
+	TodoID    string
+	AuthorID  string
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TodoStatsRow sums up the todos of a user over a time window.
+type TodoStatsRow struct {
+	// Created counts the todos created in the window and Done those of them
+	// that are done.
+	Created int
+	Done    int
+	// AvgCompletion is the mean time from creation to completion of the todos
+	// completed in the window, or zero when none were.
+	AvgCompletion time.Duration
+	// Completions counts the todos completed in the window per day, leaving
+	// out days without any.
+	Completions []DayCount
+}
+
+type DayCount struct {
+	Day   time.Time
+	Count int
+}
+
+// TodoPatch is a change applied to many todos at once. Nil fields are left
+// as they are.
+type TodoPatch struct {
+	Done     *bool
+	Priority *Priority
+	// AddTags and RemoveTags are tag names. Added tags that the owner of a
+	// todo does not have yet are created for them.
+	AddTags    []string
+	RemoveTags []string
+}
+
+// TodoFilter selects todos by list, tags and due date. Zero fields match
+// every todo.
+type TodoFilter struct {
+	ListID string
+	// Tags selects the todos carrying every one of the tag names.
+	Tags      []string
+	DueBefore time.Time
+}
+
+// BulkResult is the outcome of a bulk write for one todo. Err tells why the
+// todo was left as it is.
+type BulkResult struct {
+	TodoID string
+	Err    error
+}
+
+// TodoChangeRow is the state of a todo after its latest change, for clients
+// syncing their copies.
+type TodoChangeRow struct {
+	Todo    TodoRow
+	Deleted bool
+	// Version is the id of the latest history entry of the todo.
+	Version int64
+}
+
+// TodoSync is a change a client made to its copy of a todo, applied with
+// SyncTodo. Nil fields are left as they are.
+type TodoSync struct {
+	ID string
+	// BaseVersion is the version of the todo the client changed.
+	BaseVersion int64
+	// Create is the todo to add, with the id ID. BaseVersion is 0 then.
+	Create *TodoRow
+	// Delete deletes the todo and its subtasks.
+	Delete   bool
+	Text     *string
+	Done     *bool
+	Priority *Priority
+	// A zero DueAt clears the due date, and an empty ListID or Recurrence
+	// clears the list or the rule.
+	DueAt      *time.Time
+	ListID     *string
+	Recurrence *string
+	// Next is the next occurrence of the todo, added along with the change,
+	// with the tags of the todo.
+	Next *TodoRow
+}
+
+// EventRow is a change as delivered to other services.
+type EventRow struct {
+	ID int64
+	// Type is the entity type and action, such as "todo.complete".
+	Type       string
+	EntityType string
+	EntityID   string
+	// Payload is the JSON encoded event.
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type WebhookRow struct {
+	ID     string
+	UserID string
+	URL    string
+	// EventTypes are the event types the webhook receives, such as
+	// "todo.complete".
+	EventTypes []string
+	// Secret is the key the payloads are signed with.
+	Secret    string
+	CreatedAt time.Time
+}
+
+// DeliveryStatus is the state of the delivery of an event to a webhook.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that ran out of attempts.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDeliveryRow is the delivery of an event to a webhook, and its log.
+type WebhookDeliveryRow struct {
+	ID        int64
+	WebhookID string
+	EventID   int64
+	EventType string
+	Payload   []byte
+	Status    DeliveryStatus
+	Attempts  int
+	// NextAttemptAt is zero once the delivery is delivered or dead.
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+	// URL and Secret are those of the webhook, for sending the delivery.
+	URL    string
+	Secret string
+}
+
+// ReminderChannel is how a reminder reaches its user.
+type ReminderChannel string
+
+const (
+	ChannelEmail ReminderChannel = "email"
+	// ChannelLog writes reminders to the server log, for development.
+	ChannelLog ReminderChannel = "log"
+)
+
+// ReminderStatus is the state of a reminder.
+type ReminderStatus string
+
+const (
+	ReminderPending ReminderStatus = "pending"
+	ReminderSent    ReminderStatus = "sent"
+	// ReminderFailed is a reminder that ran out of attempts.
+	ReminderFailed ReminderStatus = "failed"
+	// ReminderCancelled is a reminder of a todo that was done or deleted
+	// when it fired.
+	ReminderCancelled ReminderStatus = "cancelled"
+)
+
+type ReminderRow struct {
+	ID      string
+	TodoID  string
+	UserID  string
+	FireAt  time.Time
+	Channel ReminderChannel
+	// Address is where the reminder is sent, an email address for
+	// ChannelEmail.
+	Address  string
+	Status   ReminderStatus
+	Attempts int
+	// NextAttemptAt is when a pending reminder may next be claimed, zero
+	// once it is no longer pending.
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        time.Time
+	// TodoText and TodoDueAt are those of the todo, for writing the
+	// reminder, and TodoOpen tells whether it is neither done nor deleted.
+	TodoText  string
+	TodoDueAt time.Time
+	TodoOpen  bool
+}
+
+// IdempotencyRow is a key a client sent with a mutation, and the result of
+// that mutation to return when the client retries with the same key.
+type IdempotencyRow struct {
+	UserID string
+	Key    string
+	// Operation is the mutation the key was used for.
+	Operation string
+	// Result is the encoded result, nil while the mutation is running.
+	Result []byte
+	// CreatedAt is when the key was reserved for the mutation.
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TodoSearchRow is a todo matching a search, with its relevance score.
+type TodoSearchRow struct {
+	Todo  TodoRow
+	Score float64
 }
 
 type Repository interface {
+	// WithActor returns a repository whose writes are recorded in the history
+	// as made by actorId.
+	WithActor(actorId string) Repository
+	// WithOperation returns a repository whose writes are recorded in the
+	// history as part of the operation operationId, to be undone together.
+	WithOperation(operationId string) Repository
+	// WithContext returns a repository serving the request of ctx, whose
+	// deadline, cancellation and trace its operations follow.
+	WithContext(ctx context.Context) Repository
+	// Primary returns a repository whose reads all go to the primary
+	// database, for reading what was just written. Other repositories may
+	// read some rows from replicas that lag behind.
+	Primary() Repository
+
 	TodoByID(id string) (TodoRow, error)
 	TodosByUser(userId string) ([]TodoRow, error)
+	TodosByUserAndTags(userId string, tags []string) ([]TodoRow, error)
 	AddTodo(row TodoRow) (bool, error)
 	UpdateTodo(row TodoRow) (bool, error)
+	// TodosByIDs returns the todos with the given ids, leaving out those that
+	// do not exist.
+	TodosByIDs(ids []string) ([]TodoRow, error)
+	// UpdateTodos applies a patch to the todos that userId may edit, in one
+	// transaction. There is a result per id, in the same order.
+	UpdateTodos(userId string, ids []string, patch TodoPatch) ([]BulkResult, error)
+	// CompleteAll completes the open todos visible to userId that match the
+	// filter, in one transaction, with a result per matching todo.
+	CompleteAll(userId string, filter TodoFilter) ([]BulkResult, error)
+	// DeleteTodo deletes a todo and its subtasks. They are kept, hidden, so
+	// that the deletion can be undone.
+	DeleteTodo(id string) (bool, error)
+	TagByID(id string) (TagRow, error)
+	TagByName(userId string, name string) (TagRow, error)
+	TagsByTodo(todoId string) ([]TagRow, error)
+	AddTag(row TagRow) (bool, error)
+	RenameTag(id string, name string) (bool, error)
+	AddTagToTodo(todoId string, tagId string) (bool, error)
+	RemoveTagFromTodo(todoId string, tagId string) (bool, error)
+	TodoListByID(id string) (TodoListRow, error)
+	TodoListsByUser(userId string, includeArchived bool) ([]TodoListRow, error)
+	TodosByList(listId string) ([]TodoRow, error)
+	AddTodoList(row TodoListRow) (bool, error)
+	UpdateTodoList(row TodoListRow) (bool, error)
+	DeleteTodoList(id string) (bool, error)
+	MoveTodoToList(todoId string, listId string) (bool, error)
+	TodosByParent(parentId string) ([]TodoRow, error)
+	ChildProgress(parentId string) (completed int, total int, err error)
+	SetTodoParent(todoId string, parentId string) (bool, error)
+	CompleteDescendants(todoId string) (int64, error)
+	SetTodoDueAt(todoId string, dueAt time.Time) (bool, error)
+	SetTodoPriority(todoId string, priority Priority) (bool, error)
+	SetTodoRecurrence(todoId string, recurrence string) (bool, error)
+	OverdueTodos(userId string, now time.Time) ([]TodoRow, error)
+	TodosDueBetween(userId string, from time.Time, to time.Time) ([]TodoRow, error)
+	LastTodoPosition(userId string) (string, error)
+	TodoPositionBefore(userId string, position string) (string, error)
+	TodoPositionAfter(userId string, position string) (string, error)
+	SetTodoPosition(todoId string, position string) (bool, error)
+
+	SearchTodos(userId string, query string, limit int, offset int) ([]TodoSearchRow, error)
+	// TodoStats aggregates the todos visible to the user over [from, to).
+	TodoStats(userId string, from time.Time, to time.Time) (TodoStatsRow, error)
+
+	AttachmentsByTodo(todoId string) ([]AttachmentRow, error)
+	AddAttachment(row AttachmentRow) (bool, error)
+
+	ShareTodo(todoId string, userId string, role Role) (bool, error)
+	UnshareTodo(todoId string, userId string) (bool, error)
+	TodoCollaborators(todoId string) ([]CollaboratorRow, error)
+	// TodoRole returns the role of a collaborator on a todo, through a share
+	// of the todo or of its list, or "" when it is not shared with them.
+	TodoRole(todoId string, userId string) (Role, error)
+	ShareTodoList(listId string, userId string, role Role) (bool, error)
+	UnshareTodoList(listId string, userId string) (bool, error)
+	TodoListCollaborators(listId string) ([]CollaboratorRow, error)
+	TodoListRole(listId string, userId string) (Role, error)
+
+	TodoHistory(todoId string, limit int, offset int) ([]HistoryRow, error)
+	ActivityByUser(userId string, limit int, offset int) ([]HistoryRow, error)
+	// OperationHistory returns the entries of an operation, oldest first.
+	OperationHistory(operationId string) ([]HistoryRow, error)
+	// UndoOperation reverts the changes of an operation and RedoOperation
+	// applies them again. Both refuse when a todo the operation changed has
+	// been changed since, and return false when there is no such operation.
+	UndoOperation(operationId string) (bool, error)
+	RedoOperation(operationId string) (bool, error)
+	// TodoVersion returns the id of the latest history entry of a todo, or 0
+	// when it has none.
+	TodoVersion(todoId string) (int64, error)
+	// TodoChangesSince returns the todos accessible to userId, deleted ones
+	// included, changed after the version since, in the order of their
+	// versions.
+	TodoChangesSince(userId string, since int64, limit int) ([]TodoChangeRow, error)
+	// SyncTodo applies a change of a sync when the todo is still at its base
+	// version, or does not exist for a create, checking that and writing in
+	// one transaction. Otherwise it returns false with the current version.
+	SyncTodo(change TodoSync) (applied bool, version int64, err error)
+
+	// ReserveIdempotencyKey claims a key for a mutation about to run, after
+	// dropping the expired keys of the user. A key reserved longer than lease
+	// ago without a result belongs to a mutation that never finished, and is
+	// claimed again. It returns false when the key is taken already.
+	ReserveIdempotencyKey(row IdempotencyRow, lease time.Duration) (bool, error)
+	IdempotencyKey(userId string, key string) (IdempotencyRow, error)
+	SaveIdempotencyResult(userId string, key string, result []byte) (bool, error)
+	// ReleaseIdempotencyKey gives up a key without a result, after the
+	// mutation failed, so that the client can retry it.
+	ReleaseIdempotencyKey(userId string, key string) (bool, error)
 	Close()
 }
+
+// CommentRepository stores the discussion on todos.
+type CommentRepository interface {
+	CommentByID(id string) (CommentRow, error)
+	CommentsByTodo(todoId string, limit int, offset int) ([]CommentRow, error)
+	AddComment(row CommentRow) (bool, error)
+	UpdateComment(row CommentRow) (bool, error)
+	DeleteComment(id string) (bool, error)
+}
+
+// OutboxRepository reads the events written along with every change, for
+// delivery to other services.
+type OutboxRepository interface {
+	// UnsentEvents returns the oldest events that are not marked sent, in
+	// the order they were written. It stops before events written after one
+	// that may not be committed yet, so that none is relayed before an
+	// earlier one.
+	UnsentEvents(limit int) ([]EventRow, error)
+	MarkEventsSent(ids []int64) (int64, error)
+}
+
+type WebhookRepository interface {
+	WebhookByID(id string) (WebhookRow, error)
+	WebhooksByUser(userId string) ([]WebhookRow, error)
+	AddWebhook(row WebhookRow) (bool, error)
+	DeleteWebhook(id string) (bool, error)
+	// QueueWebhookDeliveries creates a pending delivery of each of the given
+	// outbox events to every webhook of the owner of its entity that
+	// receives its type. Events queued before are skipped.
+	QueueWebhookDeliveries(eventIds []int64) (int64, error)
+	// ClaimDueWebhookDeliveries claims up to limit pending deliveries whose
+	// next attempt is due at now, oldest first, for lease, so that no other
+	// dispatcher claims them until then. Deliveries that another dispatcher
+	// is claiming at the same time are skipped.
+	ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]WebhookDeliveryRow, error)
+	// RecordWebhookAttempt stores the outcome of an attempt to send a
+	// delivery: its status, attempts, next attempt and last response.
+	RecordWebhookAttempt(row WebhookDeliveryRow) (bool, error)
+	// WebhookDeliveries returns a page of the deliveries of a webhook,
+	// newest first, of any status when status is "".
+	WebhookDeliveries(webhookId string, status DeliveryStatus, limit int, offset int) ([]WebhookDeliveryRow, error)
+}
+
+type ReminderRepository interface {
+	ReminderByID(id string) (ReminderRow, error)
+	// RemindersByUser returns the reminders of a user, by the time they
+	// fire.
+	RemindersByUser(userId string) ([]ReminderRow, error)
+	AddReminder(row ReminderRow) (bool, error)
+	DeleteReminder(id string) (bool, error)
+	// ClaimDueReminders claims up to limit pending reminders due at now for
+	// lease, so that no other scheduler claims them until then. Reminders
+	// that another scheduler is claiming at the same time are skipped.
+	ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]ReminderRow, error)
+	// RecordReminderAttempt stores the outcome of an attempt to send a
+	// reminder: its status, attempts, next attempt and last error.
+	RecordReminderAttempt(row ReminderRow) (bool, error)
+}
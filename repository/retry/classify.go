@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Class tells whether, and how, an operation that failed may be retried.
+type Class int
+
+const (
+	// Permanent errors, such as missing rows or constraint violations, fail
+	// the same way again and are returned as they are.
+	Permanent Class = iota
+	// RolledBack errors undid the whole statement or transaction, so any
+	// operation may be retried: deadlocks and lock wait timeouts.
+	RolledBack
+	// Unavailable errors are those of a database that could not be reached
+	// or whose connection broke. A write may have been applied before the
+	// connection broke, so only reads are retried. They count towards
+	// tripping the circuit breaker.
+	Unavailable
+)
+
+func (c Class) String() string {
+	switch c {
+	case RolledBack:
+		return "rolled back"
+	case Unavailable:
+		return "unavailable"
+	}
+	return "permanent"
+}
+
+// MySQL error numbers of transient failures.
+const (
+	errLockWaitTimeout   = 1205
+	errLockDeadlock      = 1213
+	errTooManyConnection = 1040
+	errServerShutdown    = 1053
+	errCannotConnect     = 2002
+	errConnectionFailed  = 2003
+	errServerGone        = 2006
+	errServerLost        = 2013
+)
+
+var rolledBackNumbers = map[uint16]bool{errLockWaitTimeout: true, errLockDeadlock: true}
+
+var unavailableNumbers = map[uint16]bool{
+	errTooManyConnection: true, errServerShutdown: true, errCannotConnect: true,
+	errConnectionFailed: true, errServerGone: true, errServerLost: true,
+}
+
+// unavailableMessages are parts of the messages of network and driver
+// errors of a broken connection.
+var unavailableMessages = []string{
+	mysql.ErrInvalidConn.Error(),
+	driver.ErrBadConn.Error(),
+	"connection reset by peer",
+	"connection refused",
+	"broken pipe",
+	"i/o timeout",
+	"unexpected EOF",
+}
+
+// ClassifyMySQL classifies the errors of repository/mysql. They wrap the
+// driver errors with %v, so when the *mysql.MySQLError is not in the chain
+// its number is read from the message, which the driver formats as
+// "Error 1213: ...".
+func ClassifyMySQL(err error) Class {
+	if err == nil {
+		return Permanent
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return classifyNumber(mysqlErr.Number)
+	}
+	message := err.Error()
+	for number := range rolledBackNumbers {
+		if strings.Contains(message, fmt.Sprintf("Error %d:", number)) {
+			return RolledBack
+		}
+	}
+	for number := range unavailableNumbers {
+		if strings.Contains(message, fmt.Sprintf("Error %d:", number)) {
+			return Unavailable
+		}
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return Unavailable
+	}
+	for _, part := range unavailableMessages {
+		if strings.Contains(message, part) {
+			return Unavailable
+		}
+	}
+	return Permanent
+}
+
+func classifyNumber(number uint16) Class {
+	switch {
+	case rolledBackNumbers[number]:
+		return RolledBack
+	case unavailableNumbers[number]:
+		return Unavailable
+	}
+	return Permanent
+}
@@ -0,0 +1,494 @@
+// Code generated by decorate -template retry. DO NOT EDIT.
+
+package retry
+
+import (
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// The operations of the wrapped repository, each retried as a read or a
+// write.
+
+var _ repository.Repository = (*Repository)(nil)
+
+func (r *Repository) TodoByID(id string) (value repository.TodoRow, err error) {
+	err = r.read("TodoByID", func() (err error) {
+		value, err = r.next.TodoByID(id)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosByUser(userId string) (value []repository.TodoRow, err error) {
+	err = r.read("TodosByUser", func() (err error) {
+		value, err = r.next.TodosByUser(userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosByUserAndTags(userId string, tags []string) (value []repository.TodoRow, err error) {
+	err = r.read("TodosByUserAndTags", func() (err error) {
+		value, err = r.next.TodosByUserAndTags(userId, tags)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AddTodo(row repository.TodoRow) (value bool, err error) {
+	err = r.write("AddTodo", func() (err error) {
+		value, err = r.next.AddTodo(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UpdateTodo(row repository.TodoRow) (value bool, err error) {
+	err = r.write("UpdateTodo", func() (err error) {
+		value, err = r.next.UpdateTodo(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosByIDs(ids []string) (value []repository.TodoRow, err error) {
+	err = r.read("TodosByIDs", func() (err error) {
+		value, err = r.next.TodosByIDs(ids)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UpdateTodos(userId string, ids []string, patch repository.TodoPatch) (value []repository.BulkResult, err error) {
+	err = r.write("UpdateTodos", func() (err error) {
+		value, err = r.next.UpdateTodos(userId, ids, patch)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) CompleteAll(userId string, filter repository.TodoFilter) (value []repository.BulkResult, err error) {
+	err = r.write("CompleteAll", func() (err error) {
+		value, err = r.next.CompleteAll(userId, filter)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) DeleteTodo(id string) (value bool, err error) {
+	err = r.write("DeleteTodo", func() (err error) {
+		value, err = r.next.DeleteTodo(id)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TagByID(id string) (value repository.TagRow, err error) {
+	err = r.read("TagByID", func() (err error) {
+		value, err = r.next.TagByID(id)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TagByName(userId string, name string) (value repository.TagRow, err error) {
+	err = r.read("TagByName", func() (err error) {
+		value, err = r.next.TagByName(userId, name)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TagsByTodo(todoId string) (value []repository.TagRow, err error) {
+	err = r.read("TagsByTodo", func() (err error) {
+		value, err = r.next.TagsByTodo(todoId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AddTag(row repository.TagRow) (value bool, err error) {
+	err = r.write("AddTag", func() (err error) {
+		value, err = r.next.AddTag(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) RenameTag(id string, name string) (value bool, err error) {
+	err = r.write("RenameTag", func() (err error) {
+		value, err = r.next.RenameTag(id, name)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AddTagToTodo(todoId string, tagId string) (value bool, err error) {
+	err = r.write("AddTagToTodo", func() (err error) {
+		value, err = r.next.AddTagToTodo(todoId, tagId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) RemoveTagFromTodo(todoId string, tagId string) (value bool, err error) {
+	err = r.write("RemoveTagFromTodo", func() (err error) {
+		value, err = r.next.RemoveTagFromTodo(todoId, tagId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoListByID(id string) (value repository.TodoListRow, err error) {
+	err = r.read("TodoListByID", func() (err error) {
+		value, err = r.next.TodoListByID(id)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoListsByUser(userId string, includeArchived bool) (value []repository.TodoListRow, err error) {
+	err = r.read("TodoListsByUser", func() (err error) {
+		value, err = r.next.TodoListsByUser(userId, includeArchived)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosByList(listId string) (value []repository.TodoRow, err error) {
+	err = r.read("TodosByList", func() (err error) {
+		value, err = r.next.TodosByList(listId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AddTodoList(row repository.TodoListRow) (value bool, err error) {
+	err = r.write("AddTodoList", func() (err error) {
+		value, err = r.next.AddTodoList(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UpdateTodoList(row repository.TodoListRow) (value bool, err error) {
+	err = r.write("UpdateTodoList", func() (err error) {
+		value, err = r.next.UpdateTodoList(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) DeleteTodoList(id string) (value bool, err error) {
+	err = r.write("DeleteTodoList", func() (err error) {
+		value, err = r.next.DeleteTodoList(id)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) MoveTodoToList(todoId string, listId string) (value bool, err error) {
+	err = r.write("MoveTodoToList", func() (err error) {
+		value, err = r.next.MoveTodoToList(todoId, listId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosByParent(parentId string) (value []repository.TodoRow, err error) {
+	err = r.read("TodosByParent", func() (err error) {
+		value, err = r.next.TodosByParent(parentId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) ChildProgress(parentId string) (completed int, total int, err error) {
+	err = r.read("ChildProgress", func() (err error) {
+		completed, total, err = r.next.ChildProgress(parentId)
+		return err
+	})
+	return completed, total, err
+}
+
+func (r *Repository) SetTodoParent(todoId string, parentId string) (value bool, err error) {
+	err = r.write("SetTodoParent", func() (err error) {
+		value, err = r.next.SetTodoParent(todoId, parentId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) CompleteDescendants(todoId string) (value int64, err error) {
+	err = r.write("CompleteDescendants", func() (err error) {
+		value, err = r.next.CompleteDescendants(todoId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SetTodoDueAt(todoId string, dueAt time.Time) (value bool, err error) {
+	err = r.write("SetTodoDueAt", func() (err error) {
+		value, err = r.next.SetTodoDueAt(todoId, dueAt)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SetTodoPriority(todoId string, priority repository.Priority) (value bool, err error) {
+	err = r.write("SetTodoPriority", func() (err error) {
+		value, err = r.next.SetTodoPriority(todoId, priority)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SetTodoRecurrence(todoId string, recurrence string) (value bool, err error) {
+	err = r.write("SetTodoRecurrence", func() (err error) {
+		value, err = r.next.SetTodoRecurrence(todoId, recurrence)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) OverdueTodos(userId string, now time.Time) (value []repository.TodoRow, err error) {
+	err = r.read("OverdueTodos", func() (err error) {
+		value, err = r.next.OverdueTodos(userId, now)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodosDueBetween(userId string, from time.Time, to time.Time) (value []repository.TodoRow, err error) {
+	err = r.read("TodosDueBetween", func() (err error) {
+		value, err = r.next.TodosDueBetween(userId, from, to)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) LastTodoPosition(userId string) (value string, err error) {
+	err = r.read("LastTodoPosition", func() (err error) {
+		value, err = r.next.LastTodoPosition(userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoPositionBefore(userId string, position string) (value string, err error) {
+	err = r.read("TodoPositionBefore", func() (err error) {
+		value, err = r.next.TodoPositionBefore(userId, position)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoPositionAfter(userId string, position string) (value string, err error) {
+	err = r.read("TodoPositionAfter", func() (err error) {
+		value, err = r.next.TodoPositionAfter(userId, position)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SetTodoPosition(todoId string, position string) (value bool, err error) {
+	err = r.write("SetTodoPosition", func() (err error) {
+		value, err = r.next.SetTodoPosition(todoId, position)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SearchTodos(userId string, query string, limit int, offset int) (value []repository.TodoSearchRow, err error) {
+	err = r.read("SearchTodos", func() (err error) {
+		value, err = r.next.SearchTodos(userId, query, limit, offset)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoStats(userId string, from time.Time, to time.Time) (value repository.TodoStatsRow, err error) {
+	err = r.read("TodoStats", func() (err error) {
+		value, err = r.next.TodoStats(userId, from, to)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AttachmentsByTodo(todoId string) (value []repository.AttachmentRow, err error) {
+	err = r.read("AttachmentsByTodo", func() (err error) {
+		value, err = r.next.AttachmentsByTodo(todoId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) AddAttachment(row repository.AttachmentRow) (value bool, err error) {
+	err = r.write("AddAttachment", func() (err error) {
+		value, err = r.next.AddAttachment(row)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) ShareTodo(todoId string, userId string, role repository.Role) (value bool, err error) {
+	err = r.write("ShareTodo", func() (err error) {
+		value, err = r.next.ShareTodo(todoId, userId, role)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UnshareTodo(todoId string, userId string) (value bool, err error) {
+	err = r.write("UnshareTodo", func() (err error) {
+		value, err = r.next.UnshareTodo(todoId, userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoCollaborators(todoId string) (value []repository.CollaboratorRow, err error) {
+	err = r.read("TodoCollaborators", func() (err error) {
+		value, err = r.next.TodoCollaborators(todoId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoRole(todoId string, userId string) (value repository.Role, err error) {
+	err = r.read("TodoRole", func() (err error) {
+		value, err = r.next.TodoRole(todoId, userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) ShareTodoList(listId string, userId string, role repository.Role) (value bool, err error) {
+	err = r.write("ShareTodoList", func() (err error) {
+		value, err = r.next.ShareTodoList(listId, userId, role)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UnshareTodoList(listId string, userId string) (value bool, err error) {
+	err = r.write("UnshareTodoList", func() (err error) {
+		value, err = r.next.UnshareTodoList(listId, userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoListCollaborators(listId string) (value []repository.CollaboratorRow, err error) {
+	err = r.read("TodoListCollaborators", func() (err error) {
+		value, err = r.next.TodoListCollaborators(listId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoListRole(listId string, userId string) (value repository.Role, err error) {
+	err = r.read("TodoListRole", func() (err error) {
+		value, err = r.next.TodoListRole(listId, userId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoHistory(todoId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	err = r.read("TodoHistory", func() (err error) {
+		value, err = r.next.TodoHistory(todoId, limit, offset)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) ActivityByUser(userId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	err = r.read("ActivityByUser", func() (err error) {
+		value, err = r.next.ActivityByUser(userId, limit, offset)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) OperationHistory(operationId string) (value []repository.HistoryRow, err error) {
+	err = r.read("OperationHistory", func() (err error) {
+		value, err = r.next.OperationHistory(operationId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) UndoOperation(operationId string) (value bool, err error) {
+	err = r.write("UndoOperation", func() (err error) {
+		value, err = r.next.UndoOperation(operationId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) RedoOperation(operationId string) (value bool, err error) {
+	err = r.write("RedoOperation", func() (err error) {
+		value, err = r.next.RedoOperation(operationId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoVersion(todoId string) (value int64, err error) {
+	err = r.read("TodoVersion", func() (err error) {
+		value, err = r.next.TodoVersion(todoId)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) TodoChangesSince(userId string, since int64, limit int) (value []repository.TodoChangeRow, err error) {
+	err = r.read("TodoChangesSince", func() (err error) {
+		value, err = r.next.TodoChangesSince(userId, since, limit)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SyncTodo(change repository.TodoSync) (applied bool, version int64, err error) {
+	err = r.write("SyncTodo", func() (err error) {
+		applied, version, err = r.next.SyncTodo(change)
+		return err
+	})
+	return applied, version, err
+}
+
+func (r *Repository) ReserveIdempotencyKey(row repository.IdempotencyRow, lease time.Duration) (value bool, err error) {
+	err = r.write("ReserveIdempotencyKey", func() (err error) {
+		value, err = r.next.ReserveIdempotencyKey(row, lease)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) IdempotencyKey(userId string, key string) (value repository.IdempotencyRow, err error) {
+	err = r.read("IdempotencyKey", func() (err error) {
+		value, err = r.next.IdempotencyKey(userId, key)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) SaveIdempotencyResult(userId string, key string, result []byte) (value bool, err error) {
+	err = r.write("SaveIdempotencyResult", func() (err error) {
+		value, err = r.next.SaveIdempotencyResult(userId, key, result)
+		return err
+	})
+	return value, err
+}
+
+func (r *Repository) ReleaseIdempotencyKey(userId string, key string) (value bool, err error) {
+	err = r.write("ReleaseIdempotencyKey", func() (err error) {
+		value, err = r.next.ReleaseIdempotencyKey(userId, key)
+		return err
+	})
+	return value, err
+}
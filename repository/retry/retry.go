@@ -0,0 +1,183 @@
+// Package retry decorates a repository to ride out transient database
+// failures: operations failing with deadlocks, lock wait timeouts or broken
+// connections are retried with jittered exponential backoff, and a circuit
+// breaker fails them fast while the database stays unreachable.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+//go:generate go run github.com/chloexu/hackernews/repository/decorate -template retry -interface ../repository.go
+
+// ErrUnavailable is returned, wrapped, while the circuit breaker is open.
+var ErrUnavailable = errors.New("database unavailable")
+
+// Policy configures retries and the circuit breaker.
+type Policy struct {
+	// Attempts is how often an operation is tried in all.
+	Attempts int
+	// BaseDelay is the most the first retry waits. Each retry may wait up to
+	// twice as long as the one before, up to MaxDelay, and waits a random
+	// part of that so that retrying callers spread out.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Threshold is how many operations in a row must fail with Unavailable
+	// errors, after their retries, to open the circuit breaker, which then
+	// fails operations fast for Cooldown.
+	Threshold int
+	Cooldown  time.Duration
+	// Classify classifies the errors of the wrapped repository.
+	Classify func(error) Class
+}
+
+// DefaultPolicy suits repository/mysql.
+var DefaultPolicy = Policy{
+	Attempts:  3,
+	BaseDelay: 50 * time.Millisecond,
+	MaxDelay:  time.Second,
+	Threshold: 5,
+	Cooldown:  10 * time.Second,
+	Classify:  ClassifyMySQL,
+}
+
+// Repository retries the operations of the repository it wraps. Reads are
+// retried after any transient error. Writes are only retried after errors
+// that rolled them back, as those of repository/mysql run in transactions;
+// after a broken connection they may have been applied.
+type Repository struct {
+	next    repository.Repository
+	policy  Policy
+	breaker *breaker
+	// wait is sleep, except in tests.
+	wait func(ctx context.Context, d time.Duration) error
+	mu   *sync.Mutex
+	rand *rand.Rand
+	// ctx is the context of the request operations are made for, if any.
+	ctx context.Context
+}
+
+func New(next repository.Repository, policy Policy) *Repository {
+	return &Repository{
+		next:    next,
+		policy:  policy,
+		breaker: newBreaker(policy.Threshold, policy.Cooldown),
+		wait:    sleep,
+		mu:      &sync.Mutex{},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Open reports whether the circuit breaker is open, failing operations fast.
+func (r *Repository) Open() bool {
+	return r.breaker.open()
+}
+
+// sleep waits for d, or until ctx is done, returning the error of ctx then.
+// A nil ctx is never done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// derive returns a repository wrapping next that shares the retries and the
+// circuit breaker of r.
+func (r *Repository) derive(next repository.Repository) *Repository {
+	derived := *r
+	derived.next = next
+	return &derived
+}
+
+// backoff returns the wait before retry number retry, from 1.
+func (r *Repository) backoff(retry int) time.Duration {
+	limit := r.policy.BaseDelay
+	for i := 1; i < retry && limit < r.policy.MaxDelay; i++ {
+		limit *= 2
+	}
+	if limit > r.policy.MaxDelay {
+		limit = r.policy.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.rand.Int63n(int64(limit) + 1))
+}
+
+// do runs op, retrying it while it fails with errors of the classes retried
+// and the request it is made for is not done.
+func (r *Repository) do(name string, read bool, op func() error) error {
+	if !r.breaker.allow() {
+		return fmt.Errorf("%s: %w", name, ErrUnavailable)
+	}
+	var err error
+	class := Permanent
+	for attempt := 1; ; attempt++ {
+		err = op()
+		class = Permanent
+		if err != nil {
+			class = r.policy.Classify(err)
+		}
+		retried := class == RolledBack || class == Unavailable && read
+		if !retried || attempt >= r.policy.Attempts {
+			break
+		}
+		wait := r.backoff(attempt)
+		logging.FromContext(r.ctx).Warn().Err(err).Str("method", name).Int("attempt", attempt).Dur("wait", wait).
+			Msg("retrying repository call")
+		if r.wait(r.ctx, wait) != nil {
+			// the request is gone, so is whoever would see the retry succeed
+			break
+		}
+	}
+	r.breaker.record(class == Unavailable)
+	return err
+}
+
+func (r *Repository) read(name string, op func() error) error {
+	return r.do(name, true, op)
+}
+
+func (r *Repository) write(name string, op func() error) error {
+	return r.do(name, false, op)
+}
+
+func (r *Repository) WithActor(actorId string) repository.Repository {
+	return r.derive(r.next.WithActor(actorId))
+}
+
+func (r *Repository) WithOperation(operationId string) repository.Repository {
+	return r.derive(r.next.WithOperation(operationId))
+}
+
+func (r *Repository) WithContext(ctx context.Context) repository.Repository {
+	derived := r.derive(r.next.WithContext(ctx))
+	derived.ctx = ctx
+	return derived
+}
+
+func (r *Repository) Primary() repository.Repository {
+	return r.derive(r.next.Primary())
+}
+
+func (r *Repository) Close() {
+	r.next.Close()
+}
@@ -0,0 +1,241 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+)
+
+// failingRepository fails its operations with the errors queued in errs, in
+// turn, then succeeds.
+type failingRepository struct {
+	repository.Repository
+	errs  []error
+	calls int
+}
+
+func (f *failingRepository) result() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *failingRepository) TodoByID(id string) (repository.TodoRow, error) {
+	return repository.TodoRow{ID: id}, f.result()
+}
+
+func (f *failingRepository) AddTodo(row repository.TodoRow) (bool, error) {
+	return true, f.result()
+}
+
+func (f *failingRepository) WithActor(actorId string) repository.Repository {
+	return f
+}
+
+func (f *failingRepository) WithContext(ctx context.Context) repository.Repository {
+	return f
+}
+
+var (
+	deadlock   = fmt.Errorf("AddTodo exec : %v", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"})
+	connReset  = fmt.Errorf("TodoByID row scan: %q %v", "id", "read tcp 127.0.0.1:50712->127.0.0.1:3306: read: connection reset by peer")
+	notFound   = fmt.Errorf("TodoByID row scan: no row. %q %v", "id", "sql: no rows in result set")
+	testPolicy = Policy{Attempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Threshold: 2, Cooldown: time.Minute, Classify: ClassifyMySQL}
+)
+
+// newTestRepository returns a repository that does not sleep, and records
+// the waits it would have slept.
+func newTestRepository(next repository.Repository) (*Repository, *[]time.Duration) {
+	r := New(next, testPolicy)
+	var waits []time.Duration
+	r.wait = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return r, &waits
+}
+
+func TestClassifyMySQL(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"test driver deadlock should be rolled back", &mysql.MySQLError{Number: 1213}, RolledBack},
+		{"test wrapped deadlock should be rolled back", deadlock, RolledBack},
+		{"test lock wait timeout should be rolled back", errors.New("UpdateTodo exec : Error 1205: Lock wait timeout exceeded"), RolledBack},
+		{"test connection reset should be unavailable", connReset, Unavailable},
+		{"test invalid connection should be unavailable", fmt.Errorf("AddTag exec : %v", mysql.ErrInvalidConn), Unavailable},
+		{"test server gone should be unavailable", &mysql.MySQLError{Number: 2006, Message: "MySQL server has gone away"}, Unavailable},
+		{"test missing row should be permanent", notFound, Permanent},
+		{"test duplicate key should be permanent", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, Permanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyMySQL(tt.err); got != tt.want {
+				t.Errorf("ClassifyMySQL(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadsRetryTransientErrors(t *testing.T) {
+	next := &failingRepository{errs: []error{connReset, deadlock}}
+	r, waits := newTestRepository(next)
+
+	if _, err := r.TodoByID("id"); err != nil {
+		t.Fatalf("Repository.TodoByID() error = %v", err)
+	}
+	if next.calls != 3 {
+		t.Errorf("wrapped repository called %d times, want 3", next.calls)
+	}
+	if len(*waits) != 2 || (*waits)[0] > 10*time.Millisecond || (*waits)[1] > 20*time.Millisecond {
+		t.Errorf("Repository waited %v, want two jittered waits of at most 10ms and 20ms", *waits)
+	}
+}
+
+func TestRetriesAreLoggedWithTheRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Str("request_id", "req-1").Logger()
+	next := &failingRepository{errs: []error{connReset}}
+	r, _ := newTestRepository(next)
+
+	if _, err := r.WithContext(logger.WithContext(context.Background())).TodoByID("id"); err != nil {
+		t.Fatalf("Repository.TodoByID() error = %v", err)
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, `"request_id":"req-1"`) || !strings.Contains(logged, `"method":"TodoByID"`) {
+		t.Errorf("retry logged %q, want it tagged with the request and method", logged)
+	}
+}
+
+func TestReadsGiveUpAfterAttempts(t *testing.T) {
+	next := &failingRepository{errs: []error{connReset, connReset, connReset, connReset}}
+	r, _ := newTestRepository(next)
+
+	if _, err := r.TodoByID("id"); err != connReset {
+		t.Errorf("Repository.TodoByID() error = %v, want %v", err, connReset)
+	}
+	if next.calls != testPolicy.Attempts {
+		t.Errorf("wrapped repository called %d times, want %d", next.calls, testPolicy.Attempts)
+	}
+}
+
+func TestRetriesStopWithTheRequest(t *testing.T) {
+	next := &failingRepository{errs: []error{connReset, connReset}}
+	policy := testPolicy
+	policy.BaseDelay, policy.MaxDelay = time.Hour, time.Hour
+	r := New(next, policy)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if _, err := r.WithContext(ctx).TodoByID("id"); err != connReset {
+		t.Errorf("Repository.TodoByID() error = %v, want %v", err, connReset)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Repository.TodoByID() took %v after the request was cancelled", elapsed)
+	}
+	if next.calls != 1 {
+		t.Errorf("wrapped repository called %d times, want 1", next.calls)
+	}
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	next := &failingRepository{errs: []error{notFound}}
+	r, _ := newTestRepository(next)
+
+	if _, err := r.TodoByID("id"); err != notFound {
+		t.Errorf("Repository.TodoByID() error = %v, want %v", err, notFound)
+	}
+	if next.calls != 1 {
+		t.Errorf("wrapped repository called %d times, want 1", next.calls)
+	}
+}
+
+func TestWritesRetryOnlyRolledBackErrors(t *testing.T) {
+	next := &failingRepository{errs: []error{deadlock}}
+	r, _ := newTestRepository(next)
+	if _, err := r.WithActor("user").AddTodo(repository.TodoRow{ID: "id"}); err != nil || next.calls != 2 {
+		t.Errorf("Repository.AddTodo() after a deadlock = %v with %d calls, want it retried", err, next.calls)
+	}
+
+	// the insert may have been applied before the connection broke
+	next = &failingRepository{errs: []error{connReset}}
+	r, _ = newTestRepository(next)
+	if _, err := r.AddTodo(repository.TodoRow{ID: "id"}); err != connReset || next.calls != 1 {
+		t.Errorf("Repository.AddTodo() after a connection reset = %v with %d calls, want it not retried", err, next.calls)
+	}
+}
+
+func TestBreakerFailsFast(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	unreachable := make([]error, 2*testPolicy.Attempts)
+	for i := range unreachable {
+		unreachable[i] = connReset
+	}
+	next := &failingRepository{errs: unreachable}
+	r, _ := newTestRepository(next)
+	r.breaker.now = func() time.Time { return now }
+
+	// Threshold operations failing every attempt open the breaker
+	for i := 0; i < testPolicy.Threshold; i++ {
+		if _, err := r.TodoByID("id"); err != connReset {
+			t.Fatalf("Repository.TodoByID() error = %v, want %v", err, connReset)
+		}
+	}
+	if !r.Open() {
+		t.Fatal("Repository.Open() = false after repeated failures")
+	}
+	calls := next.calls
+	if _, err := r.TodoByID("id"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Repository.TodoByID() with the breaker open error = %v, want ErrUnavailable", err)
+	}
+	if next.calls != calls {
+		t.Error("Repository.TodoByID() with the breaker open reached the wrapped repository")
+	}
+
+	// after the cooldown, an operation is let through and closes the
+	// breaker when it succeeds
+	now = now.Add(testPolicy.Cooldown)
+	if _, err := r.TodoByID("id"); err != nil {
+		t.Errorf("Repository.TodoByID() after the cooldown error = %v", err)
+	}
+	if r.Open() {
+		t.Error("Repository.Open() = true after a successful operation")
+	}
+}
+
+func TestBreakerReopensWhenProbeFails(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.record(true)
+	}
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("breaker.allow() = false after the cooldown")
+	}
+	if b.allow() {
+		t.Error("breaker.allow() = true while a probe runs")
+	}
+	b.record(true)
+	if b.allow() || !b.open() {
+		t.Error("breaker let operations through after a failed probe")
+	}
+}
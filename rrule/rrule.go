@@ -0,0 +1,581 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// repeating todos. A rule is anchored at its DTSTART and supports the FREQ,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST parts with
+// DAILY, WEEKLY, MONTHLY and YEARLY frequencies.
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+func (f Frequency) String() string {
+	return frequencyNames[f]
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// WeekdayNum is one BYDAY entry. N selects the Nth such weekday of the month
+// or year, counting from the end when negative; zero selects every one.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Day]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Day]
+}
+
+type Rule struct {
+	// Start is the DTSTART of the rule. Occurrences keep its time of day
+	// and location, and none fall before it.
+	Start      time.Time
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// maxEmptyPeriods bounds the search for rules that can never produce
+// another occurrence, such as every 30th of February.
+const maxEmptyPeriods = 2000
+
+// Parse reads a rule written as a bare "FREQ=..." value, an "RRULE:" line, or
+// a "DTSTART:" line followed by an "RRULE:" line.
+func Parse(s string) (Rule, error) {
+	var rule Rule
+	var value string
+	for _, line := range strings.Split(strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "DTSTART"):
+			start, err := parseDTStart(line)
+			if err != nil {
+				return rule, err
+			}
+			rule.Start = start
+		case strings.HasPrefix(line, "RRULE:"):
+			value = strings.TrimPrefix(line, "RRULE:")
+		default:
+			value = line
+		}
+	}
+	if value == "" {
+		return rule, fmt.Errorf("rrule: missing RRULE")
+	}
+	if err := rule.parseParts(value); err != nil {
+		return rule, err
+	}
+	return rule, rule.validate()
+}
+
+func parseDTStart(line string) (time.Time, error) {
+	sep := strings.LastIndex(line, ":")
+	if sep < 0 {
+		return time.Time{}, fmt.Errorf("rrule: malformed %q", line)
+	}
+	loc := time.UTC
+	for _, param := range strings.Split(line[:sep], ";")[1:] {
+		if strings.HasPrefix(param, "TZID=") {
+			l, err := time.LoadLocation(strings.TrimPrefix(param, "TZID="))
+			if err != nil {
+				return time.Time{}, fmt.Errorf("rrule: unknown time zone in %q: %v", line, err)
+			}
+			loc = l
+		}
+	}
+	return parseTime(line[sep+1:], loc)
+}
+
+// parseTime reads a DATE or DATE-TIME value. Times ending in Z are UTC and
+// others are read in loc.
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.ParseInLocation(dateTimeLayout, strings.TrimSuffix(value, "Z"), time.UTC)
+	}
+	if len(value) == len(dateLayout) {
+		return time.ParseInLocation(dateLayout, value, loc)
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	if err != nil {
+		return t, fmt.Errorf("rrule: invalid time %q", value)
+	}
+	return t, nil
+}
+
+func (r *Rule) parseParts(value string) error {
+	r.Interval = 1
+	r.WeekStart = time.Monday
+	seen := make(map[string]bool)
+	hasFreq := false
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return fmt.Errorf("rrule: malformed part %q", part)
+		}
+		name, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[name] {
+			return fmt.Errorf("rrule: %s given more than once", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			hasFreq = true
+			r.Freq, err = parseFrequency(val)
+		case "INTERVAL":
+			r.Interval, err = parsePositive(name, val)
+		case "COUNT":
+			r.Count, err = parsePositive(name, val)
+		case "UNTIL":
+			loc := time.UTC
+			if !r.Start.IsZero() {
+				loc = r.Start.Location()
+			}
+			r.Until, err = parseTime(val, loc)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(name, val, 1, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseIntList(name, val, 1, 12)
+			for _, m := range months {
+				if m < 0 {
+					return fmt.Errorf("rrule: BYMONTH value %d out of range", m)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			r.WeekStart, err = parseWeekday(val)
+		default:
+			return fmt.Errorf("rrule: unsupported part %s", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !hasFreq {
+		return fmt.Errorf("rrule: missing FREQ")
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("rrule: COUNT and UNTIL must not both be set")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return fmt.Errorf("rrule: BYDAY %s needs FREQ=MONTHLY or FREQ=YEARLY", wd)
+		}
+		// ordinals count within the month unless a yearly rule has no BYMONTH
+		if (r.Freq == Monthly || len(r.ByMonth) > 0) && abs(wd.N) > 5 {
+			return fmt.Errorf("rrule: BYDAY %s out of range within a month", wd)
+		}
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("rrule: BYMONTHDAY must not be used with FREQ=WEEKLY")
+	}
+	return nil
+}
+
+func parseFrequency(val string) (Frequency, error) {
+	for freq, name := range frequencyNames {
+		if name == val {
+			return freq, nil
+		}
+	}
+	return 0, fmt.Errorf("rrule: unsupported FREQ %s", val)
+}
+
+func parsePositive(name string, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("rrule: %s must be a positive integer, got %q", name, val)
+	}
+	return n, nil
+}
+
+// parseIntList reads a comma separated list of values within [min, max] or
+// [-max, -min].
+func parseIntList(name string, val string, min int, max int) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("rrule: invalid %s value %q", name, item)
+		}
+		if abs(n) < min || abs(n) > max {
+			return nil, fmt.Errorf("rrule: %s value %d out of range", name, n)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func parseByDay(val string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(val, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("rrule: invalid BYDAY value %q", item)
+		}
+		day, err := parseWeekday(item[len(item)-2:])
+		if err != nil {
+			return nil, err
+		}
+		wd := WeekdayNum{Day: day}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || abs(n) > 53 {
+				return nil, fmt.Errorf("rrule: invalid BYDAY value %q", item)
+			}
+			wd.N = n
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func parseWeekday(val string) (time.Weekday, error) {
+	for day, name := range weekdayNames {
+		if name == val {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("rrule: invalid weekday %q", val)
+}
+
+// RRule formats the rule parts without DTSTART, e.g. "FREQ=WEEKLY;BYDAY=MO".
+func (r Rule) RRule() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(dateTimeLayout)+"Z")
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// String formats the rule as it is stored: a DTSTART line, when the rule has
+// a start, followed by the RRULE line. Parse reads it back.
+func (r Rule) String() string {
+	if r.Start.IsZero() {
+		return "RRULE:" + r.RRule()
+	}
+	start := "DTSTART:" + r.Start.UTC().Format(dateTimeLayout) + "Z"
+	if loc := r.Start.Location(); loc != time.UTC && loc != time.Local {
+		start = "DTSTART;TZID=" + loc.String() + ":" + r.Start.Format(dateTimeLayout)
+	}
+	return start + "\nRRULE:" + r.RRule()
+}
+
+// Next returns the first occurrence strictly after t. It reports false when
+// the rule has no occurrence left.
+func (r Rule) Next(t time.Time) (time.Time, bool) {
+	occurrences := r.Occurrences(t, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	return occurrences[0], true
+}
+
+// Occurrences returns up to limit occurrences strictly after t, in order.
+func (r Rule) Occurrences(t time.Time, limit int) []time.Time {
+	var occurrences []time.Time
+	if limit <= 0 {
+		return occurrences
+	}
+	r.iterate(func(occurrence time.Time) bool {
+		if occurrence.After(t) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < limit
+	})
+	return occurrences
+}
+
+// iterate calls fn with every occurrence of the rule in order, starting at
+// Start, until fn returns false or the rule is exhausted. COUNT is counted
+// from Start, so occurrences are always generated from the beginning.
+func (r Rule) iterate(fn func(time.Time) bool) {
+	if r.Start.IsZero() {
+		return
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	count, empty := 0, 0
+	for period := 0; empty < maxEmptyPeriods; period++ {
+		candidates, periodStart := r.expand(period * interval)
+		if !r.Until.IsZero() && periodStart.After(r.Until) {
+			return
+		}
+		produced := false
+		for _, candidate := range candidates {
+			if candidate.Before(r.Start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return
+			}
+			produced = true
+			count++
+			if !fn(candidate) || (r.Count > 0 && count >= r.Count) {
+				return
+			}
+		}
+		if produced {
+			empty = 0
+		} else {
+			empty++
+		}
+	}
+}
+
+// expand returns the sorted candidates of the period that lies offset
+// frequency units after the one containing Start, along with the start of
+// that period.
+func (r Rule) expand(offset int) ([]time.Time, time.Time) {
+	s := r.Start
+	year, month, day := s.Date()
+	switch r.Freq {
+	case Daily:
+		d := r.at(year, month, day+offset)
+		if r.matchesMonth(d) && r.matchesMonthDay(d) && r.matchesWeekday(d) {
+			return []time.Time{d}, d
+		}
+		return nil, d
+	case Weekly:
+		back := (int(s.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := r.at(year, month, day-back+7*offset)
+		var candidates []time.Time
+		for i := 0; i < 7; i++ {
+			d := weekStart.AddDate(0, 0, i)
+			if len(r.ByDay) > 0 && !r.matchesWeekday(d) || len(r.ByDay) == 0 && d.Weekday() != s.Weekday() {
+				continue
+			}
+			if r.matchesMonth(d) {
+				candidates = append(candidates, d)
+			}
+		}
+		return candidates, weekStart
+	case Monthly:
+		first := r.at(year, month+time.Month(offset), 1)
+		if !r.matchesMonth(first) {
+			return nil, first
+		}
+		return r.expandMonth(first), first
+	default:
+		first := r.at(year+offset, time.January, 1)
+		if len(r.ByMonth) > 0 {
+			var candidates []time.Time
+			for _, m := range sortedMonths(r.ByMonth) {
+				candidates = append(candidates, r.expandMonth(r.at(first.Year(), m, 1))...)
+			}
+			return candidates, first
+		}
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			if d := r.at(first.Year(), month, day); d.Day() == day {
+				return []time.Time{d}, first
+			}
+			return nil, first
+		}
+		return r.expandRange(first, daysInYear(first.Year())), first
+	}
+}
+
+// expandMonth returns the candidates of the month starting at first.
+func (r Rule) expandMonth(first time.Time) []time.Time {
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		day := r.Start.Day()
+		if day > daysIn(first.Year(), first.Month()) {
+			return nil
+		}
+		return []time.Time{first.AddDate(0, 0, day-1)}
+	}
+	return r.expandRange(first, daysIn(first.Year(), first.Month()))
+}
+
+// expandRange returns the days among the n days from first that BYDAY and
+// BYMONTHDAY select. BYDAY ordinals count within the range; when both parts
+// are given a day has to match both.
+func (r Rule) expandRange(first time.Time, n int) []time.Time {
+	selected := make([]bool, n)
+	for i := range selected {
+		selected[i] = true
+	}
+	if len(r.ByDay) > 0 {
+		byDay := make([]bool, n)
+		for _, wd := range r.ByDay {
+			var matches []int
+			for i := 0; i < n; i++ {
+				if first.AddDate(0, 0, i).Weekday() == wd.Day {
+					matches = append(matches, i)
+				}
+			}
+			switch {
+			case wd.N == 0:
+				for _, i := range matches {
+					byDay[i] = true
+				}
+			case wd.N > 0 && wd.N <= len(matches):
+				byDay[matches[wd.N-1]] = true
+			case wd.N < 0 && -wd.N <= len(matches):
+				byDay[matches[len(matches)+wd.N]] = true
+			}
+		}
+		for i := range selected {
+			selected[i] = selected[i] && byDay[i]
+		}
+	}
+	var days []time.Time
+	for i, ok := range selected {
+		if !ok {
+			continue
+		}
+		d := first.AddDate(0, 0, i)
+		if r.matchesMonthDay(d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// at builds a time on the given, possibly denormalized, date with the time of
+// day and location of Start.
+func (r Rule) at(year int, month time.Month, day int) time.Time {
+	hour, min, sec := r.Start.Clock()
+	return time.Date(year, month, day, hour, min, sec, r.Start.Nanosecond(), r.Start.Location())
+}
+
+func (r Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if t.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	n := daysIn(t.Year(), t.Month())
+	for _, d := range r.ByMonthDay {
+		if d > 0 && t.Day() == d || d < 0 && t.Day() == n+d+1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if t.Weekday() == wd.Day {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return 337 + daysIn(year, time.February)
+}
+
+func sortedMonths(months []time.Month) []time.Month {
+	sorted := append([]time.Month(nil), months...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package rrule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// date builds a time in UTC at 09:00, the DTSTART time of most RFC 5545
+// examples.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+func mustParse(t *testing.T, s string, start time.Time) Rule {
+	t.Helper()
+	rule, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", s, err)
+	}
+	rule.Start = start
+	return rule
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Rule
+		wantErr bool
+	}{
+		{"bare rule", "FREQ=WEEKLY;BYDAY=MO",
+			Rule{Freq: Weekly, Interval: 1, ByDay: []WeekdayNum{{Day: time.Monday}}, WeekStart: time.Monday}, false},
+		{"rrule line with all parts", "RRULE:FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYMONTH=1,6;BYMONTHDAY=1,-1;BYDAY=-1FR,2MO;WKST=SU",
+			Rule{
+				Freq: Monthly, Interval: 2, Count: 10,
+				ByMonth:    []time.Month{time.January, time.June},
+				ByMonthDay: []int{1, -1},
+				ByDay:      []WeekdayNum{{N: -1, Day: time.Friday}, {N: 2, Day: time.Monday}},
+				WeekStart:  time.Sunday,
+			}, false},
+		{"lower case", "freq=daily;until=20220601T000000Z",
+			Rule{Freq: Daily, Interval: 1, Until: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), WeekStart: time.Monday}, false},
+		{"with dtstart", "DTSTART:20220523T090000Z\nRRULE:FREQ=WEEKLY",
+			Rule{Start: date(2022, 5, 23), Freq: Weekly, Interval: 1, WeekStart: time.Monday}, false},
+		{"with date dtstart", "DTSTART;VALUE=DATE:20220523\r\nRRULE:FREQ=DAILY",
+			Rule{Start: time.Date(2022, 5, 23, 0, 0, 0, 0, time.UTC), Freq: Daily, Interval: 1, WeekStart: time.Monday}, false},
+		{"empty", "", Rule{}, true},
+		{"missing freq", "INTERVAL=2", Rule{}, true},
+		{"unknown freq", "FREQ=HOURLY", Rule{}, true},
+		{"unsupported part", "FREQ=DAILY;BYHOUR=9", Rule{}, true},
+		{"malformed part", "FREQ=DAILY;COUNT", Rule{}, true},
+		{"repeated part", "FREQ=DAILY;FREQ=WEEKLY", Rule{}, true},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0", Rule{}, true},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20220601T000000Z", Rule{}, true},
+		{"bad weekday", "FREQ=WEEKLY;BYDAY=XX", Rule{}, true},
+		{"ordinal in weekly rule", "FREQ=WEEKLY;BYDAY=1MO", Rule{}, true},
+		{"ordinal beyond month", "FREQ=MONTHLY;BYDAY=6MO", Rule{}, true},
+		{"month day out of range", "FREQ=MONTHLY;BYMONTHDAY=32", Rule{}, true},
+		{"month out of range", "FREQ=YEARLY;BYMONTH=13", Rule{}, true},
+		{"month day in weekly rule", "FREQ=WEEKLY;BYMONTHDAY=1", Rule{}, true},
+		{"unknown time zone", "DTSTART;TZID=Nowhere/Land:20220523T090000\nRRULE:FREQ=DAILY", Rule{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	tests := []struct {
+		name  string
+		start time.Time
+		in    string
+		want  string
+	}{
+		{"without start", time.Time{}, "FREQ=WEEKLY;BYDAY=MO",
+			"RRULE:FREQ=WEEKLY;BYDAY=MO"},
+		{"utc start", date(2022, 5, 23), "FREQ=MONTHLY;INTERVAL=2;COUNT=3;BYDAY=-1FR;WKST=SU",
+			"DTSTART:20220523T090000Z\nRRULE:FREQ=MONTHLY;INTERVAL=2;COUNT=3;BYDAY=-1FR;WKST=SU"},
+		{"zoned start", time.Date(2022, 5, 23, 9, 0, 0, 0, paris), "FREQ=YEARLY;UNTIL=20250101T000000Z;BYMONTH=5;BYMONTHDAY=23",
+			"DTSTART;TZID=Europe/Paris:20220523T090000\nRRULE:FREQ=YEARLY;UNTIL=20250101T000000Z;BYMONTH=5;BYMONTHDAY=23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := mustParse(t, tt.in, tt.start)
+			if got := rule.String(); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+			again, err := Parse(rule.String())
+			if err != nil {
+				t.Fatalf("Parse(String()) error = %v", err)
+			}
+			if !again.Start.Equal(rule.Start) || again.RRule() != rule.RRule() {
+				t.Errorf("Parse(String()) = %v, want %v", again, rule)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		limit int
+		want  []time.Time
+	}{
+		{"daily for 10 occurrences", "FREQ=DAILY;COUNT=10", date(1997, 9, 2), 20,
+			[]time.Time{date(1997, 9, 2), date(1997, 9, 3), date(1997, 9, 4), date(1997, 9, 5), date(1997, 9, 6),
+				date(1997, 9, 7), date(1997, 9, 8), date(1997, 9, 9), date(1997, 9, 10), date(1997, 9, 11)}},
+		{"every other day", "FREQ=DAILY;INTERVAL=2", date(1997, 9, 2), 4,
+			[]time.Time{date(1997, 9, 2), date(1997, 9, 4), date(1997, 9, 6), date(1997, 9, 8)}},
+		{"daily until", "FREQ=DAILY;UNTIL=19970905T000000Z", date(1997, 9, 2), 10,
+			[]time.Time{date(1997, 9, 2), date(1997, 9, 3), date(1997, 9, 4)}},
+		{"daily in january only", "FREQ=DAILY;BYMONTH=1", date(1997, 12, 30), 3,
+			[]time.Time{date(1998, 1, 1), date(1998, 1, 2), date(1998, 1, 3)}},
+		{"weekdays", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", date(2022, 5, 20), 3,
+			[]time.Time{date(2022, 5, 20), date(2022, 5, 23), date(2022, 5, 24)}},
+		{"every monday", "FREQ=WEEKLY;BYDAY=MO", date(2022, 5, 23), 3,
+			[]time.Time{date(2022, 5, 23), date(2022, 5, 30), date(2022, 6, 6)}},
+		{"weekly defaults to start weekday", "FREQ=WEEKLY;COUNT=3", date(2022, 5, 25), 10,
+			[]time.Time{date(2022, 5, 25), date(2022, 6, 1), date(2022, 6, 8)}},
+		{"every other week on tuesday and thursday", "FREQ=WEEKLY;INTERVAL=2;WKST=SU;BYDAY=TU,TH;COUNT=8", date(1997, 9, 2), 10,
+			[]time.Time{date(1997, 9, 2), date(1997, 9, 4), date(1997, 9, 16), date(1997, 9, 18),
+				date(1997, 9, 30), date(1997, 10, 2), date(1997, 10, 14), date(1997, 10, 16)}},
+		{"week start monday", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO", date(1997, 8, 5), 10,
+			[]time.Time{date(1997, 8, 5), date(1997, 8, 10), date(1997, 8, 19), date(1997, 8, 24)}},
+		{"week start sunday", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU", date(1997, 8, 5), 10,
+			[]time.Time{date(1997, 8, 5), date(1997, 8, 17), date(1997, 8, 19), date(1997, 8, 31)}},
+		{"second to last monday", "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO", date(1997, 9, 22), 10,
+			[]time.Time{date(1997, 9, 22), date(1997, 10, 20), date(1997, 11, 17), date(1997, 12, 22),
+				date(1998, 1, 19), date(1998, 2, 16)}},
+		{"first and last sunday", "FREQ=MONTHLY;INTERVAL=2;COUNT=4;BYDAY=1SU,-1SU", date(1997, 9, 7), 10,
+			[]time.Time{date(1997, 9, 7), date(1997, 9, 28), date(1997, 11, 2), date(1997, 11, 30)}},
+		{"third to last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-3", date(1997, 9, 28), 6,
+			[]time.Time{date(1997, 9, 28), date(1997, 10, 29), date(1997, 11, 28), date(1997, 12, 29),
+				date(1998, 1, 29), date(1998, 2, 26)}},
+		{"monthly skips short months", "FREQ=MONTHLY;COUNT=3", date(2022, 1, 31), 10,
+			[]time.Time{date(2022, 1, 31), date(2022, 3, 31), date(2022, 5, 31)}},
+		{"friday the 13th", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", date(1997, 9, 2), 5,
+			[]time.Time{date(1998, 2, 13), date(1998, 3, 13), date(1998, 11, 13), date(1999, 8, 13), date(2000, 10, 13)}},
+		{"yearly in june and july", "FREQ=YEARLY;COUNT=4;BYMONTH=6,7", date(1997, 6, 10), 10,
+			[]time.Time{date(1997, 6, 10), date(1997, 7, 10), date(1998, 6, 10), date(1998, 7, 10)}},
+		{"twentieth monday of the year", "FREQ=YEARLY;BYDAY=20MO", date(1997, 5, 19), 3,
+			[]time.Time{date(1997, 5, 19), date(1998, 5, 18), date(1999, 5, 17)}},
+		{"every thursday in march", "FREQ=YEARLY;BYMONTH=3;BYDAY=TH", date(1997, 3, 13), 6,
+			[]time.Time{date(1997, 3, 13), date(1997, 3, 20), date(1997, 3, 27), date(1998, 3, 5),
+				date(1998, 3, 12), date(1998, 3, 19)}},
+		{"leap day", "FREQ=YEARLY;COUNT=3", date(2020, 2, 29), 10,
+			[]time.Time{date(2020, 2, 29), date(2024, 2, 29), date(2028, 2, 29)}},
+		{"first of every month from a yearly rule", "FREQ=YEARLY;BYMONTHDAY=1", date(2022, 11, 1), 3,
+			[]time.Time{date(2022, 11, 1), date(2022, 12, 1), date(2023, 1, 1)}},
+		{"impossible date", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", date(2022, 1, 1), 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := mustParse(t, tt.rule, tt.start)
+			got := rule.Occurrences(tt.start.Add(-time.Second), tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	rule := mustParse(t, "FREQ=WEEKLY;BYDAY=MO;COUNT=3", date(2022, 5, 23))
+	tests := []struct {
+		name   string
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"before start", date(2022, 5, 1), date(2022, 5, 23), true},
+		{"on an occurrence is strictly after", date(2022, 5, 23), date(2022, 5, 30), true},
+		{"between occurrences", date(2022, 6, 1), date(2022, 6, 6), true},
+		{"after the last occurrence", date(2022, 6, 6), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rule.Next(tt.after)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOccurrencesKeepLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	// daylight saving time ends on 2022-11-06; the wall clock time stays put
+	start := time.Date(2022, 11, 5, 9, 0, 0, 0, newYork)
+	rule := mustParse(t, "FREQ=DAILY;COUNT=2", start)
+	got := rule.Occurrences(start.Add(-time.Second), 2)
+	want := []time.Time{start, time.Date(2022, 11, 6, 9, 0, 0, 0, newYork)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Occurrences() = %v, want %v", got, want)
+	}
+	if got[1].Sub(got[0]) != 25*time.Hour {
+		t.Errorf("Occurrences() apart %v, want 25h across the DST change", got[1].Sub(got[0]))
+	}
+}
+
+func TestOccurrencesWithoutStart(t *testing.T) {
+	rule := mustParse(t, "FREQ=DAILY", time.Time{})
+	if got := rule.Occurrences(time.Time{}, 3); len(got) != 0 {
+		t.Errorf("Occurrences() = %v, want none without a start", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package search holds the text handling shared by todo search: splitting
+// queries into terms and cutting highlighted snippets out of matching text.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HighlightStart and HighlightEnd wrap matched words in snippets.
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// Tokenize splits text into lower case words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Snippet returns about width runes of text around the first word matching
+// one of the terms, HTML escaped, with every matching word wrapped in
+// HighlightStart and HighlightEnd. A word matches a term it starts with, so
+// "water" highlights "watering". Cut off text is marked with an ellipsis.
+func Snippet(text string, terms []string, width int) string {
+	words := wordSpans(text)
+	first := -1
+	for i, w := range words {
+		if matches(text[w.start:w.end], terms) {
+			first = i
+			break
+		}
+	}
+
+	start, end := 0, len(text)
+	if utf8.RuneCountInString(text) > width {
+		// center the window on the first match, or show the beginning
+		center := 0
+		if first >= 0 {
+			center = words[first].start
+		}
+		start = backRunes(text, center, width/3)
+		end = forwardRunes(text, start, width)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, w := range words {
+		if w.start < start || w.end > end {
+			continue
+		}
+		if !matches(text[w.start:w.end], terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		b.WriteString(HighlightStart)
+		b.WriteString(html.EscapeString(text[w.start:w.end]))
+		b.WriteString(HighlightEnd)
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+type span struct {
+	start, end int
+}
+
+// wordSpans returns the byte ranges of the words of text, as Tokenize
+// splits them.
+func wordSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		switch {
+		case !isSeparator(r) && start < 0:
+			start = i
+		case isSeparator(r) && start >= 0:
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+func matches(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if term != "" && strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// backRunes moves n runes back from byte offset i.
+func backRunes(text string, i int, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	return i
+}
+
+// forwardRunes moves n runes forward from byte offset i.
+func forwardRunes(text string, i int, n int) int {
+	for ; n > 0 && i < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"words", "Water roses and lilies", []string{"water", "roses", "and", "lilies"}},
+		{"punctuation", "Pick up laundry, then groceries!", []string{"pick", "up", "laundry", "then", "groceries"}},
+		{"digits and unicode", "Call Zoë at 5pm", []string{"call", "zoë", "at", "5pm"}},
+		{"empty", "  ...  ", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.text)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := "Before the trip: call the neighbours, water roses and lilies in the back garden, and leave the key under the mat"
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		width int
+		want  string
+	}{
+		{"short text", "Water roses and lilies", []string{"roses"}, 80,
+			"Water <mark>roses</mark> and lilies"},
+		{"every match and prefixes", "Water roses and lilies, watering can", []string{"water"}, 80,
+			"<mark>Water</mark> roses and lilies, <mark>watering</mark> can"},
+		{"escaped", "Buy <bread> & water", []string{"bread"}, 80,
+			"Buy &lt;<mark>bread</mark>&gt; &amp; water"},
+		{"window around the match", long, []string{"lilies"}, 30,
+			"…roses and <mark>lilies</mark> in the back g…"},
+		{"no match shows the beginning", long, []string{"missing"}, 20,
+			"Before the trip: cal…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.text, tt.terms, tt.width); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
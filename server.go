@@ -1,18 +1,54 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/chloexu/hackernews/blob"
 	"github.com/chloexu/hackernews/graph"
 	"github.com/chloexu/hackernews/graph/generated"
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/metrics"
+	"github.com/chloexu/hackernews/outbox"
+	"github.com/chloexu/hackernews/reminder"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/chloexu/hackernews/repository/cache"
 	"github.com/chloexu/hackernews/repository/mysql"
+	"github.com/chloexu/hackernews/repository/retry"
+	"github.com/chloexu/hackernews/tracing"
+	"github.com/chloexu/hackernews/webhook"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 const defaultPort = "8080"
+const defaultAttachmentDir = "attachments"
+
+// outboxInterval is how often the outbox relay looks for new events.
+const outboxInterval = time.Second
+
+// reminderInterval is how often the reminder scheduler looks for due
+// reminders.
+const reminderInterval = 10 * time.Second
+
+// filesPath is where the signed attachment download links point to.
+const filesPath = "/files/"
 
 func main() {
 	port := os.Getenv("PORT")
@@ -20,16 +56,241 @@ func main() {
 		port = defaultPort
 	}
 
+	setupLogging()
+	flushTraces := setupTracing()
+
 	repo, err := mysql.NewRepository()
 	if err != nil {
-		log.Fatalf("main new repository %v\n", err)
+		log.Fatal().Err(err).Msg("main new repository")
+	}
+	m := metrics.New(metricsOperations()...)
+	pools, err := mysql.Pools(repo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main database pools")
+	}
+	for name, db := range pools {
+		if err := m.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+			log.Fatal().Err(err).Str("pool", name).Msg("main register database pool metrics")
+		}
+	}
+
+	comments, err := mysql.NewCommentRepository(repo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new comment repository")
+	}
+
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = defaultAttachmentDir
+	}
+	blobs, err := blob.NewFileStore(attachmentDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new blob store")
 	}
+	signer := blob.NewSigner(signingSecret(), filesPath)
 
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{Repo: repo}}))
+	webhooks, err := mysql.NewWebhookRepository(repo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new webhook repository")
+	}
+
+	// the relay always queues deliveries to webhooks, and also sends events
+	// to OUTBOX_SINK when it is set
+	events, err := mysql.NewOutboxRepository(repo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new outbox repository")
+	}
+	sinks := outbox.MultiSink{webhook.NewFanout(webhooks)}
+	if spec := os.Getenv("OUTBOX_SINK"); spec != "" {
+		sink, err := outbox.NewSink(spec)
+		if err != nil {
+			log.Fatal().Err(err).Msg("main new outbox sink")
+		}
+		sinks = append(sinks, sink)
+	}
+	go outbox.NewRelay(events, sinks, outboxInterval).Run(context.Background())
+	go webhook.NewDispatcher(webhooks, nil).Run(context.Background(), outboxInterval)
+
+	reminders, err := mysql.NewReminderRepository(repo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new reminder repository")
+	}
+	go reminder.NewScheduler(reminders, notifiers()).Run(context.Background(), reminderInterval)
+
+	// the other repositories need the MySQL one, so it is wrapped last.
+	// Repository calls are timed and traced with their retries, and without
+	// cache hits.
+	repo = cachedRepository(m, m.Repository(tracing.NewRepository(retry.New(repo, retry.DefaultPolicy))))
+
+	resolver := &graph.Resolver{Repo: repo, CommentRepo: comments, WebhookRepo: webhooks, ReminderRepo: reminders, Blobs: blobs, Signer: signer, IdempotencyTTL: idempotencyTTL()}
+	srv := newServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	srv.Use(m.Extension())
+	srv.Use(tracing.Extension{})
+	srv.Use(logging.Extension{})
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	http.Handle("/query", tracing.Propagate(logging.RequestID(graph.IdempotencyKeys(srv))))
+	http.Handle(filesPath, logging.RequestID(blob.NewHandler(blobs, signer)))
+	http.Handle("/metrics", m.Handler())
+
+	log.Info().Msgf("connect to http://localhost:%s/ for GraphQL playground", port)
+	err = http.ListenAndServe(":"+port, nil)
+	flushTraces(context.Background())
+	log.Fatal().Err(err).Msg("main serve")
+}
+
+// setupLogging logs entries of LOG_LEVEL, "info" by default, and above to
+// stderr, as lines of JSON.
+func setupLogging() {
+	level := zerolog.InfoLevel
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		var err error
+		if level, err = zerolog.ParseLevel(value); err != nil || level == zerolog.NoLevel {
+			log.Fatal().Str("value", value).Msg("main invalid LOG_LEVEL")
+		}
+	}
+	logging.Setup(logging.New(os.Stderr, level))
+}
+
+// tracedDriver is the driver name the MySQL driver is registered with when
+// its statements are traced.
+const tracedDriver = "mysql+tracing"
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+// setupTracing exports spans to TRACE_EXPORTER, "stdout" or "otlp-file:"
+// followed by a path, and traces the statements of repositories. Without
+// TRACE_EXPORTER nothing is recorded. It returns the function flushing the
+// spans left.
+func setupTracing() func(context.Context) error {
+	spec := os.Getenv("TRACE_EXPORTER")
+	if spec == "" {
+		return func(context.Context) error { return nil }
+	}
+	exporter, err := tracing.NewExporter(spec)
+	if err != nil {
+		log.Fatal().Err(err).Msg("main new trace exporter")
+	}
+	sql.Register(tracedDriver, tracing.WrapDriver(&mysqldriver.MySQLDriver{}))
+	mysql.DriverName = tracedDriver
+	return tracing.Setup(exporter)
+}
+
+// newServer is handler.NewDefaultServer with multipart uploads limited to the
+// size of one attachment.
+func newServer(es graphql.ExecutableSchema) *handler.Server {
+	srv := handler.New(es)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: graph.MaxAttachmentSize + 1<<20,
+		MaxMemory:     graph.MaxAttachmentSize,
+	})
+
+	srv.SetQueryCache(lru.New(1000))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
+
+	return srv
+}
+
+// notifiers returns the notifier of each reminder channel. EMAIL reminders
+// are mailed through SMTP_ADDR, a host and port, from SMTP_FROM, logging in
+// as SMTP_USERNAME with SMTP_PASSWORD when they are set. Without SMTP_ADDR
+// they are logged like LOG reminders.
+func notifiers() map[repository.ReminderChannel]reminder.Notifier {
+	logged := reminder.NewLogNotifier()
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		log.Warn().Msg("SMTP_ADDR is not set, email reminders will only be logged.")
+		return map[repository.ReminderChannel]reminder.Notifier{repository.ChannelEmail: logged, repository.ChannelLog: logged}
+	}
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			log.Fatal().Err(err).Str("value", addr).Msg("main invalid SMTP_ADDR")
+		}
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	mailed := reminder.NewSMTPNotifier(addr, os.Getenv("SMTP_FROM"), auth)
+	return map[repository.ReminderChannel]reminder.Notifier{repository.ChannelEmail: mailed, repository.ChannelLog: logged}
+}
+
+// signingSecret returns the key for attachment download links. Without
+// ATTACHMENT_SECRET a random key is used, and links stop working when the
+// server restarts.
+func signingSecret() []byte {
+	if secret := os.Getenv("ATTACHMENT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Warn().Msg("ATTACHMENT_SECRET is not set, attachment links will not survive a restart.")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal().Err(err).Msg("main generate signing secret")
+	}
+	return secret
+}
+
+// defaultCacheSize is how many entries the repository cache holds without
+// REPOSITORY_CACHE_SIZE.
+const defaultCacheSize = 10000
+
+// cachedRepository wraps repo with a cache when REPOSITORY_CACHE_TTL is set,
+// and publishes its statistics as metrics of m.
+func cachedRepository(m *metrics.Metrics, repo repository.Repository) repository.Repository {
+	value := os.Getenv("REPOSITORY_CACHE_TTL")
+	if value == "" {
+		return repo
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Fatal().Str("value", value).Msg("main invalid REPOSITORY_CACHE_TTL")
+	}
+	size := defaultCacheSize
+	if value := os.Getenv("REPOSITORY_CACHE_SIZE"); value != "" {
+		size, err = strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			log.Fatal().Str("value", value).Msg("main invalid REPOSITORY_CACHE_SIZE")
+		}
+	}
+	cached := cache.New(repo, size, ttl)
+	if err := m.Register(metrics.Cache(cached)...); err != nil {
+		log.Fatal().Err(err).Msg("main register repository cache metrics")
+	}
+	return cached
+}
+
+// metricsOperations returns the operation names labelling the GraphQL
+// metrics instead of their root fields, from METRICS_OPERATIONS, a comma
+// separated list.
+func metricsOperations() []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("METRICS_OPERATIONS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// idempotencyTTL returns how long idempotency keys are kept, from
+// IDEMPOTENCY_TTL, such as "12h".
+func idempotencyTTL() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_TTL")
+	if value == "" {
+		return graph.DefaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Fatal().Str("value", value).Msg("main invalid IDEMPOTENCY_TTL")
+	}
+	return ttl
 }
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// FileClient is an OTLP client appending each batch of spans to a file, as a
+// line of OTLP JSON, which the OpenTelemetry collector reads with its
+// otlpjsonfile receiver. It needs no network.
+type FileClient struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileClient(path string) (*FileClient, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("NewFileClient open %q: %v", path, err)
+	}
+	return &FileClient{path: path, file: file}, nil
+}
+
+func (c *FileClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *FileClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	if err != nil {
+		return fmt.Errorf("FileClient close %q: %v", c.path, err)
+	}
+	return nil
+}
+
+func (c *FileClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	line, err := marshalOTLP(&tracepb.TracesData{ResourceSpans: spans})
+	if err != nil {
+		return fmt.Errorf("FileClient marshal : %v", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return fmt.Errorf("FileClient %q is stopped", c.path)
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("FileClient write %q: %v", c.path, err)
+	}
+	return nil
+}
+
+// idFields are the fields of OTLP JSON holding trace and span ids.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalOTLP encodes spans as OTLP JSON, which differs from the JSON
+// mapping of protobuf: enums are numbers, and ids are hex rather than
+// base64.
+func marshalOTLP(data *tracepb.TracesData) ([]byte, error) {
+	encoded, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// hexIDs rewrites the base64 ids found in the decoded JSON value v as hex.
+func hexIDs(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if id, ok := value.(string); ok && idFields[key] {
+				raw, err := base64.StdEncoding.DecodeString(id)
+				if err != nil {
+					return fmt.Errorf("%s %q: %v", key, id, err)
+				}
+				v[key] = hex.EncodeToString(raw)
+				continue
+			}
+			if err := hexIDs(value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if err := hexIDs(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
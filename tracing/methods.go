@@ -0,0 +1,373 @@
+// Code generated by decorate -template tracing. DO NOT EDIT.
+
+package tracing
+
+import (
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// The operations of the wrapped repository, each in a span.
+
+var _ repository.Repository = (*Repository)(nil)
+
+func (r *Repository) TodoByID(id string) (value repository.TodoRow, err error) {
+	next, span := r.start("TodoByID")
+	defer end(span, &err)
+	return next.TodoByID(id)
+}
+
+func (r *Repository) TodosByUser(userId string) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosByUser")
+	defer end(span, &err)
+	return next.TodosByUser(userId)
+}
+
+func (r *Repository) TodosByUserAndTags(userId string, tags []string) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosByUserAndTags")
+	defer end(span, &err)
+	return next.TodosByUserAndTags(userId, tags)
+}
+
+func (r *Repository) AddTodo(row repository.TodoRow) (value bool, err error) {
+	next, span := r.start("AddTodo")
+	defer end(span, &err)
+	return next.AddTodo(row)
+}
+
+func (r *Repository) UpdateTodo(row repository.TodoRow) (value bool, err error) {
+	next, span := r.start("UpdateTodo")
+	defer end(span, &err)
+	return next.UpdateTodo(row)
+}
+
+func (r *Repository) TodosByIDs(ids []string) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosByIDs")
+	defer end(span, &err)
+	return next.TodosByIDs(ids)
+}
+
+func (r *Repository) UpdateTodos(userId string, ids []string, patch repository.TodoPatch) (value []repository.BulkResult, err error) {
+	next, span := r.start("UpdateTodos")
+	defer end(span, &err)
+	return next.UpdateTodos(userId, ids, patch)
+}
+
+func (r *Repository) CompleteAll(userId string, filter repository.TodoFilter) (value []repository.BulkResult, err error) {
+	next, span := r.start("CompleteAll")
+	defer end(span, &err)
+	return next.CompleteAll(userId, filter)
+}
+
+func (r *Repository) DeleteTodo(id string) (value bool, err error) {
+	next, span := r.start("DeleteTodo")
+	defer end(span, &err)
+	return next.DeleteTodo(id)
+}
+
+func (r *Repository) TagByID(id string) (value repository.TagRow, err error) {
+	next, span := r.start("TagByID")
+	defer end(span, &err)
+	return next.TagByID(id)
+}
+
+func (r *Repository) TagByName(userId string, name string) (value repository.TagRow, err error) {
+	next, span := r.start("TagByName")
+	defer end(span, &err)
+	return next.TagByName(userId, name)
+}
+
+func (r *Repository) TagsByTodo(todoId string) (value []repository.TagRow, err error) {
+	next, span := r.start("TagsByTodo")
+	defer end(span, &err)
+	return next.TagsByTodo(todoId)
+}
+
+func (r *Repository) AddTag(row repository.TagRow) (value bool, err error) {
+	next, span := r.start("AddTag")
+	defer end(span, &err)
+	return next.AddTag(row)
+}
+
+func (r *Repository) RenameTag(id string, name string) (value bool, err error) {
+	next, span := r.start("RenameTag")
+	defer end(span, &err)
+	return next.RenameTag(id, name)
+}
+
+func (r *Repository) AddTagToTodo(todoId string, tagId string) (value bool, err error) {
+	next, span := r.start("AddTagToTodo")
+	defer end(span, &err)
+	return next.AddTagToTodo(todoId, tagId)
+}
+
+func (r *Repository) RemoveTagFromTodo(todoId string, tagId string) (value bool, err error) {
+	next, span := r.start("RemoveTagFromTodo")
+	defer end(span, &err)
+	return next.RemoveTagFromTodo(todoId, tagId)
+}
+
+func (r *Repository) TodoListByID(id string) (value repository.TodoListRow, err error) {
+	next, span := r.start("TodoListByID")
+	defer end(span, &err)
+	return next.TodoListByID(id)
+}
+
+func (r *Repository) TodoListsByUser(userId string, includeArchived bool) (value []repository.TodoListRow, err error) {
+	next, span := r.start("TodoListsByUser")
+	defer end(span, &err)
+	return next.TodoListsByUser(userId, includeArchived)
+}
+
+func (r *Repository) TodosByList(listId string) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosByList")
+	defer end(span, &err)
+	return next.TodosByList(listId)
+}
+
+func (r *Repository) AddTodoList(row repository.TodoListRow) (value bool, err error) {
+	next, span := r.start("AddTodoList")
+	defer end(span, &err)
+	return next.AddTodoList(row)
+}
+
+func (r *Repository) UpdateTodoList(row repository.TodoListRow) (value bool, err error) {
+	next, span := r.start("UpdateTodoList")
+	defer end(span, &err)
+	return next.UpdateTodoList(row)
+}
+
+func (r *Repository) DeleteTodoList(id string) (value bool, err error) {
+	next, span := r.start("DeleteTodoList")
+	defer end(span, &err)
+	return next.DeleteTodoList(id)
+}
+
+func (r *Repository) MoveTodoToList(todoId string, listId string) (value bool, err error) {
+	next, span := r.start("MoveTodoToList")
+	defer end(span, &err)
+	return next.MoveTodoToList(todoId, listId)
+}
+
+func (r *Repository) TodosByParent(parentId string) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosByParent")
+	defer end(span, &err)
+	return next.TodosByParent(parentId)
+}
+
+func (r *Repository) ChildProgress(parentId string) (completed int, total int, err error) {
+	next, span := r.start("ChildProgress")
+	defer end(span, &err)
+	return next.ChildProgress(parentId)
+}
+
+func (r *Repository) SetTodoParent(todoId string, parentId string) (value bool, err error) {
+	next, span := r.start("SetTodoParent")
+	defer end(span, &err)
+	return next.SetTodoParent(todoId, parentId)
+}
+
+func (r *Repository) CompleteDescendants(todoId string) (value int64, err error) {
+	next, span := r.start("CompleteDescendants")
+	defer end(span, &err)
+	return next.CompleteDescendants(todoId)
+}
+
+func (r *Repository) SetTodoDueAt(todoId string, dueAt time.Time) (value bool, err error) {
+	next, span := r.start("SetTodoDueAt")
+	defer end(span, &err)
+	return next.SetTodoDueAt(todoId, dueAt)
+}
+
+func (r *Repository) SetTodoPriority(todoId string, priority repository.Priority) (value bool, err error) {
+	next, span := r.start("SetTodoPriority")
+	defer end(span, &err)
+	return next.SetTodoPriority(todoId, priority)
+}
+
+func (r *Repository) SetTodoRecurrence(todoId string, recurrence string) (value bool, err error) {
+	next, span := r.start("SetTodoRecurrence")
+	defer end(span, &err)
+	return next.SetTodoRecurrence(todoId, recurrence)
+}
+
+func (r *Repository) OverdueTodos(userId string, now time.Time) (value []repository.TodoRow, err error) {
+	next, span := r.start("OverdueTodos")
+	defer end(span, &err)
+	return next.OverdueTodos(userId, now)
+}
+
+func (r *Repository) TodosDueBetween(userId string, from time.Time, to time.Time) (value []repository.TodoRow, err error) {
+	next, span := r.start("TodosDueBetween")
+	defer end(span, &err)
+	return next.TodosDueBetween(userId, from, to)
+}
+
+func (r *Repository) LastTodoPosition(userId string) (value string, err error) {
+	next, span := r.start("LastTodoPosition")
+	defer end(span, &err)
+	return next.LastTodoPosition(userId)
+}
+
+func (r *Repository) TodoPositionBefore(userId string, position string) (value string, err error) {
+	next, span := r.start("TodoPositionBefore")
+	defer end(span, &err)
+	return next.TodoPositionBefore(userId, position)
+}
+
+func (r *Repository) TodoPositionAfter(userId string, position string) (value string, err error) {
+	next, span := r.start("TodoPositionAfter")
+	defer end(span, &err)
+	return next.TodoPositionAfter(userId, position)
+}
+
+func (r *Repository) SetTodoPosition(todoId string, position string) (value bool, err error) {
+	next, span := r.start("SetTodoPosition")
+	defer end(span, &err)
+	return next.SetTodoPosition(todoId, position)
+}
+
+func (r *Repository) SearchTodos(userId string, query string, limit int, offset int) (value []repository.TodoSearchRow, err error) {
+	next, span := r.start("SearchTodos")
+	defer end(span, &err)
+	return next.SearchTodos(userId, query, limit, offset)
+}
+
+func (r *Repository) TodoStats(userId string, from time.Time, to time.Time) (value repository.TodoStatsRow, err error) {
+	next, span := r.start("TodoStats")
+	defer end(span, &err)
+	return next.TodoStats(userId, from, to)
+}
+
+func (r *Repository) AttachmentsByTodo(todoId string) (value []repository.AttachmentRow, err error) {
+	next, span := r.start("AttachmentsByTodo")
+	defer end(span, &err)
+	return next.AttachmentsByTodo(todoId)
+}
+
+func (r *Repository) AddAttachment(row repository.AttachmentRow) (value bool, err error) {
+	next, span := r.start("AddAttachment")
+	defer end(span, &err)
+	return next.AddAttachment(row)
+}
+
+func (r *Repository) ShareTodo(todoId string, userId string, role repository.Role) (value bool, err error) {
+	next, span := r.start("ShareTodo")
+	defer end(span, &err)
+	return next.ShareTodo(todoId, userId, role)
+}
+
+func (r *Repository) UnshareTodo(todoId string, userId string) (value bool, err error) {
+	next, span := r.start("UnshareTodo")
+	defer end(span, &err)
+	return next.UnshareTodo(todoId, userId)
+}
+
+func (r *Repository) TodoCollaborators(todoId string) (value []repository.CollaboratorRow, err error) {
+	next, span := r.start("TodoCollaborators")
+	defer end(span, &err)
+	return next.TodoCollaborators(todoId)
+}
+
+func (r *Repository) TodoRole(todoId string, userId string) (value repository.Role, err error) {
+	next, span := r.start("TodoRole")
+	defer end(span, &err)
+	return next.TodoRole(todoId, userId)
+}
+
+func (r *Repository) ShareTodoList(listId string, userId string, role repository.Role) (value bool, err error) {
+	next, span := r.start("ShareTodoList")
+	defer end(span, &err)
+	return next.ShareTodoList(listId, userId, role)
+}
+
+func (r *Repository) UnshareTodoList(listId string, userId string) (value bool, err error) {
+	next, span := r.start("UnshareTodoList")
+	defer end(span, &err)
+	return next.UnshareTodoList(listId, userId)
+}
+
+func (r *Repository) TodoListCollaborators(listId string) (value []repository.CollaboratorRow, err error) {
+	next, span := r.start("TodoListCollaborators")
+	defer end(span, &err)
+	return next.TodoListCollaborators(listId)
+}
+
+func (r *Repository) TodoListRole(listId string, userId string) (value repository.Role, err error) {
+	next, span := r.start("TodoListRole")
+	defer end(span, &err)
+	return next.TodoListRole(listId, userId)
+}
+
+func (r *Repository) TodoHistory(todoId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	next, span := r.start("TodoHistory")
+	defer end(span, &err)
+	return next.TodoHistory(todoId, limit, offset)
+}
+
+func (r *Repository) ActivityByUser(userId string, limit int, offset int) (value []repository.HistoryRow, err error) {
+	next, span := r.start("ActivityByUser")
+	defer end(span, &err)
+	return next.ActivityByUser(userId, limit, offset)
+}
+
+func (r *Repository) OperationHistory(operationId string) (value []repository.HistoryRow, err error) {
+	next, span := r.start("OperationHistory")
+	defer end(span, &err)
+	return next.OperationHistory(operationId)
+}
+
+func (r *Repository) UndoOperation(operationId string) (value bool, err error) {
+	next, span := r.start("UndoOperation")
+	defer end(span, &err)
+	return next.UndoOperation(operationId)
+}
+
+func (r *Repository) RedoOperation(operationId string) (value bool, err error) {
+	next, span := r.start("RedoOperation")
+	defer end(span, &err)
+	return next.RedoOperation(operationId)
+}
+
+func (r *Repository) TodoVersion(todoId string) (value int64, err error) {
+	next, span := r.start("TodoVersion")
+	defer end(span, &err)
+	return next.TodoVersion(todoId)
+}
+
+func (r *Repository) TodoChangesSince(userId string, since int64, limit int) (value []repository.TodoChangeRow, err error) {
+	next, span := r.start("TodoChangesSince")
+	defer end(span, &err)
+	return next.TodoChangesSince(userId, since, limit)
+}
+
+func (r *Repository) SyncTodo(change repository.TodoSync) (applied bool, version int64, err error) {
+	next, span := r.start("SyncTodo")
+	defer end(span, &err)
+	return next.SyncTodo(change)
+}
+
+func (r *Repository) ReserveIdempotencyKey(row repository.IdempotencyRow, lease time.Duration) (value bool, err error) {
+	next, span := r.start("ReserveIdempotencyKey")
+	defer end(span, &err)
+	return next.ReserveIdempotencyKey(row, lease)
+}
+
+func (r *Repository) IdempotencyKey(userId string, key string) (value repository.IdempotencyRow, err error) {
+	next, span := r.start("IdempotencyKey")
+	defer end(span, &err)
+	return next.IdempotencyKey(userId, key)
+}
+
+func (r *Repository) SaveIdempotencyResult(userId string, key string, result []byte) (value bool, err error) {
+	next, span := r.start("SaveIdempotencyResult")
+	defer end(span, &err)
+	return next.SaveIdempotencyResult(userId, key, result)
+}
+
+func (r *Repository) ReleaseIdempotencyKey(userId string, key string) (value bool, err error) {
+	next, span := r.start("ReleaseIdempotencyKey")
+	defer end(span, &err)
+	return next.ReleaseIdempotencyKey(userId, key)
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/chloexu/hackernews/repository"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//go:generate go run github.com/chloexu/hackernews/repository/decorate -template tracing -interface ../repository/repository.go
+
+// Repository records a span for each operation of the repository it wraps,
+// in the trace of the context it was given with WithContext. The statements
+// the operation runs become its children.
+type Repository struct {
+	next repository.Repository
+	ctx  context.Context
+}
+
+func NewRepository(next repository.Repository) *Repository {
+	return &Repository{next: next, ctx: context.Background()}
+}
+
+// start starts the span of the operation name, and returns the wrapped
+// repository to run it with.
+func (r *Repository) start(name string) (repository.Repository, trace.Span) {
+	ctx, span := tracer().Start(r.ctx, "repository."+name)
+	return r.next.WithContext(ctx), span
+}
+
+// end ends the span of an operation. It is deferred, so it reads the error
+// the operation returned through err.
+func end(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+func (r *Repository) WithActor(actorId string) repository.Repository {
+	return &Repository{next: r.next.WithActor(actorId), ctx: r.ctx}
+}
+
+func (r *Repository) WithOperation(operationId string) repository.Repository {
+	return &Repository{next: r.next.WithOperation(operationId), ctx: r.ctx}
+}
+
+func (r *Repository) WithContext(ctx context.Context) repository.Repository {
+	return &Repository{next: r.next.WithContext(ctx), ctx: ctx}
+}
+
+func (r *Repository) Primary() repository.Repository {
+	return &Repository{next: r.next.Primary(), ctx: r.ctx}
+}
+
+func (r *Repository) Close() {
+	r.next.Close()
+}
@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WrapDriver wraps a MySQL driver to record a span for each statement run in
+// the context of a trace, with the literals of the statement redacted.
+// Statements of a transaction begun in the context of a trace are recorded
+// in that trace. Statements outside of traces, such as those of background
+// jobs, are not recorded.
+func WrapDriver(d driver.Driver) driver.Driver {
+	return tracedDriver{d}
+}
+
+type tracedDriver struct {
+	driver.Driver
+}
+
+func (d tracedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: c}, nil
+}
+
+// tracedConn records the statements run on a connection.
+type tracedConn struct {
+	driver.Conn
+	// txCtx is the context the current transaction was begun with, whose
+	// trace its statements are recorded in.
+	txCtx context.Context
+}
+
+// record records a statement that ran from start, if it ran in the context
+// of a trace.
+func (c *tracedConn) record(ctx context.Context, query string, start time.Time, err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if c.txCtx == nil || !trace.SpanContextFromContext(c.txCtx).IsValid() {
+			return
+		}
+		ctx = c.txCtx
+	}
+	operation := statementOperation(query)
+	_, span := tracer().Start(ctx, operation, trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperationKey.String(operation), semconv.DBStatementKey.String(Redact(query))))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: s, conn: c, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.txCtx = ctx
+	return &tracedTx{Tx: tx, conn: c}, nil
+}
+
+// ExecContext runs statements the driver runs without preparing them. When
+// it skips them, database/sql prepares them and they are recorded by
+// tracedStmt.
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.record(ctx, query, start, err)
+	}
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.record(ctx, query, start, err)
+	}
+	return rows, err
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	c.txCtx = nil
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type tracedTx struct {
+	driver.Tx
+	conn *tracedConn
+}
+
+func (t *tracedTx) Commit() error {
+	t.conn.txCtx = nil
+	return t.Tx.Commit()
+}
+
+func (t *tracedTx) Rollback() error {
+	t.conn.txCtx = nil
+	return t.Tx.Rollback()
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	conn  *tracedConn
+	query string
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(values(args))
+	}
+	s.conn.record(ctx, s.query, start, err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	s.conn.record(ctx, s.query, start, err)
+	return rows, err
+}
+
+func (s *tracedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return s.conn.CheckNamedValue(value)
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vs[i] = arg.Value
+	}
+	return vs
+}
+
+// statementOperation returns the first keyword of a statement, such as
+// SELECT.
+func statementOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// Redact replaces the string and number literals of a statement with
+// placeholders, so that spans do not record values, even those written into
+// statements rather than passed as parameters. Quoted identifiers are kept.
+func Redact(query string) string {
+	var b strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"':
+			// skip to the closing quote, past escaped and doubled quotes
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteRune('?')
+		case r == '`':
+			b.WriteRune(r)
+			for i++; i < len(runes) && runes[i] != '`'; i++ {
+				b.WriteRune(runes[i])
+			}
+			if i < len(runes) {
+				b.WriteRune('`')
+			}
+		case unicode.IsDigit(r):
+			for i+1 < len(runes) && (isIdentifier(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			b.WriteRune('?')
+		case isIdentifier(r):
+			// copy whole words, so that digits within them are kept
+			for ; i < len(runes) && isIdentifier(runes[i]); i++ {
+				b.WriteRune(runes[i])
+			}
+			i--
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isIdentifier(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,76 @@
+// Package tracing records OpenTelemetry spans of GraphQL operations, of the
+// resolvers they run, of repository calls and of the SQL statements those
+// make, and exports them to stdout or to a file.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer of the package.
+const instrumentation = "github.com/chloexu/hackernews/tracing"
+
+// serviceName is the service.name resource attribute of the spans.
+const serviceName = "todos"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// NewExporter returns the span exporter described by spec: "stdout", or
+// "otlp-file:" followed by a path to append spans to as OTLP JSON lines.
+func NewExporter(spec string) (sdktrace.SpanExporter, error) {
+	switch {
+	case spec == "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case strings.HasPrefix(spec, "otlp-file:"):
+		client, err := NewFileClient(strings.TrimPrefix(spec, "otlp-file:"))
+		if err != nil {
+			return nil, err
+		}
+		return otlptrace.New(context.Background(), client)
+	}
+	return nil, fmt.Errorf("NewExporter unknown exporter %q", spec)
+}
+
+// Setup installs a tracer provider exporting spans in batches to exporter,
+// and the W3C trace context propagator. The returned function flushes the
+// spans left and shuts the provider down.
+func Setup(exporter sdktrace.SpanExporter) func(context.Context) error {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown
+}
+
+// Propagate continues the traces named by the traceparent and tracestate
+// headers of requests, and names the trace of each request in the
+// traceparent header of its response.
+func Propagate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		propagator := otel.GetTextMapPropagator()
+		ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer().Start(ctx, req.Method+" "+req.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethodKey.String(req.Method), semconv.HTTPTargetKey.String(req.URL.Path)))
+		defer span.End()
+		propagator.Inject(ctx, propagation.HeaderCarrier(w.Header()))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
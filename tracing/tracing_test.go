@@ -0,0 +1,252 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chloexu/hackernews/repository"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record installs a tracer provider recording the spans ended until the
+// test ends.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanNamed(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+// memoryRepository runs the statements of TodoByID on db.
+type memoryRepository struct {
+	repository.Repository
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (m *memoryRepository) WithContext(ctx context.Context) repository.Repository {
+	return &memoryRepository{db: m.db, ctx: ctx}
+}
+
+func (m *memoryRepository) TodoByID(id string) (repository.TodoRow, error) {
+	var todo repository.TodoRow
+	err := m.db.QueryRowContext(m.ctx, "SELECT id, text FROM todos WHERE id = ?", id).Scan(&todo.ID, &todo.Text)
+	return todo, err
+}
+
+// newTracedMock returns a database whose statements are traced and checked
+// by the returned mock.
+func newTracedMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	dsn := t.Name()
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error %s was not expected when opening a stub database", err)
+	}
+	sql.Register("traced "+dsn, WrapDriver(mockDB.Driver()))
+	db, err := sql.Open("traced "+dsn, dsn)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		mockDB.Close()
+	})
+	return db, mock
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"test placeholders should be kept", "SELECT id FROM todos WHERE id = ? AND user_id = ?", "SELECT id FROM todos WHERE id = ? AND user_id = ?"},
+		{"test string literals should be redacted", `UPDATE todos SET text = 'it''s \'secret\'' WHERE id = "x1"`, "UPDATE todos SET text = ? WHERE id = ?"},
+		{"test number literals should be redacted", "SELECT id FROM todos WHERE priority > 2 AND score < 0.5 LIMIT 10", "SELECT id FROM todos WHERE priority > ? AND score < ? LIMIT ?"},
+		{"test digits in identifiers should be kept", "SELECT t1.id FROM todos t1 JOIN `list 2` l2 ON l2.id = t1.list_id", "SELECT t1.id FROM todos t1 JOIN `list 2` l2 ON l2.id = t1.list_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.query); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpansNestFromOperationToStatement(t *testing.T) {
+	recorder := record(t)
+	db, mock := newTracedMock(t)
+	mock.ExpectQuery("SELECT id, text FROM todos WHERE id = ?").WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text"}).AddRow("1", "buy milk"))
+	repo := NewRepository(&memoryRepository{db: db, ctx: context.Background()})
+
+	opCtx := &graphql.OperationContext{Operation: &ast.OperationDefinition{Name: "Todo", Operation: ast.Query}}
+	ctx := graphql.WithOperationContext(context.Background(), opCtx)
+	fc := &graphql.FieldContext{Object: "Query", IsResolver: true, Field: graphql.CollectedField{Field: &ast.Field{Name: "todo", Alias: "todo"}}}
+	Extension{}.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+		Extension{}.InterceptField(graphql.WithFieldContext(ctx, fc), func(ctx context.Context) (interface{}, error) {
+			return repo.WithContext(ctx).TodoByID("1")
+		})
+		return &graphql.Response{}
+	})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	spans := recorder.Ended()
+	chain := []string{"query Todo", "Query.todo", "repository.TodoByID", "SELECT"}
+	var parent trace.SpanContext
+	for _, name := range chain {
+		span := spanNamed(spans, name)
+		if span == nil {
+			t.Fatalf("no span %q among %d spans", name, len(spans))
+		}
+		if parent.IsValid() && span.Parent().SpanID() != parent.SpanID() {
+			t.Errorf("span %q is not a child of the span before it in %v", name, chain)
+		}
+		parent = span.SpanContext()
+	}
+	statement := spanNamed(spans, "SELECT")
+	if got := attributeOf(statement, "db.statement"); got != "SELECT id, text FROM todos WHERE id = ?" {
+		t.Errorf("db.statement = %q", got)
+	}
+}
+
+func TestTransactionStatementsJoinTheTraceOfBegin(t *testing.T) {
+	recorder := record(t)
+	db, mock := newTracedMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE todos SET done = 1 WHERE id = ?").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM outbox WHERE id = ?").WithArgs("2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	if _, err := tx.Exec("UPDATE todos SET done = 1 WHERE id = ?", "1"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	span.End()
+	// statements outside of traces are not recorded
+	if _, err := db.Exec("DELETE FROM outbox WHERE id = ?", "2"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the request and its UPDATE", len(spans))
+	}
+	update := spanNamed(spans, "UPDATE")
+	if update == nil || update.Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Error("UPDATE of the transaction is not a child of the span it began in")
+	}
+}
+
+func TestRepositoryRecordsErrors(t *testing.T) {
+	recorder := record(t)
+	db, mock := newTracedMock(t)
+	mock.ExpectQuery("SELECT id, text FROM todos WHERE id = ?").WithArgs("missing").WillReturnError(sql.ErrNoRows)
+	repo := NewRepository(&memoryRepository{db: db})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	if _, err := repo.WithContext(ctx).TodoByID("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("TodoByID() error = %v, want sql.ErrNoRows", err)
+	}
+	span.End()
+
+	call := spanNamed(recorder.Ended(), "repository.TodoByID")
+	if call == nil || call.Status().Description != sql.ErrNoRows.Error() {
+		t.Errorf("repository span does not record the error of the call")
+	}
+}
+
+func TestFileClientWritesOTLPJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := NewExporter("otlp-file:" + path)
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := provider.Tracer("test").Start(context.Background(), "request", trace.WithSpanKind(trace.SpanKindServer))
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("file has %d lines, want 1", len(lines))
+	}
+	var data struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &data); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if len(data.ResourceSpans) != 1 || len(data.ResourceSpans[0].ScopeSpans) != 1 || len(data.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("line = %s, want one span", lines[0])
+	}
+	got := data.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	want := span.SpanContext()
+	if got.TraceID != want.TraceID().String() || got.SpanID != want.SpanID().String() {
+		t.Errorf("ids = %s/%s, want hex %s/%s", got.TraceID, got.SpanID, want.TraceID(), want.SpanID())
+	}
+	if got.Name != "request" || got.Kind != 2 {
+		t.Errorf("span = %q of kind %d, want request of kind 2, server", got.Name, got.Kind)
+	}
+	if !regexp.MustCompile(`"startTimeUnixNano":"\d+"`).MatchString(lines[0]) {
+		t.Errorf("line %s has no start time", lines[0])
+	}
+}
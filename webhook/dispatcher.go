@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chloexu/hackernews/logging"
+	"github.com/chloexu/hackernews/repository"
+)
+
+// MaxAttempts is how often a delivery is tried before it is dead.
+const MaxAttempts = 8
+
+// firstRetryDelay is the wait after the first failed attempt, doubling with
+// every further one up to maxRetryDelay.
+const (
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+)
+
+// batchSize is the most deliveries a dispatcher claims at once.
+const batchSize = 50
+
+// Lease is how long a dispatcher holds the deliveries it claims, which
+// outlasts a batch of attempts timing out. A delivery whose dispatcher
+// stopped before recording the attempt is claimed again once the lease is
+// over, so a delivery may be sent twice but is not lost.
+const Lease = 15 * time.Minute
+
+// maxErrorLength is the longest error kept in the log of a delivery.
+const maxErrorLength = 1024
+
+// RetryDelay is the wait before the next attempt at a delivery that failed
+// attempts times.
+func RetryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Dispatcher sends the pending deliveries of webhooks when they are due. Any
+// number of dispatchers may run against the same database, each claiming its
+// own deliveries.
+type Dispatcher struct {
+	webhooks repository.WebhookRepository
+	client   *http.Client
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+// NewDispatcher returns a dispatcher posting with client, or with a client
+// timing out after 10 seconds when client is nil.
+func NewDispatcher(webhooks repository.WebhookRepository, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{webhooks: webhooks, client: client, now: time.Now}
+}
+
+// Run sends due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	for {
+		sent, err := d.DeliverDue(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error().Err(err).Msg("webhook dispatcher")
+		}
+		if err == nil && sent == batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// DeliverDue claims a batch of due deliveries, makes one attempt at each and
+// returns how many it attempted.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	due, err := d.webhooks.ClaimDueWebhookDeliveries(d.now(), Lease, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range due {
+		statusCode, err := d.send(ctx, delivery)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if _, err := d.webhooks.RecordWebhookAttempt(d.attempted(delivery, statusCode, err)); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// attempted returns the delivery after an attempt that got statusCode or
+// failed with err.
+func (d *Dispatcher) attempted(delivery repository.WebhookDeliveryRow, statusCode int, err error) repository.WebhookDeliveryRow {
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	delivery.NextAttemptAt = time.Time{}
+	if err == nil {
+		delivery.Status = repository.DeliveryDelivered
+		delivery.DeliveredAt = now
+		return delivery
+	}
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Status = repository.DeliveryDead
+		return delivery
+	}
+	delivery.NextAttemptAt = now.Add(RetryDelay(delivery.Attempts))
+	return delivery
+}
+
+// send posts a delivery and returns the status code of the response, if
+// any. Statuses other than 2xx are errors.
+func (d *Dispatcher) send(ctx context.Context, delivery repository.WebhookDeliveryRow) (int, error) {
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign([]byte(delivery.Secret), timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// memoryWebhooks keeps the deliveries of webhooks in memory.
+type memoryWebhooks struct {
+	repository.WebhookRepository
+	mu         sync.Mutex
+	deliveries []repository.WebhookDeliveryRow
+}
+
+func (m *memoryWebhooks) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]repository.WebhookDeliveryRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []repository.WebhookDeliveryRow
+	for i := range m.deliveries {
+		delivery := &m.deliveries[i]
+		if delivery.Status == repository.DeliveryPending && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			delivery.NextAttemptAt = now.Add(lease)
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryWebhooks) RecordWebhookAttempt(row repository.WebhookDeliveryRow) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == row.ID {
+			m.deliveries[i] = row
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	payload := []byte(`{"entityType":"todo","action":"complete"}`)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify([]byte("s3cret"), r.Header.Get(SignatureHeader), r.Header.Get(TimestampHeader), body, now, time.Minute) {
+			t.Errorf("receiver got a delivery with an invalid signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got := r.Header.Get(EventHeader); got != "todo.complete" {
+			t.Errorf("receiver got event %q, want todo.complete", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	webhooks := &memoryWebhooks{deliveries: []repository.WebhookDeliveryRow{{ID: 1, EventType: "todo.complete", Payload: payload,
+		Status: repository.DeliveryPending, NextAttemptAt: now, URL: receiver.URL, Secret: "s3cret"}}}
+	dispatcher := NewDispatcher(webhooks, receiver.Client())
+	dispatcher.now = func() time.Time { return now }
+
+	if sent, err := dispatcher.DeliverDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("Dispatcher.DeliverDue() = %v, %v, want 1, nil", sent, err)
+	}
+	got := webhooks.deliveries[0]
+	if got.Status != repository.DeliveryDelivered || got.Attempts != 1 || got.LastStatusCode != http.StatusNoContent || !got.DeliveredAt.Equal(now) {
+		t.Errorf("delivery after DeliverDue() = %+v, want delivered at the first attempt", got)
+	}
+}
+
+func TestDispatcherRetriesUntilDead(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	webhooks := &memoryWebhooks{deliveries: []repository.WebhookDeliveryRow{{ID: 1, EventType: "todo.complete", Payload: []byte(`{}`),
+		Status: repository.DeliveryPending, NextAttemptAt: now, URL: receiver.URL, Secret: "s3cret"}}}
+	dispatcher := NewDispatcher(webhooks, receiver.Client())
+	dispatcher.now = func() time.Time { return now }
+
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		if sent, err := dispatcher.DeliverDue(context.Background()); err != nil || sent != 1 {
+			t.Fatalf("attempt %d: Dispatcher.DeliverDue() = %v, %v, want 1, nil", attempt, sent, err)
+		}
+		got := webhooks.deliveries[0]
+		if got.Attempts != attempt || got.LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d: delivery = %+v", attempt, got)
+		}
+		if attempt < MaxAttempts {
+			if want := now.Add(RetryDelay(attempt)); got.Status != repository.DeliveryPending || !got.NextAttemptAt.Equal(want) {
+				t.Fatalf("attempt %d: delivery = %+v, want pending until %v", attempt, got, want)
+			}
+			// the retry is not due before its time
+			if sent, _ := dispatcher.DeliverDue(context.Background()); sent != 0 {
+				t.Fatalf("attempt %d: Dispatcher.DeliverDue() sent a delivery before it was due", attempt)
+			}
+			now = got.NextAttemptAt
+		}
+	}
+	if got := webhooks.deliveries[0]; got.Status != repository.DeliveryDead || !got.NextAttemptAt.IsZero() {
+		t.Errorf("delivery after %d attempts = %+v, want dead", MaxAttempts, got)
+	}
+}
+
+func TestDispatchersClaimTheirOwnDeliveries(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	var other *Dispatcher
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		// another dispatcher looking while this delivery is in flight
+		if sent, err := other.DeliverDue(r.Context()); err != nil || sent != 0 {
+			t.Errorf("other Dispatcher.DeliverDue() = %v, %v, want 0, nil", sent, err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	webhooks := &memoryWebhooks{deliveries: []repository.WebhookDeliveryRow{{ID: 1, EventType: "todo.complete", Payload: []byte(`{}`),
+		Status: repository.DeliveryPending, NextAttemptAt: now, URL: receiver.URL, Secret: "s3cret"}}}
+	dispatcher := NewDispatcher(webhooks, receiver.Client())
+	dispatcher.now = func() time.Time { return now }
+	other = NewDispatcher(webhooks, receiver.Client())
+	other.now = func() time.Time { return now.Add(time.Minute) }
+
+	if sent, err := dispatcher.DeliverDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("Dispatcher.DeliverDue() = %v, %v, want 1, nil", sent, err)
+	}
+	if received != 1 {
+		t.Errorf("receiver got %d deliveries, want 1", received)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := RetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2022, 5, 20, 14, 0, 0, 0, time.UTC)
+	body := []byte(`{"action":"complete"}`)
+	timestamp := "1653055200"
+	signature := "sha256=" + Sign([]byte("s3cret"), timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		now       time.Time
+		want      bool
+	}{
+		{"test genuine delivery should verify", "s3cret", timestamp, string(body), now, true},
+		{"test other secret should fail", "guess", timestamp, string(body), now, false},
+		{"test changed body should fail", "s3cret", timestamp, `{"action":"delete"}`, now, false},
+		{"test changed timestamp should fail", "s3cret", "1653055201", string(body), now, false},
+		{"test old delivery should fail", "s3cret", timestamp, string(body), now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify([]byte(tt.secret), signature, tt.timestamp, []byte(tt.body), tt.now, 5*time.Minute); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package webhook delivers outbox events to the webhooks users register, as
+// signed JSON posts retried with exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/chloexu/hackernews/repository"
+)
+
+// Headers of a delivery. SignatureHeader is "sha256=" followed by Sign of the
+// request, whose timestamp is in TimestampHeader.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp, a dot and body, with
+// secret as the key. Receivers check it, and reject old timestamps, to make
+// sure a delivery is genuine.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery received at now, allowing for
+// tolerance between the clocks of the sender and the receiver.
+func Verify(secret []byte, signature string, timestamp string, body []byte, now time.Time, tolerance time.Duration) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte("sha256="+Sign(secret, timestamp, body)))
+}
+
+// Fanout is an outbox sink queueing a delivery of each event to every
+// webhook that receives it. Queueing an event twice has no effect, so the
+// relay may send it again.
+type Fanout struct {
+	webhooks repository.WebhookRepository
+}
+
+func NewFanout(webhooks repository.WebhookRepository) *Fanout {
+	return &Fanout{webhooks: webhooks}
+}
+
+func (f *Fanout) Send(ctx context.Context, events []repository.EventRow) error {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	_, err := f.webhooks.QueueWebhookDeliveries(ids)
+	return err
+}